@@ -39,13 +39,31 @@ func (x List) End() token.Pos {
 	return x[len(x)-1].End()
 }
 
+// Bad is a placeholder for a node containing syntax errors for which
+// no correct node could be created.
+type Bad struct {
+	From token.Pos // position of first character of the bad node
+	To   token.Pos // position of first character immediately after the bad node
+}
+
+func (x *Bad) isNode()        {}
+func (x *Bad) Pos() token.Pos { return x.From }
+func (x *Bad) End() token.Pos { return x.To }
+
 // Macro is a LaTeX macro.
 // ex:
 //  \sqrt{a}
 //  \frac{num}{den}
+//
+// The arguments of a user-defined macro are *Verbatim nodes holding their
+// source text, e.g. {x+1} in \half{x+1}, and the nodes the macro expands
+// to are held by Expansion.
+// The positions of the expanded nodes are those of their source, e.g. in
+// the replacement text of the macro definition.
 type Macro struct {
-	Name *Ident
-	Args List
+	Name      *Ident
+	Args      List
+	Expansion List // expansion of a user-defined macro, or nil
 }
 
 func (x *Macro) isNode()        {}
@@ -57,9 +75,31 @@ func (x *Macro) End() token.Pos {
 	return x.Name.End()
 }
 
+// MacroDef is a macro definition.
+// ex:
+//  \newcommand{\R}{\mathbb{R}}
+//  \newcommand{\norm}[2][2]{\|#2\|_{#1}}
+//  \def\half#1{\frac{#1}{2}}
+type MacroDef struct {
+	Cmd      *Ident    // definition command (\newcommand, \renewcommand, \providecommand or \def)
+	Name     *Ident    // name of the defined macro
+	NArgs    int       // number of arguments of the defined macro
+	Optional bool      // whether the first argument of the defined macro is optional
+	Default  string    // default value of the optional argument
+	Body     string    // replacement text of the defined macro
+	Rbrace   token.Pos // position of the '}' closing the replacement text
+}
+
+func (x *MacroDef) isNode()        {}
+func (x *MacroDef) Pos() token.Pos { return x.Cmd.Pos() }
+func (x *MacroDef) End() token.Pos { return x.Rbrace + 1 }
+
 // Arg is an argument of a macro.
 // ex:
 //  {a} in \sqrt{a}
+//
+// As for the other nodes, End is the position immediately after the
+// closing brace, so that the argument spans the source [Pos, End).
 type Arg struct {
 	Lbrace token.Pos // position of '{'
 	List   List      // or stmt?
@@ -67,12 +107,14 @@ type Arg struct {
 }
 
 func (x *Arg) Pos() token.Pos { return x.Lbrace }
-func (x *Arg) End() token.Pos { return x.Rbrace }
+func (x *Arg) End() token.Pos { return x.Rbrace + 1 }
 func (x *Arg) isNode()        {}
 
 // OptArg is an optional argument of a macro
 // ex:
 //  [n] in \sqrt[n]{a}
+//
+// End is the position immediately after the closing bracket.
 type OptArg struct {
 	Lbrack token.Pos // position of '['
 	List   List
@@ -80,7 +122,7 @@ type OptArg struct {
 }
 
 func (x *OptArg) Pos() token.Pos { return x.Lbrack }
-func (x *OptArg) End() token.Pos { return x.Rbrack }
+func (x *OptArg) End() token.Pos { return x.Rbrack + 1 }
 func (x *OptArg) isNode()        {}
 
 type Ident struct {
@@ -92,21 +134,133 @@ func (x *Ident) Pos() token.Pos { return x.NamePos }
 func (x *Ident) End() token.Pos { return token.Pos(int(x.NamePos) + len(x.Name)) }
 func (x *Ident) isNode()        {}
 
+// Env is a LaTeX environment.
+// ex:
+//  \begin{equation} x^2 \end{equation}
+//  \begin{figure}[htbp] ... \end{figure}
+type Env struct {
+	BeginPos token.Pos // position of '\begin'
+	Name     *Ident    // name of the environment
+	Args     List      // arguments of the environment
+	Body     List
+	EndPos   token.Pos // position immediately after '\end{name}'
+}
+
+func (x *Env) isNode()        {}
+func (x *Env) Pos() token.Pos { return x.BeginPos }
+func (x *Env) End() token.Pos { return x.EndPos }
+
+// Table is an alignment environment, whose body is made of cells
+// separated by '&' and of rows ended by \\.
+// ex:
+//  \begin{pmatrix} a & b \\ c & d \end{pmatrix}
+//  \begin{tabular}{l|r} \hline a & b \\[2pt] c & d \end{tabular}
+type Table struct {
+	Env     *Env     // table environment, with an empty body
+	ColSpec *Arg     // column specification (e.g. {l|r} for tabular and array), or nil
+	Rows    [][]Cell // cells of the table, row by row
+}
+
+func (x *Table) isNode()        {}
+func (x *Table) Pos() token.Pos { return x.Env.Pos() }
+func (x *Table) End() token.Pos { return x.Env.End() }
+
+// Cell is a cell of a Table.
+type Cell struct {
+	Rules  List      // horizontal rules (e.g. \hline) preceding the row, with the spaces and comments preceding them; only set for the first cell of a row
+	List   List      // content of the cell
+	Sep    string    // separator ending the cell: "&", \\ or "" for the last cell of the table
+	SepPos token.Pos // position of the separator, or of \end for the last cell of the table
+	Space  *OptArg   // vertical space following a \\ separator (e.g. [2pt]), or nil
+}
+
+// Delimited is a math expression enclosed in auto-sized delimiters.
+//
+// e.g.: \left( \frac{a}{b} \middle| c \right.
+type Delimited struct {
+	Left  *Delim // \left delimiter
+	Body  List   // body, including the \middle delimiters at their position
+	Right *Delim // \right delimiter
+}
+
+func (x *Delimited) isNode()        {}
+func (x *Delimited) Pos() token.Pos { return x.Left.Pos() }
+func (x *Delimited) End() token.Pos { return x.Right.End() }
+
+// Middle returns the \middle delimiters of the body of x.
+func (x *Delimited) Middle() []*Delim {
+	var mids []*Delim
+	for _, node := range x.Body {
+		if mid, ok := node.(*Delim); ok && mid.Macro == `\middle` {
+			mids = append(mids, mid)
+		}
+	}
+	return mids
+}
+
+// Delim is an auto-sized delimiter: a \left, \middle or \right command,
+// followed by its delimiter.
+//
+// e.g.: \left( or \right.
+type Delim struct {
+	MacroPos token.Pos // position of \left, \middle or \right
+	Macro    string    // \left, \middle or \right
+	DelimPos token.Pos // position of the delimiter
+	Text     string    // delimiter, e.g. "(", "\langle" or "." for the null delimiter
+}
+
+func (x *Delim) isNode()        {}
+func (x *Delim) Pos() token.Pos { return x.MacroPos }
+func (x *Delim) End() token.Pos { return token.Pos(int(x.DelimPos) + len(x.Text)) }
+
+// IsNull reports whether x is the null delimiter ".", which produces no
+// symbol.
+func (x *Delim) IsNull() bool { return x.Text == "." }
+
+// Group is a group of nodes enclosed in braces.
+//
+// A group delimits the scope of declarations: switches such as \bf, \it
+// or \small apply to the nodes following them, up to the end of the
+// enclosing group.
+//
+// e.g.: {\bf bold} normal
+type Group struct {
+	Lbrace token.Pos // position of '{'
+	List   List
+	Rbrace token.Pos // position of '}'
+}
+
+func (x *Group) isNode()        {}
+func (x *Group) Pos() token.Pos { return x.Lbrace }
+func (x *Group) End() token.Pos { return x.Rbrace + 1 }
+
 // MathExpr is a math expression.
 // ex:
 //  $f(x) \doteq \sqrt[n]{x}$
 //  \[ x^n + y^n = z^n \]
+//  \begin{equation} E = mc^2 \end{equation}
 type MathExpr struct {
-	Delim string    // delimiter used for this math expression.
-	Left  token.Pos // position of opening '$', '\(', '\[' or '\begin{math}'
-	List  List
-	Right token.Pos // position of closing '$', '\)', '\]' or '\end{math}'
+	Delim   string    // delimiter used for this math expression: '$', '$$', '\(', '\[' or '\begin{name}'.
+	Display bool      // whether this is a displayed math expression.
+	Left    token.Pos // position of opening '$', '$$', '\(', '\[' or '\begin{math}'
+	List    List
+	Right   token.Pos // position of closing '$', '$$', '\)', '\]', or immediately after '\end{math}'
 }
 
 func (x *MathExpr) isNode()        {}
 func (x *MathExpr) Pos() token.Pos { return x.Left }
 func (x *MathExpr) End() token.Pos { return x.Right }
 
+// Paragraph is a paragraph of text, ended by a blank line or a \par
+// command.
+type Paragraph struct {
+	List List
+}
+
+func (x *Paragraph) isNode()        {}
+func (x *Paragraph) Pos() token.Pos { return x.List.Pos() }
+func (x *Paragraph) End() token.Pos { return x.List.End() }
+
 type Word struct {
 	WordPos token.Pos
 	Text    string
@@ -125,6 +279,90 @@ func (x *Literal) isNode()        {}
 func (x *Literal) Pos() token.Pos { return x.LitPos }
 func (x *Literal) End() token.Pos { return token.Pos(int(x.LitPos) + len(x.Text)) }
 
+// Dimen is a TeX dimension, optionally followed by the stretch and shrink
+// components of a glue specification.
+// ex:
+//  2.5em
+//  -3pt
+//  0.5\textwidth
+//  1em plus 1fil minus 2pt
+type Dimen struct {
+	ValuePos token.Pos // position of the value, or of the unit when there is no value
+	Value    string    // number and its signs, e.g. "-2.5"; may be empty when the unit is a macro
+	UnitPos  token.Pos // position of the unit
+	Unit     string    // unit, e.g. "pt" or "fil", length macro, e.g. \textwidth, or empty for a bare number
+	Plus     *Dimen    // stretch component, following "plus"; or nil
+	Minus    *Dimen    // shrink component, following "minus"; or nil
+}
+
+func (x *Dimen) isNode()        {}
+func (x *Dimen) Pos() token.Pos { return x.ValuePos }
+func (x *Dimen) End() token.Pos {
+	switch {
+	case x.Minus != nil:
+		return x.Minus.End()
+	case x.Plus != nil:
+		return x.Plus.End()
+	}
+	return token.Pos(int(x.UnitPos) + len(x.Unit))
+}
+
+// KeyVal is a comma-separated list of key-value options, as found in
+// optional arguments.
+// ex:
+//  width=3cm,angle=90 in \includegraphics[width=3cm,angle=90]{img}
+//  per-mode=symbol in \SI[per-mode=symbol]{3}{\metre}
+type KeyVal struct {
+	Pairs []*Pair
+}
+
+func (x *KeyVal) isNode() {}
+func (x *KeyVal) Pos() token.Pos {
+	if len(x.Pairs) == 0 {
+		return -1
+	}
+	return x.Pairs[0].KeyPos
+}
+
+func (x *KeyVal) End() token.Pos {
+	if len(x.Pairs) == 0 {
+		return -1
+	}
+	return x.Pairs[len(x.Pairs)-1].End()
+}
+
+// Lookup returns the value of the last option named key, and whether
+// there is such an option.
+func (x *KeyVal) Lookup(key string) (List, bool) {
+	for i := len(x.Pairs) - 1; i >= 0; i-- {
+		if pair := x.Pairs[i]; pair.Key == key {
+			return pair.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Pair is an option of a KeyVal list: a key, optionally followed by '='
+// and a value.
+type Pair struct {
+	KeyPos token.Pos // position of the key
+	Key    string    // key, e.g. "width" or "per-mode"
+	Assign token.Pos // position of '=', if any
+	Value  List      // value following '='; nil when the option has no value
+}
+
+// End returns the position of the first character immediately after the
+// option.
+func (x *Pair) End() token.Pos {
+	switch {
+	case len(x.Value) > 0:
+		return x.Value.End()
+	case x.Value != nil:
+		return x.Assign + 1
+	}
+	return token.Pos(int(x.KeyPos) + len(x.Key))
+}
+
 type Symbol struct {
 	SymPos token.Pos
 	Text   string
@@ -134,6 +372,30 @@ func (x *Symbol) isNode()        {}
 func (x *Symbol) Pos() token.Pos { return x.SymPos }
 func (x *Symbol) End() token.Pos { return token.Pos(int(x.SymPos) + len(x.Text)) }
 
+// Comment is a LaTeX comment, from a '%' character to the end of the line.
+type Comment struct {
+	Percent token.Pos // position of '%'
+	Text    string    // comment text, including the '%' and excluding the newline
+}
+
+func (x *Comment) isNode()        {}
+func (x *Comment) Pos() token.Pos { return x.Percent }
+func (x *Comment) End() token.Pos { return token.Pos(int(x.Percent) + len(x.Text)) }
+
+// Verbatim is a piece of text scanned verbatim, without interpreting
+// macros, comments or special characters.
+//
+// e.g.: |a%b| in \verb|a%b|, the body of \begin{verbatim}...\end{verbatim},
+// or {x+1} in \half{x+1}, where \half is a user-defined macro.
+type Verbatim struct {
+	VerbPos token.Pos // position of the opening delimiter, or of the first character
+	Text    string    // verbatim text, including its delimiters if any
+}
+
+func (x *Verbatim) isNode()        {}
+func (x *Verbatim) Pos() token.Pos { return x.VerbPos }
+func (x *Verbatim) End() token.Pos { return token.Pos(int(x.VerbPos) + len(x.Text)) }
+
 // Sub is a subscript node.
 //
 // e.g.: \sum_{i=0}
@@ -148,9 +410,13 @@ func (x *Sub) End() token.Pos { return x.Node.End() }
 
 // Sup is a superscript node.
 //
-// e.g.: \sum^{n}
+// The primes of a math expression are held by a superscript, whose list
+// starts with a "'" symbol for each prime, followed by the nodes of the
+// explicit superscript, if any.
+//
+// e.g.: \sum^{n}, f' or f'^2
 type Sup struct {
-	HatPos token.Pos
+	HatPos token.Pos // position of '^', or of the first prime
 	Node   Node
 }
 
@@ -161,6 +427,9 @@ func (x *Sup) End() token.Pos { return x.Node.End() }
 // Print prints node to w.
 func Print(o io.Writer, node Node) {
 	switch node := node.(type) {
+	case *Bad:
+		fmt.Fprintf(o, "ast.Bad{}")
+
 	case *Arg:
 		fmt.Fprintf(o, "{")
 		for i, n := range node.List {
@@ -174,6 +443,75 @@ func Print(o io.Writer, node Node) {
 	case *Ident:
 		fmt.Fprintf(o, "ast.Ident{%q}", node.Name)
 
+	case *Table:
+		fmt.Fprintf(o, "ast.Table{%q", node.Env.Name.Name)
+		if len(node.Env.Args) > 0 {
+			fmt.Fprintf(o, ", Args:")
+			for i, n := range node.Env.Args {
+				if i > 0 {
+					fmt.Fprintf(o, ", ")
+				}
+				Print(o, n)
+			}
+		}
+		if len(node.Rows) > 0 {
+			fmt.Fprintf(o, ", Rows:")
+			for i, row := range node.Rows {
+				if i > 0 {
+					fmt.Fprintf(o, ", ")
+				}
+				fmt.Fprintf(o, "[")
+				for j, cell := range row {
+					if j > 0 {
+						fmt.Fprintf(o, ", ")
+					}
+					printCell(o, cell)
+				}
+				fmt.Fprintf(o, "]")
+			}
+		}
+		fmt.Fprintf(o, "}")
+
+	case *KeyVal:
+		fmt.Fprintf(o, "ast.KeyVal{")
+		for i, pair := range node.Pairs {
+			if i > 0 {
+				fmt.Fprintf(o, ", ")
+			}
+			fmt.Fprintf(o, "%q", pair.Key)
+			if pair.Value != nil {
+				fmt.Fprintf(o, ":")
+				Print(o, pair.Value)
+			}
+		}
+		fmt.Fprintf(o, "}")
+
+	case *Delimited:
+		fmt.Fprintf(o, "ast.Delimited{Left:%q", node.Left.Text)
+		if len(node.Body) > 0 {
+			fmt.Fprintf(o, ", Body:")
+			for i, n := range node.Body {
+				if i > 0 {
+					fmt.Fprintf(o, ", ")
+				}
+				Print(o, n)
+			}
+		}
+		fmt.Fprintf(o, ", Right:%q}", node.Right.Text)
+
+	case *Delim:
+		fmt.Fprintf(o, "ast.Delim{%q, %q}", node.Macro, node.Text)
+
+	case *Group:
+		fmt.Fprintf(o, "ast.Group{")
+		for i, n := range node.List {
+			if i > 0 {
+				fmt.Fprintf(o, ", ")
+			}
+			Print(o, n)
+		}
+		fmt.Fprintf(o, "}")
+
 	case *Macro:
 		fmt.Fprintf(o, "ast.Macro{%q", node.Name.Name)
 		switch len(node.Args) {
@@ -188,9 +526,49 @@ func Print(o io.Writer, node Node) {
 				Print(o, n)
 			}
 		}
+		if node.Expansion != nil {
+			fmt.Fprintf(o, ", Expansion:")
+			Print(o, node.Expansion)
+		}
+		fmt.Fprintf(o, "}")
+	case *MacroDef:
+		fmt.Fprintf(o, "ast.MacroDef{%q, %q", node.Cmd.Name, node.Name.Name)
+		if node.NArgs > 0 {
+			fmt.Fprintf(o, ", NArgs:%d", node.NArgs)
+		}
+		if node.Optional {
+			fmt.Fprintf(o, ", Default:%q", node.Default)
+		}
+		fmt.Fprintf(o, ", Body:%q}", node.Body)
+	case *Env:
+		fmt.Fprintf(o, "ast.Env{%q", node.Name.Name)
+		if len(node.Args) > 0 {
+			fmt.Fprintf(o, ", Args:")
+			for i, n := range node.Args {
+				if i > 0 {
+					fmt.Fprintf(o, ", ")
+				}
+				Print(o, n)
+			}
+		}
+		if len(node.Body) > 0 {
+			fmt.Fprintf(o, ", Body:")
+			for i, n := range node.Body {
+				if i > 0 {
+					fmt.Fprintf(o, ", ")
+				}
+				Print(o, n)
+			}
+		}
 		fmt.Fprintf(o, "}")
 	case *MathExpr:
 		fmt.Fprintf(o, "ast.MathExpr{")
+		if node.Display {
+			fmt.Fprintf(o, "Display:true")
+			if len(node.List) > 0 {
+				fmt.Fprintf(o, ", ")
+			}
+		}
 		switch len(node.List) {
 		case 0:
 			// no-op
@@ -213,10 +591,30 @@ func Print(o io.Writer, node Node) {
 			Print(o, n)
 		}
 		fmt.Fprintf(o, "]")
+	case *Paragraph:
+		fmt.Fprintf(o, "ast.Paragraph{")
+		for i, n := range node.List {
+			if i > 0 {
+				fmt.Fprintf(o, ", ")
+			}
+			Print(o, n)
+		}
+		fmt.Fprintf(o, "}")
 	case *Word:
 		fmt.Fprintf(o, "ast.Word{%q}", node.Text)
 	case *Literal:
 		fmt.Fprintf(o, "ast.Lit{%q}", node.Text)
+	case *Dimen:
+		fmt.Fprintf(o, "ast.Dimen{%q, %q", node.Value, node.Unit)
+		if node.Plus != nil {
+			fmt.Fprintf(o, ", Plus:")
+			Print(o, node.Plus)
+		}
+		if node.Minus != nil {
+			fmt.Fprintf(o, ", Minus:")
+			Print(o, node.Minus)
+		}
+		fmt.Fprintf(o, "}")
 	case List:
 		fmt.Fprintf(o, "ast.List{")
 		for i, n := range node {
@@ -240,6 +638,12 @@ func Print(o io.Writer, node Node) {
 	case *Symbol:
 		fmt.Fprintf(o, "ast.Symbol{%q}", node.Text)
 
+	case *Verbatim:
+		fmt.Fprintf(o, "ast.Verbatim{%q}", node.Text)
+
+	case *Comment:
+		fmt.Fprintf(o, "ast.Comment{%q}", node.Text)
+
 		//	case *Op:
 		//		fmt.Fprintf(o, "ast.Op{%q}", node.Text)
 
@@ -251,16 +655,49 @@ func Print(o io.Writer, node Node) {
 	}
 }
 
+// printCell prints the table cell to w.
+func printCell(o io.Writer, cell Cell) {
+	fmt.Fprintf(o, "ast.Cell{")
+	sep := ""
+	if len(cell.Rules) > 0 {
+		fmt.Fprintf(o, "Rules:")
+		Print(o, cell.Rules)
+		sep = ", "
+	}
+	for _, n := range cell.List {
+		io.WriteString(o, sep)
+		Print(o, n)
+		sep = ", "
+	}
+	if cell.Space != nil {
+		fmt.Fprintf(o, "%sSpace:", sep)
+		Print(o, cell.Space)
+	}
+	fmt.Fprintf(o, "}")
+}
+
 var (
 	_ Node = (*List)(nil)
 	_ Node = (*Arg)(nil)
+	_ Node = (*Bad)(nil)
+	_ Node = (*Comment)(nil)
+	_ Node = (*Delim)(nil)
+	_ Node = (*Dimen)(nil)
+	_ Node = (*Delimited)(nil)
+	_ Node = (*Env)(nil)
+	_ Node = (*Group)(nil)
 	_ Node = (*Ident)(nil)
+	_ Node = (*KeyVal)(nil)
 	_ Node = (*Macro)(nil)
+	_ Node = (*MacroDef)(nil)
 	_ Node = (*MathExpr)(nil)
 	_ Node = (*OptArg)(nil)
+	_ Node = (*Paragraph)(nil)
 	_ Node = (*Word)(nil)
 	_ Node = (*Literal)(nil)
 	_ Node = (*Sup)(nil)
 	_ Node = (*Sub)(nil)
 	_ Node = (*Symbol)(nil)
+	_ Node = (*Table)(nil)
+	_ Node = (*Verbatim)(nil)
 )
@@ -96,6 +96,92 @@ func TestPrint(t *testing.T) {
 			node: &Ident{Name: `\cos`},
 			want: `ast.Ident{"\\cos"}`,
 		},
+		{
+			node: &MacroDef{
+				Cmd:      &Ident{Name: `\newcommand`, NamePos: 2},
+				Name:     &Ident{Name: `\pow`},
+				NArgs:    2,
+				Optional: true,
+				Default:  "2",
+				Body:     "#2^{#1}",
+			},
+			want: `ast.MacroDef{"\\newcommand", "\\pow", NArgs:2, Default:"2", Body:"#2^{#1}"}`,
+			pos:  2,
+		},
+		{
+			node: &Env{
+				BeginPos: 1,
+				Name:     &Ident{Name: "figure"},
+				Args:     List{&OptArg{List: List{&Word{Text: "h"}}}},
+				Body:     List{&Word{Text: "x"}},
+			},
+			want: `ast.Env{"figure", Args:[ast.Word{"h"}], Body:ast.Word{"x"}}`,
+			pos:  1,
+		},
+		{
+			node: &Paragraph{List: List{&Word{WordPos: 4, Text: "a"}, &Word{WordPos: 6, Text: "b"}}},
+			want: `ast.Paragraph{ast.Word{"a"}, ast.Word{"b"}}`,
+			pos:  4,
+		},
+		{
+			node: &Group{Lbrace: 2, List: List{&Macro{Name: &Ident{Name: `\bf`}}, &Word{Text: "x"}}, Rbrace: 7},
+			want: `ast.Group{ast.Macro{"\\bf"}, ast.Word{"x"}}`,
+			pos:  2,
+		},
+		{
+			node: &Delimited{
+				Left:  &Delim{MacroPos: 1, Macro: `\left`, DelimPos: 6, Text: "("},
+				Body:  List{&Word{WordPos: 7, Text: "x"}},
+				Right: &Delim{MacroPos: 8, Macro: `\right`, DelimPos: 14, Text: "."},
+			},
+			want: `ast.Delimited{Left:"(", Body:ast.Word{"x"}, Right:"."}`,
+			pos:  1,
+		},
+		{
+			node: &Table{
+				Env: &Env{BeginPos: 4, Name: &Ident{Name: "matrix"}, EndPos: 30},
+				Rows: [][]Cell{
+					{{List: List{&Word{Text: "a"}}}, {}},
+					{{Rules: List{&Macro{Name: &Ident{Name: `\hline`}}}, Space: &OptArg{}}},
+				},
+			},
+			want: `ast.Table{"matrix", Rows:[ast.Cell{ast.Word{"a"}}, ast.Cell{}], [ast.Cell{Rules:ast.List{ast.Macro{"\\hline"}}, Space:[]}]}`,
+			pos:  4,
+		},
+		{
+			node: &Dimen{
+				ValuePos: 3, Value: "-1", UnitPos: 5, Unit: "em",
+				Plus: &Dimen{ValuePos: 13, Value: "2", UnitPos: 14, Unit: "fil"},
+			},
+			want: `ast.Dimen{"-1", "em", Plus:ast.Dimen{"2", "fil"}}`,
+			pos:  3,
+		},
+		{
+			node: &KeyVal{Pairs: []*Pair{
+				{KeyPos: 1, Key: "width", Assign: 6, Value: List{&Dimen{ValuePos: 7, Value: "3", UnitPos: 8, Unit: "cm"}}},
+				{KeyPos: 11, Key: "draft"},
+			}},
+			want: `ast.KeyVal{"width":ast.List{ast.Dimen{"3", "cm"}}, "draft"}`,
+			pos:  1,
+		},
+		{
+			node: &KeyVal{},
+			want: `ast.KeyVal{}`,
+			pos:  -1,
+		},
+		{
+			node: &Bad{From: 3, To: 5},
+			want: `ast.Bad{}`,
+			pos:  3,
+		},
+		{
+			node: &Macro{
+				Name: &Ident{NamePos: 2, Name: `\verb`},
+				Args: List{&Verbatim{VerbPos: 7, Text: "|%x|"}},
+			},
+			want: `ast.Macro{"\\verb", Args:ast.Verbatim{"|%x|"}}`,
+			pos:  2,
+		},
 	} {
 		t.Run("", func(t *testing.T) {
 			o := new(strings.Builder)
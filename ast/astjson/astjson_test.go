@@ -0,0 +1,177 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astjson_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/ast/astjson"
+	"github.com/go-latex/latex/token"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		`hello world`,
+		`$x^2_{i}+\sqrt[n]{y}=\frac{a}{b}$ and $$f'(x)$$`,
+		"para1 % comment\n\npara2",
+		`\[\left( a \middle| b \right.\]`,
+		"\\begin{tabular}{c|c}\\hline a & b\\\\[2pt]\nc & d\\end{tabular}",
+		"\\begin{equation}x\\end{equation}\\begin{itemize}\\item a\\end{itemize}",
+		`\verb|a%b|\lstinline[language=Go]|x := 1|`,
+		`\includegraphics[width=3cm,draft,alt=]{img}\vspace{1em plus 1fil minus 2pt}`,
+		`\newcommand{\R}[1][x]{\mathbb{#1}}\def\foo#1{bar}`,
+		`\newcommand{\half}[1]{\frac{#1}{2}}\def\R{\mathbb{R}}$\half{x}\half y\in\R$`,
+		`$\unknown$`,
+	} {
+		t.Run("", func(t *testing.T) {
+			node, _ := latex.ParseExpr(input)
+
+			data, err := astjson.Marshal(node)
+			if err != nil {
+				t.Fatalf("could not marshal %q: %+v", input, err)
+			}
+
+			got, err := astjson.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("could not unmarshal %q: %+v\n%s", input, err, data)
+			}
+
+			if !reflect.DeepEqual(got, node) {
+				t.Fatalf("round-trip failed:\ngot: %v\nwant:%v", sprint(got), sprint(node))
+			}
+
+			indent, err := astjson.MarshalIndent(got, "", "  ")
+			if err != nil {
+				t.Fatalf("could not marshal decoded ast: %+v", err)
+			}
+			got, err = astjson.Unmarshal(indent)
+			if err != nil {
+				t.Fatalf("could not unmarshal indented json: %+v", err)
+			}
+			if !reflect.DeepEqual(got, node) {
+				t.Fatalf("indented round-trip failed:\ngot: %v\nwant:%v", sprint(got), sprint(node))
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	for _, tc := range []struct {
+		node ast.Node
+		want string
+	}{
+		{
+			node: nil,
+			want: `null`,
+		},
+		{
+			node: ast.List{},
+			want: `[]`,
+		},
+		{
+			node: &ast.Macro{Name: &ast.Ident{NamePos: 1, Name: `\alpha`}},
+			want: `{"type":"Macro","name":{"type":"Ident","namePos":1,"name":"\\alpha"},"args":null}`,
+		},
+		{
+			node: ast.List{
+				&ast.Word{WordPos: 0, Text: "x"},
+				&ast.Sup{HatPos: 1, Node: &ast.Literal{LitPos: 2, Text: "2"}},
+			},
+			want: `[{"type":"Word","wordPos":0,"text":"x"},{"type":"Sup","hatPos":1,"node":{"type":"Literal","litPos":2,"text":"2"}}]`,
+		},
+		{
+			node: &ast.KeyVal{Pairs: []*ast.Pair{{Key: "draft"}}},
+			want: `{"type":"KeyVal","pairs":[{"keyPos":0,"key":"draft","assign":0,"value":null}]}`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			got, err := astjson.Marshal(tc.node)
+			if err != nil {
+				t.Fatalf("could not marshal node: %+v", err)
+			}
+			if got, want := string(got), tc.want; got != want {
+				t.Fatalf("invalid json:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestMarshalPositions(t *testing.T) {
+	const src = `$x$`
+	expr, err := latex.ParseExpr(src)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+	file, err := latex.ParseFile(token.NewFileSet(), "x.tex", src)
+	if err != nil {
+		t.Fatalf("could not parse file: %+v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		node ast.Node
+		want string
+	}{
+		{"expr", expr, `[{"type":"MathExpr","delim":"$","display":false,"left":0,"list":[{"type":"Word","wordPos":1,"text":"x"}],"right":2}]`},
+		{"file", file, `[{"type":"MathExpr","delim":"$","display":false,"left":1,"list":[{"type":"Word","wordPos":2,"text":"x"}],"right":3}]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := astjson.Marshal(tc.node)
+			if err != nil {
+				t.Fatalf("could not marshal node: %+v", err)
+			}
+			if got, want := string(got), tc.want; got != want {
+				t.Fatalf("invalid json:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `{"text":"x"}`,
+			want:  `astjson: missing node type in {"text":"x"}`,
+		},
+		{
+			input: `{"type":"Foo"}`,
+			want:  `astjson: unknown node type "Foo"`,
+		},
+		{
+			input: `[{"type":"Macro","name":{"type":"Word","text":"x"}}]`,
+			want:  `astjson: invalid node type "Word" for "name" (want "Ident")`,
+		},
+		{
+			input: `{"type":"Word","text":1}`,
+			want:  `astjson: could not decode 1: json: cannot unmarshal number into Go value of type string`,
+		},
+		{
+			input: `{"type":"Word"`,
+			want:  `astjson: could not decode {"type":"Word": unexpected end of JSON input`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := astjson.Unmarshal([]byte(tc.input))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func sprint(node ast.Node) string {
+	o := new(strings.Builder)
+	ast.Print(o, node)
+	return o.String()
+}
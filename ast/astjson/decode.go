@@ -0,0 +1,364 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+// Unmarshal decodes the JSON encoding of a syntax tree, as produced by
+// Marshal, and returns its root node.
+//
+// Missing members of a node are decoded as the zero value of the
+// corresponding field.
+func Unmarshal(data []byte) (ast.Node, error) {
+	dec := new(decoder)
+	node := dec.node(json.RawMessage(data))
+	if dec.err != nil {
+		return nil, dec.err
+	}
+	return node, nil
+}
+
+// fields holds the members of a JSON object.
+type fields map[string]json.RawMessage
+
+type decoder struct {
+	err error
+}
+
+func (dec *decoder) errorf(format string, args ...interface{}) {
+	if dec.err != nil {
+		return
+	}
+	dec.err = fmt.Errorf("astjson: "+format, args...)
+}
+
+// isNull reports whether raw is missing or holds the JSON null value.
+func isNull(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+func (dec *decoder) unmarshal(raw json.RawMessage, v interface{}) {
+	if dec.err != nil || isNull(raw) {
+		return
+	}
+	err := json.Unmarshal(raw, v)
+	if err != nil {
+		dec.errorf("could not decode %s: %w", raw, err)
+	}
+}
+
+func (dec *decoder) node(raw json.RawMessage) ast.Node {
+	if dec.err != nil || isNull(raw) {
+		return nil
+	}
+	if bytes.TrimSpace(raw)[0] == '[' {
+		return dec.list(raw)
+	}
+
+	var (
+		f   fields
+		typ string
+	)
+	dec.unmarshal(raw, &f)
+	dec.unmarshal(f["type"], &typ)
+	if dec.err != nil {
+		return nil
+	}
+
+	switch typ {
+	case "Bad":
+		return &ast.Bad{
+			From: dec.pos(f, "from"),
+			To:   dec.pos(f, "to"),
+		}
+
+	case "Macro":
+		return &ast.Macro{
+			Name:      dec.ident(f, "name"),
+			Args:      dec.list(f["args"]),
+			Expansion: dec.list(f["expansion"]),
+		}
+
+	case "MacroDef":
+		return &ast.MacroDef{
+			Cmd:      dec.ident(f, "cmd"),
+			Name:     dec.ident(f, "name"),
+			NArgs:    dec.int(f, "nArgs"),
+			Optional: dec.bool(f, "optional"),
+			Default:  dec.str(f, "default"),
+			Body:     dec.str(f, "body"),
+			Rbrace:   dec.pos(f, "rbrace"),
+		}
+
+	case "Arg":
+		return &ast.Arg{
+			Lbrace: dec.pos(f, "lbrace"),
+			List:   dec.list(f["list"]),
+			Rbrace: dec.pos(f, "rbrace"),
+		}
+
+	case "OptArg":
+		return &ast.OptArg{
+			Lbrack: dec.pos(f, "lbrack"),
+			List:   dec.list(f["list"]),
+			Rbrack: dec.pos(f, "rbrack"),
+		}
+
+	case "Ident":
+		return &ast.Ident{
+			NamePos: dec.pos(f, "namePos"),
+			Name:    dec.str(f, "name"),
+		}
+
+	case "Env":
+		return &ast.Env{
+			BeginPos: dec.pos(f, "beginPos"),
+			Name:     dec.ident(f, "name"),
+			Args:     dec.list(f["args"]),
+			Body:     dec.list(f["body"]),
+			EndPos:   dec.pos(f, "endPos"),
+		}
+
+	case "Table":
+		return dec.table(f)
+
+	case "Delimited":
+		return &ast.Delimited{
+			Left:  dec.delim(f, "left"),
+			Body:  dec.list(f["body"]),
+			Right: dec.delim(f, "right"),
+		}
+
+	case "Delim":
+		return &ast.Delim{
+			MacroPos: dec.pos(f, "macroPos"),
+			Macro:    dec.str(f, "macro"),
+			DelimPos: dec.pos(f, "delimPos"),
+			Text:     dec.str(f, "text"),
+		}
+
+	case "Group":
+		return &ast.Group{
+			Lbrace: dec.pos(f, "lbrace"),
+			List:   dec.list(f["list"]),
+			Rbrace: dec.pos(f, "rbrace"),
+		}
+
+	case "MathExpr":
+		return &ast.MathExpr{
+			Delim:   dec.str(f, "delim"),
+			Display: dec.bool(f, "display"),
+			Left:    dec.pos(f, "left"),
+			List:    dec.list(f["list"]),
+			Right:   dec.pos(f, "right"),
+		}
+
+	case "Paragraph":
+		return &ast.Paragraph{
+			List: dec.list(f["list"]),
+		}
+
+	case "Word":
+		return &ast.Word{
+			WordPos: dec.pos(f, "wordPos"),
+			Text:    dec.str(f, "text"),
+		}
+
+	case "Literal":
+		return &ast.Literal{
+			LitPos: dec.pos(f, "litPos"),
+			Text:   dec.str(f, "text"),
+		}
+
+	case "Dimen":
+		return dec.dimen(f)
+
+	case "KeyVal":
+		var pairs []fields
+		dec.unmarshal(f["pairs"], &pairs)
+		node := new(ast.KeyVal)
+		for _, pf := range pairs {
+			node.Pairs = append(node.Pairs, &ast.Pair{
+				KeyPos: dec.pos(pf, "keyPos"),
+				Key:    dec.str(pf, "key"),
+				Assign: dec.pos(pf, "assign"),
+				Value:  dec.list(pf["value"]),
+			})
+		}
+		return node
+
+	case "Symbol":
+		return &ast.Symbol{
+			SymPos: dec.pos(f, "symPos"),
+			Text:   dec.str(f, "text"),
+		}
+
+	case "Comment":
+		return &ast.Comment{
+			Percent: dec.pos(f, "percent"),
+			Text:    dec.str(f, "text"),
+		}
+
+	case "Verbatim":
+		return &ast.Verbatim{
+			VerbPos: dec.pos(f, "verbPos"),
+			Text:    dec.str(f, "text"),
+		}
+
+	case "Sub":
+		return &ast.Sub{
+			UnderPos: dec.pos(f, "underPos"),
+			Node:     dec.node(f["node"]),
+		}
+
+	case "Sup":
+		return &ast.Sup{
+			HatPos: dec.pos(f, "hatPos"),
+			Node:   dec.node(f["node"]),
+		}
+
+	case "":
+		dec.errorf("missing node type in %s", raw)
+	default:
+		dec.errorf("unknown node type %q", typ)
+	}
+	return nil
+}
+
+func (dec *decoder) list(raw json.RawMessage) ast.List {
+	if dec.err != nil || isNull(raw) {
+		return nil
+	}
+	var elems []json.RawMessage
+	dec.unmarshal(raw, &elems)
+	list := make(ast.List, 0, len(elems))
+	for _, elem := range elems {
+		list = append(list, dec.node(elem))
+	}
+	return list
+}
+
+// typed decodes the node held by the key member of f, and reports whether
+// it is of type typ.
+func (dec *decoder) typed(f fields, key, typ string) (ast.Node, bool) {
+	raw := f[key]
+	if isNull(raw) {
+		return nil, false
+	}
+	var v struct {
+		Type string `json:"type"`
+	}
+	dec.unmarshal(raw, &v)
+	if dec.err != nil {
+		return nil, false
+	}
+	if v.Type != typ {
+		dec.errorf("invalid node type %q for %q (want %q)", v.Type, key, typ)
+		return nil, false
+	}
+	return dec.node(raw), dec.err == nil
+}
+
+func (dec *decoder) ident(f fields, key string) *ast.Ident {
+	node, ok := dec.typed(f, key, "Ident")
+	if !ok {
+		return nil
+	}
+	return node.(*ast.Ident)
+}
+
+func (dec *decoder) delim(f fields, key string) *ast.Delim {
+	node, ok := dec.typed(f, key, "Delim")
+	if !ok {
+		return nil
+	}
+	return node.(*ast.Delim)
+}
+
+func (dec *decoder) dimen(f fields) *ast.Dimen {
+	node := &ast.Dimen{
+		ValuePos: dec.pos(f, "valuePos"),
+		Value:    dec.str(f, "value"),
+		UnitPos:  dec.pos(f, "unitPos"),
+		Unit:     dec.str(f, "unit"),
+	}
+	if n, ok := dec.typed(f, "plus", "Dimen"); ok {
+		node.Plus = n.(*ast.Dimen)
+	}
+	if n, ok := dec.typed(f, "minus", "Dimen"); ok {
+		node.Minus = n.(*ast.Dimen)
+	}
+	return node
+}
+
+func (dec *decoder) table(f fields) *ast.Table {
+	tbl := new(ast.Table)
+	if n, ok := dec.typed(f, "env", "Env"); ok {
+		tbl.Env = n.(*ast.Env)
+	}
+
+	colspec := -1
+	if !isNull(f["colSpec"]) {
+		colspec = dec.int(f, "colSpec")
+	}
+	if tbl.Env != nil && 0 <= colspec && colspec < len(tbl.Env.Args) {
+		arg, ok := tbl.Env.Args[colspec].(*ast.Arg)
+		if !ok {
+			dec.errorf("invalid column specification %d", colspec)
+		}
+		tbl.ColSpec = arg
+	}
+
+	var rows [][]fields
+	dec.unmarshal(f["rows"], &rows)
+	for _, row := range rows {
+		cells := make([]ast.Cell, len(row))
+		for i, cf := range row {
+			cells[i] = ast.Cell{
+				Rules:  dec.list(cf["rules"]),
+				List:   dec.list(cf["list"]),
+				Sep:    dec.str(cf, "sep"),
+				SepPos: dec.pos(cf, "sepPos"),
+			}
+			if n, ok := dec.typed(cf, "space", "OptArg"); ok {
+				cells[i].Space = n.(*ast.OptArg)
+			}
+		}
+		tbl.Rows = append(tbl.Rows, cells)
+	}
+	return tbl
+}
+
+func (dec *decoder) pos(f fields, key string) token.Pos {
+	var v token.Pos
+	dec.unmarshal(f[key], &v)
+	return v
+}
+
+func (dec *decoder) int(f fields, key string) int {
+	var v int
+	dec.unmarshal(f[key], &v)
+	return v
+}
+
+func (dec *decoder) bool(f fields, key string) bool {
+	var v bool
+	dec.unmarshal(f[key], &v)
+	return v
+}
+
+func (dec *decoder) str(f fields, key string) string {
+	var v string
+	dec.unmarshal(f[key], &v)
+	return v
+}
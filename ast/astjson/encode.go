@@ -0,0 +1,350 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package astjson encodes LaTeX syntax trees to JSON, and decodes them back.
+//
+// Each node is encoded as a JSON object, whose "type" member holds the name
+// of the node type (e.g. "Macro" for an *ast.Macro), followed by a member
+// for each field of the node, named after the field with a lower-case
+// first letter (e.g. "name" and "args" for an *ast.Macro).
+// Positions are encoded as the token.Pos values held by the nodes: byte
+// offsets for an expression parsed by latex.ParseExpr, or positions within the
+// token.FileSet of a file parsed by latex.ParseFile, where the first byte of
+// the first file is at the base of the set, 1.
+//
+// An ast.List is the only node encoded without a "type" member: it is
+// encoded as a JSON array of nodes. A nil list is encoded as null, except
+// for the Expansion of an *ast.Macro, whose member is omitted for macros
+// that are not user-defined.
+//
+// The ColSpec of an *ast.Table is encoded as the index of the column
+// specification among the arguments of the table environment, or -1.
+//
+// Example:
+//
+//	{"type":"Macro","name":{"type":"Ident","namePos":0,"name":"\\sqrt"},"args":[...]}
+package astjson // import "github.com/go-latex/latex/ast/astjson"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-latex/latex/ast"
+)
+
+// Marshal returns the JSON encoding of node.
+func Marshal(node ast.Node) ([]byte, error) {
+	v, err := encode(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// MarshalIndent is like Marshal but applies json.Indent to format the
+// output.
+func MarshalIndent(node ast.Node, prefix, indent string) ([]byte, error) {
+	v, err := encode(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(v, prefix, indent)
+}
+
+// object is a JSON object whose members are encoded in order.
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteString("{")
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+func encode(node ast.Node) (interface{}, error) {
+	enc := new(encoder)
+	v := enc.node(node)
+	if enc.err != nil {
+		return nil, enc.err
+	}
+	return v, nil
+}
+
+type encoder struct {
+	err error
+}
+
+// node returns the JSON value of node.
+func (enc *encoder) node(node ast.Node) interface{} {
+	switch node := node.(type) {
+	case nil:
+		return nil
+
+	case ast.List:
+		return enc.list(node)
+
+	case *ast.Bad:
+		return object{
+			{"type", "Bad"},
+			{"from", node.From},
+			{"to", node.To},
+		}
+
+	case *ast.Macro:
+		o := object{
+			{"type", "Macro"},
+			{"name", enc.ptr(node.Name, node.Name == nil)},
+			{"args", enc.list(node.Args)},
+		}
+		if node.Expansion != nil {
+			o = append(o, member{"expansion", enc.list(node.Expansion)})
+		}
+		return o
+
+	case *ast.MacroDef:
+		return object{
+			{"type", "MacroDef"},
+			{"cmd", enc.ptr(node.Cmd, node.Cmd == nil)},
+			{"name", enc.ptr(node.Name, node.Name == nil)},
+			{"nArgs", node.NArgs},
+			{"optional", node.Optional},
+			{"default", node.Default},
+			{"body", node.Body},
+			{"rbrace", node.Rbrace},
+		}
+
+	case *ast.Arg:
+		return object{
+			{"type", "Arg"},
+			{"lbrace", node.Lbrace},
+			{"list", enc.list(node.List)},
+			{"rbrace", node.Rbrace},
+		}
+
+	case *ast.OptArg:
+		return object{
+			{"type", "OptArg"},
+			{"lbrack", node.Lbrack},
+			{"list", enc.list(node.List)},
+			{"rbrack", node.Rbrack},
+		}
+
+	case *ast.Ident:
+		return object{
+			{"type", "Ident"},
+			{"namePos", node.NamePos},
+			{"name", node.Name},
+		}
+
+	case *ast.Env:
+		return object{
+			{"type", "Env"},
+			{"beginPos", node.BeginPos},
+			{"name", enc.ptr(node.Name, node.Name == nil)},
+			{"args", enc.list(node.Args)},
+			{"body", enc.list(node.Body)},
+			{"endPos", node.EndPos},
+		}
+
+	case *ast.Table:
+		return enc.table(node)
+
+	case *ast.Delimited:
+		return object{
+			{"type", "Delimited"},
+			{"left", enc.ptr(node.Left, node.Left == nil)},
+			{"body", enc.list(node.Body)},
+			{"right", enc.ptr(node.Right, node.Right == nil)},
+		}
+
+	case *ast.Delim:
+		return object{
+			{"type", "Delim"},
+			{"macroPos", node.MacroPos},
+			{"macro", node.Macro},
+			{"delimPos", node.DelimPos},
+			{"text", node.Text},
+		}
+
+	case *ast.Group:
+		return object{
+			{"type", "Group"},
+			{"lbrace", node.Lbrace},
+			{"list", enc.list(node.List)},
+			{"rbrace", node.Rbrace},
+		}
+
+	case *ast.MathExpr:
+		return object{
+			{"type", "MathExpr"},
+			{"delim", node.Delim},
+			{"display", node.Display},
+			{"left", node.Left},
+			{"list", enc.list(node.List)},
+			{"right", node.Right},
+		}
+
+	case *ast.Paragraph:
+		return object{
+			{"type", "Paragraph"},
+			{"list", enc.list(node.List)},
+		}
+
+	case *ast.Word:
+		return object{
+			{"type", "Word"},
+			{"wordPos", node.WordPos},
+			{"text", node.Text},
+		}
+
+	case *ast.Literal:
+		return object{
+			{"type", "Literal"},
+			{"litPos", node.LitPos},
+			{"text", node.Text},
+		}
+
+	case *ast.Dimen:
+		return object{
+			{"type", "Dimen"},
+			{"valuePos", node.ValuePos},
+			{"value", node.Value},
+			{"unitPos", node.UnitPos},
+			{"unit", node.Unit},
+			{"plus", enc.ptr(node.Plus, node.Plus == nil)},
+			{"minus", enc.ptr(node.Minus, node.Minus == nil)},
+		}
+
+	case *ast.KeyVal:
+		pairs := make([]interface{}, len(node.Pairs))
+		for i, pair := range node.Pairs {
+			pairs[i] = object{
+				{"keyPos", pair.KeyPos},
+				{"key", pair.Key},
+				{"assign", pair.Assign},
+				{"value", enc.list(pair.Value)},
+			}
+		}
+		return object{
+			{"type", "KeyVal"},
+			{"pairs", pairs},
+		}
+
+	case *ast.Symbol:
+		return object{
+			{"type", "Symbol"},
+			{"symPos", node.SymPos},
+			{"text", node.Text},
+		}
+
+	case *ast.Comment:
+		return object{
+			{"type", "Comment"},
+			{"percent", node.Percent},
+			{"text", node.Text},
+		}
+
+	case *ast.Verbatim:
+		return object{
+			{"type", "Verbatim"},
+			{"verbPos", node.VerbPos},
+			{"text", node.Text},
+		}
+
+	case *ast.Sub:
+		return object{
+			{"type", "Sub"},
+			{"underPos", node.UnderPos},
+			{"node", enc.node(node.Node)},
+		}
+
+	case *ast.Sup:
+		return object{
+			{"type", "Sup"},
+			{"hatPos", node.HatPos},
+			{"node", enc.node(node.Node)},
+		}
+
+	default:
+		if enc.err == nil {
+			enc.err = fmt.Errorf("astjson: unknown node %T", node)
+		}
+		return nil
+	}
+}
+
+// ptr returns the JSON value of a node held by a pointer field, or null.
+func (enc *encoder) ptr(node ast.Node, isNil bool) interface{} {
+	if isNil {
+		return nil
+	}
+	return enc.node(node)
+}
+
+func (enc *encoder) list(list ast.List) interface{} {
+	if list == nil {
+		return nil
+	}
+	o := make([]interface{}, len(list))
+	for i, node := range list {
+		o[i] = enc.node(node)
+	}
+	return o
+}
+
+func (enc *encoder) table(tbl *ast.Table) interface{} {
+	colspec := -1
+	if tbl.Env != nil && tbl.ColSpec != nil {
+		for i, arg := range tbl.Env.Args {
+			if arg == ast.Node(tbl.ColSpec) {
+				colspec = i
+				break
+			}
+		}
+	}
+
+	rows := make([]interface{}, len(tbl.Rows))
+	for i, row := range tbl.Rows {
+		cells := make([]interface{}, len(row))
+		for j, cell := range row {
+			cells[j] = object{
+				{"rules", enc.list(cell.Rules)},
+				{"list", enc.list(cell.List)},
+				{"sep", cell.Sep},
+				{"sepPos", cell.SepPos},
+				{"space", enc.ptr(cell.Space, cell.Space == nil)},
+			}
+		}
+		rows[i] = cells
+	}
+
+	return object{
+		{"type", "Table"},
+		{"env", enc.ptr(tbl.Env, tbl.Env == nil)},
+		{"colSpec", colspec},
+		{"rows", rows},
+	}
+}
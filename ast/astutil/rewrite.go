@@ -0,0 +1,310 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package astutil contains utilities to rewrite LaTeX syntax trees.
+package astutil // import "github.com/go-latex/latex/ast/astutil"
+
+import (
+	"fmt"
+
+	"github.com/go-latex/latex/ast"
+)
+
+// An ApplyFunc is invoked by Apply for each node n, before and/or after
+// the node's children, using a Cursor describing the current node and
+// providing operations on it.
+//
+// The return value of ApplyFunc controls the syntax tree traversal.
+// See Apply for details.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses a syntax tree recursively, starting with root,
+// and calling pre and post for each node as described below.
+// Apply returns the syntax tree, possibly modified.
+//
+// If pre is not nil, it is called for each node before the node's
+// children are traversed (pre-order). If pre returns false, no
+// children are traversed, and post is not called for that node.
+//
+// If post is not nil, and a prior call of pre didn't return false,
+// post is called for each node after its children are traversed
+// (post-order). If post returns false, traversal is terminated and
+// Apply returns immediately.
+//
+// Only fields that refer to AST nodes are considered children, in the
+// order used by ast.Walk.
+// Children of type ast.List, e.g. the arguments of a macro, are not
+// visited as nodes themselves: their elements are, in order.
+//
+// If the root node is an ast.List, its elements may be deleted or
+// inserted, and Apply returns the modified list.
+func Apply(root ast.Node, pre, post ApplyFunc) (result ast.Node) {
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = root
+	}()
+
+	a := &application{pre: pre, post: post}
+	a.apply(nil, "", nil, func(n ast.Node) { root = n }, root)
+	return root
+}
+
+var abort = new(int) // singleton, to signal termination of Apply
+
+// A Cursor describes a node encountered during Apply.
+// Information about the node and its parent is available
+// from the Node, Parent, Name, and Index methods.
+//
+// If p is a variable of type and value of the current parent node
+// c.Parent(), and f is the field identifier with name c.Name(),
+// the following invariants hold:
+//
+//	p.f            == c.Node()  if c.Index() <  0
+//	p.f[c.Index()] == c.Node()  if c.Index() >= 0
+//
+// The methods Replace, Delete, InsertBefore, and InsertAfter
+// can be used to change the AST without disrupting Apply.
+type Cursor struct {
+	parent ast.Node
+	name   string
+	iter   *iterator      // valid if the current node is part of a list
+	set    func(ast.Node) // replaces the current node in its parent, if not part of a list
+	node   ast.Node
+}
+
+// Node returns the current Node.
+func (c *Cursor) Node() ast.Node { return c.node }
+
+// Parent returns the parent of the current Node.
+// Parent returns nil for the root node.
+func (c *Cursor) Parent() ast.Node { return c.parent }
+
+// Name returns the name of the parent Node field that contains the
+// current Node, e.g. "Args" for the arguments of an *ast.Macro.
+// If the parent is an ast.List and the current Node is one of its
+// elements, Name returns the empty string.
+// For the content of a table cell, Name returns "Rules", "List" or
+// "Space", and for the value of an option, it returns "Value".
+func (c *Cursor) Name() string { return c.name }
+
+// Index reports the index >= 0 of the current Node in the list of Nodes
+// that contains it, or a value < 0 if the current Node is not part of
+// a list.
+// The index of the current node changes if InsertBefore is called while
+// processing the current node.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// Replace replaces the current Node with n.
+// If Replace is called by pre, the children of n are walked instead of
+// those of the replaced Node.
+//
+// Replace panics if n cannot be stored in the parent field of the
+// current Node, e.g. when replacing the name of a macro with an
+// *ast.Word.
+func (c *Cursor) Replace(n ast.Node) {
+	if c.iter != nil {
+		(*c.iter.list)[c.iter.index] = n
+	} else {
+		c.set(n)
+	}
+	c.node = n
+}
+
+// Delete deletes the current Node from its containing list.
+// If the current Node is not part of a list, Delete panics.
+func (c *Cursor) Delete() {
+	i := c.Index()
+	if i < 0 {
+		panic("Delete node not contained in list")
+	}
+	list := *c.iter.list
+	copy(list[i:], list[i+1:])
+	list[len(list)-1] = nil
+	*c.iter.list = list[:len(list)-1]
+	c.iter.step--
+}
+
+// InsertAfter inserts n after the current Node in its containing list.
+// If the current Node is not part of a list, InsertAfter panics.
+// Apply does not walk n.
+func (c *Cursor) InsertAfter(n ast.Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertAfter node not contained in list")
+	}
+	c.iter.insert(i+1, n)
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the current Node in its containing list.
+// If the current Node is not part of a list, InsertBefore panics.
+// Apply will not walk n.
+func (c *Cursor) InsertBefore(n ast.Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("InsertBefore node not contained in list")
+	}
+	c.iter.insert(i, n)
+	c.iter.index++
+}
+
+// iterator controls the iteration over a list of nodes.
+type iterator struct {
+	list  *ast.List
+	index int
+	step  int
+}
+
+func (it *iterator) insert(i int, n ast.Node) {
+	list := append(*it.list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = n
+	*it.list = list
+}
+
+// application carries all the shared data so we can pass it around cheaply.
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+	iter      iterator
+}
+
+func (a *application) apply(parent ast.Node, name string, iter *iterator, set func(ast.Node), n ast.Node) {
+	// avoid heap-allocating a new cursor for each apply call; reuse a.cursor instead.
+	saved := a.cursor
+	a.cursor = Cursor{parent: parent, name: name, iter: iter, set: set, node: n}
+
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+
+	// walk children.
+	// (the order of the cases matches the order of the corresponding
+	// node types in ast.Walk.)
+	switch n := a.cursor.node.(type) {
+	case nil:
+		// nothing to do.
+
+	case ast.List:
+		list := n
+		a.applyList(n, "", &list)
+		a.cursor.Replace(list)
+
+	case *ast.Macro:
+		if n.Name != nil {
+			a.apply(n, "Name", nil, func(x ast.Node) { n.Name = x.(*ast.Ident) }, n.Name)
+		}
+		a.applyList(n, "Args", &n.Args)
+		a.applyList(n, "Expansion", &n.Expansion)
+
+	case *ast.MacroDef:
+		if n.Cmd != nil {
+			a.apply(n, "Cmd", nil, func(x ast.Node) { n.Cmd = x.(*ast.Ident) }, n.Cmd)
+		}
+		if n.Name != nil {
+			a.apply(n, "Name", nil, func(x ast.Node) { n.Name = x.(*ast.Ident) }, n.Name)
+		}
+
+	case *ast.Arg:
+		a.applyList(n, "List", &n.List)
+
+	case *ast.OptArg:
+		a.applyList(n, "List", &n.List)
+
+	case *ast.Ident, *ast.Bad:
+		// nothing to do.
+
+	case *ast.Env:
+		if n.Name != nil {
+			a.apply(n, "Name", nil, func(x ast.Node) { n.Name = x.(*ast.Ident) }, n.Name)
+		}
+		a.applyList(n, "Args", &n.Args)
+		a.applyList(n, "Body", &n.Body)
+
+	case *ast.Table:
+		if n.Env != nil {
+			a.apply(n, "Env", nil, func(x ast.Node) { n.Env = x.(*ast.Env) }, n.Env)
+		}
+		for i := range n.Rows {
+			for j := range n.Rows[i] {
+				cell := &n.Rows[i][j]
+				a.applyList(n, "Rules", &cell.Rules)
+				a.applyList(n, "List", &cell.List)
+				if cell.Space != nil {
+					a.apply(n, "Space", nil, func(x ast.Node) { cell.Space = x.(*ast.OptArg) }, cell.Space)
+				}
+			}
+		}
+
+	case *ast.Delimited:
+		a.apply(n, "Left", nil, func(x ast.Node) { n.Left = x.(*ast.Delim) }, n.Left)
+		a.applyList(n, "Body", &n.Body)
+		a.apply(n, "Right", nil, func(x ast.Node) { n.Right = x.(*ast.Delim) }, n.Right)
+
+	case *ast.Delim:
+		// nothing to do.
+
+	case *ast.Group:
+		a.applyList(n, "List", &n.List)
+
+	case *ast.MathExpr:
+		a.applyList(n, "List", &n.List)
+
+	case *ast.Paragraph:
+		a.applyList(n, "List", &n.List)
+
+	case *ast.Word, *ast.Literal, *ast.Symbol, *ast.Verbatim, *ast.Comment:
+		// nothing to do.
+
+	case *ast.Dimen:
+		if n.Plus != nil {
+			a.apply(n, "Plus", nil, func(x ast.Node) { n.Plus = x.(*ast.Dimen) }, n.Plus)
+		}
+		if n.Minus != nil {
+			a.apply(n, "Minus", nil, func(x ast.Node) { n.Minus = x.(*ast.Dimen) }, n.Minus)
+		}
+
+	case *ast.KeyVal:
+		for _, pair := range n.Pairs {
+			a.applyList(n, "Value", &pair.Value)
+		}
+
+	case *ast.Sub:
+		a.apply(n, "Node", nil, func(x ast.Node) { n.Node = x }, n.Node)
+
+	case *ast.Sup:
+		a.apply(n, "Node", nil, func(x ast.Node) { n.Node = x }, n.Node)
+
+	default:
+		panic(fmt.Errorf("astutil: unknown ast node %#v (type=%T)", n, n))
+	}
+
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(abort)
+	}
+
+	a.cursor = saved
+}
+
+// applyList applies to each element of the list, stored in the name field
+// of parent.
+func (a *application) applyList(parent ast.Node, name string, list *ast.List) {
+	// avoid heap-allocating a new iterator for each applyList call; reuse a.iter instead.
+	saved := a.iter
+	a.iter = iterator{list: list}
+	for a.iter.index < len(*list) {
+		a.iter.step = 1
+		a.apply(parent, name, &a.iter, nil, (*list)[a.iter.index])
+		a.iter.index += a.iter.step
+	}
+	a.iter = saved
+}
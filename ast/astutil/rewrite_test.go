@@ -0,0 +1,235 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package astutil_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/ast/astutil"
+)
+
+// overToFrac rewrites the groups {a \over b} into \frac{a}{b}.
+func overToFrac(c *astutil.Cursor) bool {
+	grp, ok := c.Node().(*ast.Group)
+	if !ok {
+		return true
+	}
+	for i, node := range grp.List {
+		if m, ok := node.(*ast.Macro); ok && m.Name.Name == `\over` {
+			c.Replace(&ast.Macro{
+				Name: &ast.Ident{Name: `\frac`},
+				Args: ast.List{
+					&ast.Arg{List: grp.List[:i]},
+					&ast.Arg{List: grp.List[i+1:]},
+				},
+			})
+			break
+		}
+	}
+	return true
+}
+
+func TestApply(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		pre   astutil.ApplyFunc
+		post  astutil.ApplyFunc
+		want  string
+	}{
+		{
+			name:  "nop",
+			input: `hello $x^{2}_i+\sqrt[n]{y}$ % comment`,
+			want:  `hello $x^{2}_i+\sqrt[n]{y}$ % comment`,
+		},
+		{
+			name:  "over",
+			input: `$x={a+1 \over b} + {{1 \over 2} \over c}$`,
+			post:  overToFrac,
+			want:  `$x=\frac{a+1}{b}+\frac{\frac{1}{2}}{c}$`,
+		},
+		{
+			name:  "replace-pre",
+			input: `$x^{a}$`,
+			pre: func(c *astutil.Cursor) bool {
+				switch n := c.Node().(type) {
+				case *ast.Sup:
+					c.Replace(&ast.Sup{Node: &ast.Word{Text: "y"}})
+				case *ast.Word:
+					if n.Text == "y" {
+						c.Replace(&ast.Word{Text: "z"})
+					}
+				}
+				return true
+			},
+			want: `$x^z$`,
+		},
+		{
+			name:  "delete",
+			input: "a % comment\nb\\sqrt{c %other\n}",
+			pre: func(c *astutil.Cursor) bool {
+				if _, ok := c.Node().(*ast.Comment); ok {
+					c.Delete()
+				}
+				return true
+			},
+			want: `a b\sqrt{c }`,
+		},
+		{
+			name:  "insert",
+			input: `$a+b$`,
+			pre: func(c *astutil.Cursor) bool {
+				if sym, ok := c.Node().(*ast.Symbol); ok && sym.Text == "+" {
+					c.InsertBefore(&ast.Macro{Name: &ast.Ident{Name: `\,`}})
+					c.InsertAfter(&ast.Macro{Name: &ast.Ident{Name: `\,`}})
+				}
+				return true
+			},
+			want: `$a\,+\,b$`,
+		},
+		{
+			name:  "skip",
+			input: `$\sqrt{x}+x$`,
+			pre: func(c *astutil.Cursor) bool {
+				if w, ok := c.Node().(*ast.Word); ok && w.Text == "x" {
+					c.Replace(&ast.Word{Text: "y"})
+				}
+				_, ok := c.Node().(*ast.Macro)
+				return !ok
+			},
+			want: `$\sqrt{x}+y$`,
+		},
+		{
+			name:  "abort",
+			input: `$x+x+x$`,
+			post: func(c *astutil.Cursor) bool {
+				if w, ok := c.Node().(*ast.Word); ok && w.Text == "x" {
+					c.Replace(&ast.Word{Text: "y"})
+					return c.Index() < 2
+				}
+				return true
+			},
+			want: `$y+y+x$`,
+		},
+		{
+			name:  "root",
+			input: `a b`,
+			pre: func(c *astutil.Cursor) bool {
+				switch c.Node().(type) {
+				case *ast.Word:
+					c.InsertAfter(&ast.Symbol{Text: "!"})
+				case *ast.Symbol:
+					c.Delete()
+				}
+				return true
+			},
+			want: `a!b!`,
+		},
+		{
+			name:  "fields",
+			input: "\\begin{tabular}{cc}a&b\\\\[1pt]\\hline\nc&\\hspace{1pt plus 1fil}\\end{tabular}",
+			pre: func(c *astutil.Cursor) bool {
+				switch n := c.Node().(type) {
+				case *ast.Word:
+					c.Replace(&ast.Word{Text: c.Name() + strings.ToUpper(n.Text)})
+				case *ast.Dimen:
+					if c.Name() == "Plus" {
+						c.Replace(&ast.Dimen{Value: "2", Unit: "fill"})
+					}
+				case *ast.Macro:
+					if c.Name() == "Rules" {
+						c.Delete()
+					}
+				}
+				return true
+			},
+			want: "\\begin{tabular}{ListCC}ListA&ListB\\\\[1pt]\nListC&\\hspace{1pt plus 2fill}\\end{tabular}",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := latex.NewParser()
+			p.RegisterMacro(`\over`, "")
+			node, err := p.ParseExpr(tc.input)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.input, err)
+			}
+			node = astutil.Apply(node, tc.pre, tc.post)
+
+			o := new(strings.Builder)
+			err = ast.Fprint(o, node)
+			if err != nil {
+				t.Fatalf("could not print ast: %+v", err)
+			}
+			if got, want := strings.TrimSpace(o.String()), tc.want; got != want {
+				t.Fatalf("invalid rewrite:\ngot= %q\nwant=%q", got, want)
+			}
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	node, err := latex.ParseExpr(`\sqrt[n]{x_i}`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	o := new(strings.Builder)
+	astutil.Apply(node, func(c *astutil.Cursor) bool {
+		fmt.Fprintf(o, "%T.%s[%d]=%T ", c.Parent(), c.Name(), c.Index(), c.Node())
+		return true
+	}, nil)
+
+	want := "<nil>.[-1]=ast.List " +
+		"ast.List.[0]=*ast.Macro " +
+		"*ast.Macro.Name[-1]=*ast.Ident " +
+		"*ast.Macro.Args[0]=*ast.OptArg " +
+		"*ast.OptArg.List[0]=*ast.Word " +
+		"*ast.Macro.Args[1]=*ast.Arg " +
+		"*ast.Arg.List[0]=*ast.Word " +
+		"*ast.Arg.List[1]=*ast.Sub " +
+		"*ast.Sub.Node[-1]=*ast.Word "
+	if got := o.String(); got != want {
+		t.Fatalf("invalid traversal:\ngot= %s\nwant=%s", got, want)
+	}
+}
+
+func TestCursorPanics(t *testing.T) {
+	node, err := latex.ParseExpr(`$x^2$`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		fct  func(c *astutil.Cursor)
+		want string
+	}{
+		{"delete", func(c *astutil.Cursor) { c.Delete() }, "Delete node not contained in list"},
+		{"insert-after", func(c *astutil.Cursor) { c.InsertAfter(nil) }, "InsertAfter node not contained in list"},
+		{"insert-before", func(c *astutil.Cursor) { c.InsertBefore(nil) }, "InsertBefore node not contained in list"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				e := recover()
+				if e == nil {
+					t.Fatalf("expected a panic")
+				}
+				if got, want := e.(string), tc.want; got != want {
+					t.Fatalf("invalid panic message: got=%q, want=%q", got, want)
+				}
+			}()
+			astutil.Apply(node, func(c *astutil.Cursor) bool {
+				if _, ok := c.Parent().(*ast.Sup); ok {
+					tc.fct(c)
+				}
+				return true
+			}, nil)
+		})
+	}
+}
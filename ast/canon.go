@@ -0,0 +1,970 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ast
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-latex/latex/internal/symbols"
+	"github.com/go-latex/latex/internal/tex2unicode"
+	"github.com/go-latex/latex/token"
+)
+
+// CanonMode controls the optional normalizations applied by Canonicalize.
+type CanonMode uint
+
+const (
+	// Commutative sorts the terms of sums, the factors of explicit
+	// products (\cdot and \times) and the sides of symmetric relations,
+	// e.g. c=b+a is canonicalized as a+b=c.
+	// Implicit products, e.g. xy, are left untouched since juxtaposition
+	// also denotes function application, e.g. f(x).
+	Commutative CanonMode = 1 << iota
+)
+
+// Canonicalizer rewrites the math formulas of an AST in a canonical
+// notation, so formulas written differently but typeset alike compare
+// equal.
+//
+// In math mode, the canonical notation:
+//   - spells each symbol with a single macro, e.g. \le and ≤ as \leq,
+//     or \to as \rightarrow, using the aliases of the Unicode symbol table;
+//   - spells the variants of macros with their base macro, e.g. \dfrac as
+//     \frac or \lbrace as \{;
+//   - encloses all sub- and superscripts in braces, e.g. x^2 as x^{2}, and
+//     places subscripts before superscripts;
+//   - encloses all macro arguments in braces, e.g. \frac12 as \frac{1}{2};
+//   - writes primes as such, e.g. f^\prime as f';
+//   - removes redundant braces, e.g. {a}+{{b}} as a+b;
+//   - replaces \left and \right delimiters with plain ones;
+//   - removes math spaces, e.g. \, or \quad.
+//
+// Text outside of math formulas is left untouched.
+type Canonicalizer struct {
+	Mode CanonMode // canonicalization mode
+
+	// Math reports whether the nodes are in math mode, e.g. when they were
+	// parsed in math mode.
+	// Nodes enclosed in a MathExpr are always canonicalized in math mode.
+	Math bool
+}
+
+// Canonicalize returns a copy of node in canonical notation, with the
+// default canonicalizer configuration.
+func Canonicalize(node Node) Node {
+	return new(Canonicalizer).Canonicalize(node)
+}
+
+// Canonicalize returns a copy of node in canonical notation.
+// node is left unmodified.
+func (cfg *Canonicalizer) Canonicalize(node Node) Node {
+	c := canonicalizer{cfg: cfg, math: cfg.Math}
+	return c.node(node)
+}
+
+// Equal reports whether the nodes a and b are equal once canonicalized
+// with the configuration opts, e.g. whether x^{2}\le{y} and x^2\leq y are
+// written the same way up to notation.
+// A nil opts canonicalizes with the default configuration.
+//
+// Positions are ignored, as well as the delimiters of math formulas, e.g.
+// $x$ and \(x\) are equal.
+func Equal(a, b Node, opts *Canonicalizer) bool {
+	if opts == nil {
+		opts = new(Canonicalizer)
+	}
+	return key(opts.Canonicalize(a)) == key(opts.Canonicalize(b))
+}
+
+// key returns a textual representation of the structure of node, ignoring
+// positions.
+func key(node Node) string {
+	o := new(strings.Builder)
+	Print(o, node)
+	return o.String()
+}
+
+// macroAliases maps the macros to the base macro they are a variant or an
+// alias of.
+var macroAliases = map[string]string{
+	`\dfrac`:  `\frac`,
+	`\tfrac`:  `\frac`,
+	`\ge`:     `\geq`,
+	`\le`:     `\leq`,
+	`\lbrace`: `\{`,
+	`\rbrace`: `\}`,
+}
+
+// symbolAliases maps the names of the TeX math symbols to the canonical
+// name of their Unicode character, e.g. \ne to \neq.
+// The canonical name is the one known to the symbol tables, or else the
+// shortest one.
+var symbolAliases = func() map[string]string {
+	names := make(map[rune][]string)
+	for _, name := range tex2unicode.Symbols() {
+		r := tex2unicode.Index(`\`+name, true)
+		if _, ok := tex2unicode.Name(r); !ok {
+			// ASCII and Latin characters, and combining marks, e.g. the
+			// \hat accent.
+			continue
+		}
+		names[r] = append(names[r], `\`+name)
+	}
+
+	known := func(name string) bool {
+		return symbols.IsSpaced(name) ||
+			symbols.LeftDelim.Has(name) || symbols.RightDelim.Has(name)
+	}
+	db := make(map[string]string)
+	for _, names := range names {
+		if len(names) < 2 {
+			continue
+		}
+		sort.Slice(names, func(i, j int) bool {
+			ni, nj := names[i], names[j]
+			switch {
+			case known(ni) != known(nj):
+				return known(ni)
+			case len(ni) != len(nj):
+				return len(ni) < len(nj)
+			}
+			return ni < nj
+		})
+		for _, name := range names[1:] {
+			db[name] = names[0]
+		}
+	}
+	return db
+}()
+
+// spaceMacros lists the macros inserting spaces in math mode.
+var spaceMacros = map[string]bool{
+	`\ `:       true,
+	`\,`:       true,
+	`\:`:       true,
+	`\;`:       true,
+	`\!`:       true,
+	`\quad`:    true,
+	`\qquad`:   true,
+	`\hspace`:  true,
+	`\hspace*`: true,
+}
+
+// scopedMacros lists the macros whose effect extends to the end of the
+// enclosing group, making its braces significant.
+var scopedMacros = map[string]bool{
+	// generalized fractions
+	`\over`:   true,
+	`\atop`:   true,
+	`\above`:  true,
+	`\choose`: true,
+	`\brace`:  true,
+	`\brack`:  true,
+
+	// styles
+	`\displaystyle`:      true,
+	`\textstyle`:         true,
+	`\scriptstyle`:       true,
+	`\scriptscriptstyle`: true,
+}
+
+type canonicalizer struct {
+	cfg  *Canonicalizer
+	math bool // whether the current node is in math mode
+}
+
+func (c *canonicalizer) node(node Node) Node {
+	switch node := node.(type) {
+	case nil:
+		return nil
+
+	case List:
+		return c.list(node)
+
+	case *Bad:
+		o := *node
+		return &o
+
+	case *Macro:
+		o := *node
+		o.Name = c.ident(node.Name)
+		if node.Expansion != nil {
+			o.Args = c.nodes(node.Args)
+			if o.Expansion = c.list(node.Expansion); o.Expansion == nil {
+				o.Expansion = List{}
+			}
+			return &o
+		}
+		if !c.math || o.Name == nil {
+			o.Args = c.nodes(node.Args)
+			return &o
+		}
+		o.Name.Name = macroName(node.Name.Name, len(node.Args))
+		if isSymbolMacro(o.Name.Name) && len(node.Args) == 0 {
+			// \{ and \} are parsed as symbols.
+			return &Symbol{SymPos: node.Name.NamePos, Text: o.Name.Name}
+		}
+		if isTextMacro(node.Name.Name) {
+			// the arguments of \text and friends are typeset in text mode.
+			c.math = false
+			defer func() { c.math = true }()
+		}
+		o.Args = c.nodes(bracedArgs(node.Args))
+		return &o
+
+	case *MacroDef:
+		o := *node
+		o.Cmd = c.ident(node.Cmd)
+		o.Name = c.ident(node.Name)
+		return &o
+
+	case *Arg:
+		o := *node
+		o.List = c.list(node.List)
+		return &o
+
+	case *OptArg:
+		o := *node
+		o.List = c.list(node.List)
+		return &o
+
+	case *Ident:
+		return c.ident(node)
+
+	case *Env:
+		return c.env(node)
+
+	case *Table:
+		o := *node
+		o.Env = c.env(node.Env)
+		for i, arg := range node.Env.Args {
+			if arg == node.ColSpec {
+				o.ColSpec = o.Env.Args[i].(*Arg)
+			}
+		}
+		o.Rows = make([][]Cell, len(node.Rows))
+		for i, row := range node.Rows {
+			o.Rows[i] = make([]Cell, len(row))
+			for j, cell := range row {
+				cell.Rules = c.nodes(cell.Rules)
+				cell.List = c.list(cell.List)
+				if cell.Space != nil {
+					cell.Space = c.node(cell.Space).(*OptArg)
+				}
+				o.Rows[i][j] = cell
+			}
+		}
+		return &o
+
+	case *Delimited:
+		o := *node
+		o.Left = c.node(node.Left).(*Delim)
+		o.Right = c.node(node.Right).(*Delim)
+		o.Body = c.list(node.Body)
+		return &o
+
+	case *Delim:
+		o := *node
+		o.Text = macroName(node.Text, 0)
+		return &o
+
+	case *Group:
+		o := *node
+		o.List = c.list(node.List)
+		return &o
+
+	case *MathExpr:
+		math := c.math
+		c.math = true
+		defer func() { c.math = math }()
+		o := *node
+		o.List = c.list(node.List)
+		return &o
+
+	case *Paragraph:
+		o := *node
+		o.List = c.list(node.List)
+		return &o
+
+	case *Word:
+		o := *node
+		return &o
+
+	case *Literal:
+		o := *node
+		return &o
+
+	case *Dimen:
+		return c.dimen(node)
+
+	case *KeyVal:
+		o := *node
+		o.Pairs = make([]*Pair, len(node.Pairs))
+		for i, pair := range node.Pairs {
+			p := *pair
+			if pair.Value != nil {
+				p.Value = c.list(pair.Value)
+			}
+			o.Pairs[i] = &p
+		}
+		return &o
+
+	case *Symbol:
+		o := *node
+		return &o
+
+	case *Comment:
+		o := *node
+		return &o
+
+	case *Verbatim:
+		o := *node
+		return &o
+
+	case *Sub:
+		o := *node
+		o.Node = c.script(node.Node)
+		return &o
+
+	case *Sup:
+		o := *node
+		o.Node = c.script(node.Node)
+		if list, ok := o.Node.(List); ok && c.math {
+			o.Node = primes(list)
+		}
+		return &o
+	}
+	panic("ast: unknown node type")
+}
+
+func (c *canonicalizer) nodes(nodes List) List {
+	if nodes == nil {
+		return nil
+	}
+	o := make(List, len(nodes))
+	for i, node := range nodes {
+		o[i] = c.node(node)
+	}
+	return o
+}
+
+func (c *canonicalizer) ident(id *Ident) *Ident {
+	if id == nil {
+		return nil
+	}
+	o := *id
+	return &o
+}
+
+func (c *canonicalizer) env(env *Env) *Env {
+	o := *env
+	o.Name = c.ident(env.Name)
+	o.Args = c.nodes(env.Args)
+	o.Body = c.list(env.Body)
+	return &o
+}
+
+func (c *canonicalizer) dimen(dim *Dimen) *Dimen {
+	if dim == nil {
+		return nil
+	}
+	o := *dim
+	o.Plus = c.dimen(dim.Plus)
+	o.Minus = c.dimen(dim.Minus)
+	return &o
+}
+
+// macroName returns the canonical name of the math macro name, taking
+// nargs arguments.
+func macroName(name string, nargs int) string {
+	if alias, ok := macroAliases[name]; ok {
+		name = alias
+	}
+	if alias, ok := symbolAliases[name]; ok && nargs == 0 {
+		name = alias
+	}
+	return name
+}
+
+// isSymbolMacro reports whether the macro name is parsed as a symbol, e.g.
+// \{.
+func isSymbolMacro(name string) bool {
+	return name == `\{` || name == `\}`
+}
+
+// bracedArgs returns the arguments of a macro, with its bare single-node
+// arguments enclosed in braces, e.g. the arguments of \frac12 as those of
+// \frac{1}{2}.
+func bracedArgs(list List) List {
+	var o List
+	for i, node := range list {
+		switch node.(type) {
+		case *Arg, *OptArg, *Verbatim:
+			if o != nil {
+				o = append(o, node)
+			}
+			continue
+		}
+		if o == nil {
+			o = append(make(List, 0, len(list)), list[:i]...)
+		}
+		o = append(o, &Arg{Lbrace: node.Pos(), List: List{node}, Rbrace: node.End() - 1})
+	}
+	if o == nil {
+		return list
+	}
+	return o
+}
+
+// isScoped reports whether the effect of the macro name extends to the end
+// of the enclosing group, e.g. \over or \rm.
+func isScoped(name string) bool {
+	return scopedMacros[name] || symbols.FontNames.Has(strings.TrimPrefix(name, `\`))
+}
+
+// isTextMacro reports whether the math macro name typesets its arguments in
+// text mode.
+func isTextMacro(name string) bool {
+	return strings.HasPrefix(name, `\text`) || name == `\mbox`
+}
+
+// script returns the canonical nodes of a sub- or superscript, enclosed in
+// a list in math mode.
+func (c *canonicalizer) script(node Node) Node {
+	if !c.math {
+		return c.node(node)
+	}
+	list, ok := node.(List)
+	if !ok {
+		list = List{node}
+	}
+	list = c.list(list)
+	if list == nil {
+		list = List{}
+	}
+	return list
+}
+
+// primes returns the superscript list, with its leading \prime macros
+// written as primes.
+func primes(list List) List {
+	for i, node := range list {
+		m, ok := node.(*Macro)
+		if !ok || macroIdent(m) != `\prime` {
+			break
+		}
+		list[i] = &Symbol{SymPos: m.Pos(), Text: "'"}
+	}
+	return list
+}
+
+// list returns the canonical nodes of list.
+func (c *canonicalizer) list(list List) List {
+	if !c.math {
+		return c.nodes(list)
+	}
+	if list == nil {
+		return nil
+	}
+	list = expand(list)
+
+	o := make(List, 0, len(list))
+	for i, node := range list {
+		switch node := node.(type) {
+		case *Symbol:
+			if isMathSpace(node) {
+				continue
+			}
+			o = appendText(o, node.SymPos, node.Text, false)
+
+		case *Macro:
+			if spaceMacros[macroIdent(node)] {
+				continue
+			}
+			o = append(o, c.node(node))
+
+		case *Word:
+			o = appendText(o, node.WordPos, node.Text, true)
+
+		case *Group:
+			o = c.appendGroup(o, node, isScriptNode(next(list, i)))
+
+		case *Delimited:
+			o = c.appendDelimited(o, node)
+
+		case *Sub:
+			o = append(o, c.node(node))
+			// subscripts come before superscripts.
+			if n := len(o); n > 1 {
+				if _, ok := o[n-2].(*Sup); ok {
+					o[n-2], o[n-1] = o[n-1], o[n-2]
+				}
+			}
+
+		default:
+			o = append(o, c.node(node))
+		}
+	}
+	o = merge(o)
+
+	if c.cfg.Mode&Commutative != 0 {
+		o = commute(o)
+	}
+	return o
+}
+
+// expand returns list, with the calls of user-defined macros replaced
+// with their expansion.
+func expand(list List) List {
+	var o List
+	for i, node := range list {
+		m, ok := node.(*Macro)
+		if !ok || m.Expansion == nil {
+			if o != nil {
+				o = append(o, node)
+			}
+			continue
+		}
+		if o == nil {
+			o = append(make(List, 0, len(list)), list[:i]...)
+		}
+		o = append(o, expand(m.Expansion)...)
+	}
+	if o == nil {
+		return list
+	}
+	return o
+}
+
+// appendText appends the symbol, or the word, text at pos to list, with its
+// Unicode math characters spelled as macros, e.g. α as \alpha.
+func appendText(list List, pos token.Pos, text string, word bool) List {
+	beg := 0
+	flush := func(end int) {
+		if beg == end {
+			return
+		}
+		switch {
+		case word:
+			list = append(list, &Word{WordPos: pos + token.Pos(beg), Text: text[beg:end]})
+		default:
+			list = append(list, &Symbol{SymPos: pos + token.Pos(beg), Text: text[beg:end]})
+		}
+	}
+	for i, r := range text {
+		name, ok := tex2unicode.Name(r)
+		if !ok {
+			continue
+		}
+		flush(i)
+		list = append(list, &Macro{Name: &Ident{
+			NamePos: pos + token.Pos(i),
+			Name:    macroName(`\`+name, 0),
+		}})
+		beg = i + utf8.RuneLen(r)
+	}
+	flush(len(text))
+	return list
+}
+
+// appendGroup appends the group to list, removing its braces when they are
+// redundant.
+// script reports whether the group is followed by a sub- or superscript.
+func (c *canonicalizer) appendGroup(list List, group *Group, script bool) List {
+	body := c.list(group.List)
+	for _, node := range body {
+		if m, ok := node.(*Macro); ok && isScoped(macroIdent(m)) {
+			o := *group
+			o.List = body
+			return append(list, &o)
+		}
+	}
+	if !script || len(body) == 1 && isAtom(body[0]) {
+		return append(list, body...)
+	}
+	// the script applies to the whole group, e.g. {}^{14}C or {ab}^2.
+	o := *group
+	o.List = body
+	return append(list, &o)
+}
+
+// appendDelimited appends the \left and \right delimited nodes to list,
+// with plain delimiters.
+func (c *canonicalizer) appendDelimited(list List, node *Delimited) List {
+	if len(node.Middle()) > 0 {
+		return append(list, c.node(node))
+	}
+	delim := func(d *Delim) {
+		text := macroName(d.Text, 0)
+		switch {
+		case d.IsNull():
+			// no-op.
+		case strings.HasPrefix(text, `\`) && text != `\{` && text != `\}`:
+			list = append(list, &Macro{Name: &Ident{
+				NamePos: d.DelimPos,
+				Name:    text,
+			}})
+		default:
+			list = appendText(list, d.DelimPos, text, false)
+		}
+	}
+	delim(node.Left)
+	list = append(list, c.list(node.Body)...)
+	delim(node.Right)
+	return list
+}
+
+// next returns the node following the i-th node of list, ignoring spaces,
+// or nil.
+func next(list List, i int) Node {
+	for _, node := range list[i+1:] {
+		switch node := node.(type) {
+		case *Symbol:
+			if isMathSpace(node) {
+				continue
+			}
+		case *Macro:
+			if spaceMacros[macroIdent(node)] {
+				continue
+			}
+		}
+		return node
+	}
+	return nil
+}
+
+// isAtom reports whether node is typeset as a single atom, which a sub- or
+// superscript may apply to.
+func isAtom(node Node) bool {
+	switch node := node.(type) {
+	case *Sub, *Sup:
+		return false
+	case *Word:
+		return utf8.RuneCountInString(node.Text) == 1
+	case *Literal:
+		return utf8.RuneCountInString(node.Text) == 1
+	}
+	return true
+}
+
+func isScriptNode(node Node) bool {
+	switch node.(type) {
+	case *Sub, *Sup:
+		return true
+	}
+	return false
+}
+
+// isMathSpace reports whether the symbol is a space in math mode.
+func isMathSpace(sym *Symbol) bool {
+	return strings.Trim(sym.Text, " \t\n~") == ""
+}
+
+// merge merges the adjacent words and the adjacent literals of list, as
+// spaces are ignored in math mode.
+func merge(list List) List {
+	o := list[:0]
+	for _, node := range list {
+		if len(o) == 0 {
+			o = append(o, node)
+			continue
+		}
+		switch prev := o[len(o)-1].(type) {
+		case *Word:
+			if w, ok := node.(*Word); ok {
+				o[len(o)-1] = &Word{WordPos: prev.WordPos, Text: prev.Text + w.Text}
+				continue
+			}
+		case *Literal:
+			if lit, ok := node.(*Literal); ok {
+				o[len(o)-1] = &Literal{LitPos: prev.LitPos, Text: prev.Text + lit.Text}
+				continue
+			}
+		}
+		o = append(o, node)
+	}
+	return o
+}
+
+// commute sorts the operands of the commutative operators and relations of
+// the canonical math list.
+func commute(list List) List {
+	list = brackets(list)
+	sides, rels, ok := split(list, isSeparator)
+	if !ok {
+		return list
+	}
+	for i, side := range sides {
+		sides[i] = sum(side)
+	}
+	if isSymmetric(sides, rels) {
+		sortLists(sides)
+	}
+	return join(sides, rels)
+}
+
+// brackets returns list with the contents of its outermost brackets
+// commuted, e.g. (b+a) as (a+b).
+func brackets(list List) List {
+	var (
+		o     = make(List, 0, len(list))
+		stack []string // open brackets
+		beg   = 0      // start of the contents of the outermost brackets
+	)
+	for i, node := range list {
+		name := nodeName(node)
+		open, closed := leftDelims[name], rightDelims[name]
+		if ambiDelims[name] {
+			n := len(stack)
+			open, closed = n == 0 || stack[n-1] != name, n > 0 && stack[n-1] == name
+		}
+		switch {
+		case open:
+			if len(stack) == 0 {
+				o = append(o, node)
+				beg = i + 1
+			}
+			stack = append(stack, name)
+		case closed:
+			if len(stack) == 0 {
+				return list
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				o = append(o, commute(list[beg:i:i])...)
+				o = append(o, node)
+			}
+		case len(stack) == 0:
+			o = append(o, node)
+		}
+	}
+	if len(stack) > 0 {
+		return list
+	}
+	return o
+}
+
+// sum returns the list with its terms sorted.
+// The sign of a term is kept with the term, e.g. b-a is sorted as -a+b.
+func sum(list List) List {
+	terms, ops, ok := split(list, isSign)
+	if !ok || len(ops) == 0 {
+		return product(list)
+	}
+
+	signs := append(List{nil}, ops...) // sign of each term, if any
+	if len(terms[0]) == 0 {
+		// leading sign.
+		terms, signs = terms[1:], signs[1:]
+	}
+	for i, term := range terms {
+		if len(term) == 0 || isOperator(term[len(term)-1]) {
+			// sign of the next term, e.g. a\cdot-b.
+			return list
+		}
+		terms[i] = product(term)
+	}
+
+	idx := make([]int, len(terms))
+	for i := range idx {
+		idx[i] = i
+	}
+	keys := make([]string, len(terms))
+	for i, term := range terms {
+		keys[i] = key(term)
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return keys[idx[i]] < keys[idx[j]] })
+
+	o := make(List, 0, len(list))
+	for i, j := range idx {
+		sign := signs[j]
+		switch {
+		case sign == nil && i > 0:
+			sign = &Symbol{SymPos: terms[j].Pos(), Text: "+"}
+		case sign != nil && i == 0 && nodeName(sign) == "+":
+			sign = nil
+		}
+		if sign != nil {
+			o = append(o, sign)
+		}
+		o = append(o, terms[j]...)
+	}
+	return o
+}
+
+// product returns the list with the factors of its explicit products
+// sorted.
+func product(list List) List {
+	factors, ops, ok := split(list, isProduct)
+	if !ok || len(ops) == 0 {
+		return list
+	}
+	for _, factor := range factors {
+		if len(factor) == 0 {
+			return list
+		}
+	}
+	sortLists(factors)
+	return join(factors, ops)
+}
+
+// split splits list into the operands separated by the nodes satisfying
+// sep, outside of brackets.
+// split reports whether the brackets of list are balanced.
+func split(list List, sep func(Node) bool) (operands []List, ops List, ok bool) {
+	var (
+		stack []string // open brackets
+		beg   = 0
+	)
+	for i, node := range list {
+		name := nodeName(node)
+		switch {
+		case ambiDelims[name]:
+			if n := len(stack); n > 0 && stack[n-1] == name {
+				stack = stack[:n-1]
+				continue
+			}
+			stack = append(stack, name)
+		case leftDelims[name]:
+			stack = append(stack, name)
+		case rightDelims[name]:
+			if len(stack) == 0 {
+				return nil, nil, false
+			}
+			stack = stack[:len(stack)-1]
+		case len(stack) == 0 && sep(node):
+			operands = append(operands, list[beg:i:i])
+			ops = append(ops, node)
+			beg = i + 1
+		}
+	}
+	if len(stack) > 0 {
+		return nil, nil, false
+	}
+	operands = append(operands, list[beg:len(list):len(list)])
+	return operands, ops, true
+}
+
+// join joins the operands with the operators ops.
+func join(operands []List, ops List) List {
+	o := append(List{}, operands[0]...)
+	for i, op := range ops {
+		o = append(o, op)
+		o = append(o, operands[i+1]...)
+	}
+	return o
+}
+
+// sortLists sorts the lists by their structure.
+func sortLists(lists []List) {
+	keys := make([]string, len(lists))
+	for i, list := range lists {
+		keys[i] = key(list)
+	}
+	sort.Stable(byKey{keys, lists})
+}
+
+type byKey struct {
+	keys  []string
+	lists []List
+}
+
+func (p byKey) Len() int           { return len(p.keys) }
+func (p byKey) Less(i, j int) bool { return p.keys[i] < p.keys[j] }
+func (p byKey) Swap(i, j int) {
+	p.keys[i], p.keys[j] = p.keys[j], p.keys[i]
+	p.lists[i], p.lists[j] = p.lists[j], p.lists[i]
+}
+
+// macroIdent returns the name of the macro m, or "" for a macro without
+// identifier.
+func macroIdent(m *Macro) string {
+	if m.Name == nil {
+		return ""
+	}
+	return m.Name.Name
+}
+
+// nodeName returns the text of a symbol, or the name of a macro without
+// arguments.
+func nodeName(node Node) string {
+	switch node := node.(type) {
+	case *Symbol:
+		return node.Text
+	case *Macro:
+		if len(node.Args) == 0 {
+			return macroIdent(node)
+		}
+	}
+	return ""
+}
+
+func isSeparator(node Node) bool {
+	name := nodeName(node)
+	return symbols.RelationSymbols.Has(name) || name == "," || name == ";"
+}
+
+func isSign(node Node) bool {
+	name := nodeName(node)
+	return name == "+" || name == "-"
+}
+
+func isProduct(node Node) bool {
+	switch nodeName(node) {
+	case `\cdot`, `\times`, "*":
+		return true
+	}
+	return false
+}
+
+// symmetricRelations lists the relations whose sides may be swapped.
+var symmetricRelations = map[string]bool{
+	"=":         true,
+	`\approx`:   true,
+	`\asymp`:    true,
+	`\cong`:     true,
+	`\doteq`:    true,
+	`\equiv`:    true,
+	`\neq`:      true,
+	`\parallel`: true,
+	`\perp`:     true,
+	`\sim`:      true,
+	`\simeq`:    true,
+}
+
+// isSymmetric reports whether the sides are related by the same symmetric
+// relation, e.g. a=b=c.
+func isSymmetric(sides []List, rels List) bool {
+	if len(rels) == 0 {
+		return false
+	}
+	for _, side := range sides {
+		if len(side) == 0 {
+			return false
+		}
+	}
+	for _, rel := range rels {
+		if name := nodeName(rel); !symmetricRelations[name] || name != nodeName(rels[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	leftDelims = map[string]bool{
+		"(": true, "[": true, `\{`: true, `\lbrace`: true,
+		`\langle`: true, `\lceil`: true, `\lfloor`: true,
+	}
+	rightDelims = map[string]bool{
+		")": true, "]": true, `\}`: true, `\rbrace`: true,
+		`\rangle`: true, `\rceil`: true, `\rfloor`: true,
+	}
+	ambiDelims = map[string]bool{
+		"|": true, `\|`: true, `\vert`: true, `\Vert`: true,
+	}
+)
@@ -0,0 +1,164 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+)
+
+func TestCanonicalize(t *testing.T) {
+	for _, tc := range []struct {
+		mode  ast.CanonMode
+		input string
+		want  string
+	}{
+		{
+			input: `$x^2 + y_i^{n} + z^{{3}}_k$`,
+			want:  `$x^{2}+y_{i}^{n}+z_{k}^{3}$`,
+		},
+		{
+			input: `$a \le b \ne \dfrac{1}{2} \to c$`,
+			want:  `$a\leq b\neq\frac{1}{2}\rightarrow c$`,
+		},
+		{
+			input: `$α ≤ β$`,
+			want:  `$\alpha\leq\beta$`,
+		},
+		{
+			input: `${a}+{{b c}} = {ab}^2 + {a}^2 + {}^{14}C$`,
+			want:  `$a+bc={ab}^{2}+a^{2}+{}^{14}C$`,
+		},
+		{
+			input: `${a \over b} + {\rm d}x$`,
+			want:  `${a\over b}+{\rm d}x$`,
+		},
+		{
+			input: `$f^\prime(x) + g'(x)$`,
+			want:  `$f'(x)+g'(x)$`,
+		},
+		{
+			input: `$\left( a \, b \right) \quad \left\{ x \middle| y \right\}$`,
+			want:  `$(ab)\left\{x\middle|y\right\}$`,
+		},
+		{
+			input: `$\lbrace x \rbrace = \{x\} = \left\lbrace x \right\rbrace = \left\lbrace x \middle| y \right\}$`,
+			want:  `$\{x\}=\{x\}=\{x\}=\left\{x\middle|y\right\}$`,
+		},
+		{
+			input: `$\textbf{a {b} c} {d}$ and {text}`,
+			want:  `$\textbf{a{b}c}d$ and {text}`,
+		},
+		{
+			mode:  ast.Commutative,
+			input: `$c = b + a$ and $y - x + 1$ and $-b + a$`,
+			want:  `$a+b=c$ and $1-x+y$ and $a-b$`,
+		},
+		{
+			mode:  ast.Commutative,
+			input: `$z \cdot y \cdot x + f(b + a) \le |b - a| \cdot 2$`,
+			want:  `$f(a+b)+x\cdot y\cdot z\leq2\cdot|-a+b|$`,
+		},
+		{
+			// unbalanced brackets and dangling operators are left as is.
+			mode:  ast.Commutative,
+			input: `$[b + a) , d \cdot - c + b$`,
+			want:  `$[a+b),d\cdot-c+b$`,
+		},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			p := newParser()
+			node, err := p.ParseExpr(tc.input)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.input, err)
+			}
+			orig := sprint(node)
+
+			got := (&ast.Canonicalizer{Mode: tc.mode}).Canonicalize(node)
+			o := new(strings.Builder)
+			err = ast.Fprint(o, got)
+			if err != nil {
+				t.Fatalf("could not print ast: %+v", err)
+			}
+			if got, want := o.String(), tc.want; got != want {
+				t.Fatalf("invalid canonical form:\ngot= %s\nwant=%s", got, want)
+			}
+
+			if got := sprint(node); got != orig {
+				t.Fatalf("input ast was modified:\ngot= %s\nwant=%s", got, orig)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeNilName(t *testing.T) {
+	node := &ast.MathExpr{List: ast.List{&ast.Macro{Args: ast.List{&ast.Word{Text: "x"}}}}}
+	got := ast.Canonicalize(node).(*ast.MathExpr)
+	if m := got.List[0].(*ast.Macro); m.Name != nil || len(m.Args) != 1 {
+		t.Fatalf("invalid canonical macro: %#v", m)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		opts *ast.Canonicalizer
+		want bool
+	}{
+		{a: `x^{2}`, b: `x^2`, want: true},
+		{a: `x^{2}`, b: `x^3`, want: false},
+		{a: `a \le b`, b: `a\leq b`, want: true},
+		{a: `a \le b`, b: `a \ge b`, want: false},
+		{a: `\dfrac{1}{x}`, b: `\frac{1}{x}`, want: true},
+		{a: `\frac12`, b: `\frac{1}{2}`, want: true},
+		{a: `\dfrac12`, b: `\frac{1}{2}`, want: true},
+		{a: `\frac12`, b: `\frac{1}{3}`, want: false},
+		{a: `\sqrt x`, b: `\sqrt{x}`, want: true},
+		{a: `\sqrt[3]x`, b: `\sqrt[3]{x}`, want: true},
+		{a: `\mathbf x`, b: `\mathbf{x}`, want: true},
+		{a: `\frac\alpha2`, b: `\frac{α}{2}`, want: true},
+		{a: `\lbrace x \rbrace`, b: `\{x\}`, want: true},
+		{a: `\left\lbrace x \right\rbrace`, b: `\{x\}`, want: true},
+		{a: `{{x}}+{y}`, b: `x + y`, want: true},
+		{a: `{x+y}^2`, b: `x+y^2`, want: false},
+		{a: `x_i^2`, b: `x^2_i`, want: true},
+		{a: `y+x`, b: `x+y`, want: false},
+		{a: `y+x`, b: `x+y`, opts: &ast.Canonicalizer{Mode: ast.Commutative}, want: true},
+		{a: `x-y`, b: `y-x`, opts: &ast.Canonicalizer{Mode: ast.Commutative}, want: false},
+		{a: `yx`, b: `xy`, opts: &ast.Canonicalizer{Mode: ast.Commutative}, want: false},
+		{a: `2 = \sqrt{b \cdot a}`, b: `\sqrt{a\cdot b}=2`, opts: &ast.Canonicalizer{Mode: ast.Commutative}, want: true},
+		{a: `a < b`, b: `b < a`, opts: &ast.Canonicalizer{Mode: ast.Commutative}, want: false},
+	} {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			p := newParser()
+			a, err := p.ParseExpr("$" + tc.a + "$")
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.a, err)
+			}
+			b, err := p.ParseExpr(`\(` + tc.b + `\)`)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.b, err)
+			}
+			if got, want := ast.Equal(a, b, tc.opts), tc.want; got != want {
+				t.Fatalf("invalid equality: got=%v, want=%v", got, want)
+			}
+		})
+	}
+}
+
+func newParser() *latex.Parser {
+	p := latex.NewParser()
+	p.RegisterMacro(`\over`, "")
+	return p
+}
+
+func sprint(node ast.Node) string {
+	o := new(strings.Builder)
+	ast.Print(o, node)
+	return o.String()
+}
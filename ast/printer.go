@@ -0,0 +1,512 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-latex/latex/internal/symbols"
+	"github.com/go-latex/latex/token"
+)
+
+// PrintMode controls the formatting of the LaTeX source printed by Fprint.
+type PrintMode uint
+
+const (
+	// NormalizeBraces encloses all sub- and superscript arguments in
+	// braces, e.g. x^2 is printed as x^{2}.
+	NormalizeBraces PrintMode = 1 << iota
+
+	// SpaceOperators surrounds binary operators and relations with spaces
+	// in math mode, e.g. a+b=c is printed as a + b = c.
+	SpaceOperators
+)
+
+// Printer prints an AST as LaTeX source.
+type Printer struct {
+	Mode PrintMode // formatting mode
+
+	// Math reports whether the printed nodes are in math mode, e.g. when
+	// they were parsed in math mode.
+	// Nodes enclosed in a MathExpr are always printed in math mode.
+	Math bool
+
+	// Src, if set, is the source the printed nodes were parsed from, and
+	// Fset the file set holding their positions, or nil if they were
+	// parsed with ParseExpr.
+	// The line breaks and the indentation skipped by the parser, e.g. in
+	// math mode or after comments, are then printed as in the source.
+	Src  []byte
+	Fset *token.FileSet
+}
+
+// Fprint prints the LaTeX source of node to w, with the default printer
+// configuration.
+//
+// Parsing the printed source yields an AST structurally identical to node.
+func Fprint(w io.Writer, node Node) error {
+	return new(Printer).Fprint(w, node)
+}
+
+// Fprint prints the LaTeX source of node to w.
+//
+// Parsing the printed source yields an AST structurally identical to node,
+// modulo the normalizations requested by the printer mode.
+// Fprint returns an error if node contains *Bad nodes.
+func (cfg *Printer) Fprint(w io.Writer, node Node) error {
+	p := printer{
+		cfg:  cfg,
+		math: cfg.Math,
+		pos:  -1,
+	}
+	p.node(node)
+	if p.err != nil {
+		return p.err
+	}
+	p.endLine()
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+type printer struct {
+	cfg  *Printer
+	buf  bytes.Buffer
+	err  error
+	math bool      // whether the current node is in math mode
+	last rune      // last printed character
+	prev string    // last printed text
+	pos  token.Pos // position following the last printed node
+	eol  bool      // whether the current line must be ended before printing further
+}
+
+// write prints s, separating it from the previously printed text when
+// they would otherwise be scanned as a single token.
+func (p *printer) write(s string) {
+	if s == "" {
+		return
+	}
+	p.endLine()
+	p.buf.WriteString(p.sep(s))
+	p.raw(s)
+}
+
+// raw prints s as is.
+func (p *printer) raw(s string) {
+	if s == "" {
+		return
+	}
+	p.endLine()
+	p.buf.WriteString(s)
+	p.last, _ = utf8.DecodeLastRuneInString(s)
+	p.prev = s
+}
+
+// endLine ends the current line, if requested.
+func (p *printer) endLine() {
+	if !p.eol {
+		return
+	}
+	p.eol = false
+	p.raw("\n")
+}
+
+// sep returns the text separating s from the previously printed text, when
+// they would otherwise be scanned as a single token.
+func (p *printer) sep(s string) string {
+	next, _ := utf8.DecodeRuneInString(s)
+	switch {
+	case unicode.IsLetter(p.last):
+		if !unicode.IsLetter(next) {
+			return ""
+		}
+		if strings.HasPrefix(p.prev, `\`) {
+			// the space ending a control word is skipped in math mode
+			// and before macro arguments.
+			return " "
+		}
+	case unicode.IsDigit(p.last):
+		// numbers hold at most one decimal point.
+		if !unicode.IsDigit(next) && (next != '.' || strings.Contains(p.prev, ".")) {
+			return ""
+		}
+	case p.last == '.':
+		if !unicode.IsDigit(next) {
+			return ""
+		}
+	case p.last == '$':
+		// $a$$$b$$ is not scanned as two formulas, while $$a$$$$b$$ is.
+		if p.prev != "$" || !strings.HasPrefix(s, "$$") {
+			return ""
+		}
+	default:
+		return ""
+	}
+
+	// spaces are skipped in math mode, while an empty group separates
+	// the tokens without typesetting anything in text mode.
+	if p.math {
+		return " "
+	}
+	return "{}"
+}
+
+// space prints the line break and the indentation preceding the position
+// pos in the source, when they were skipped by the parser.
+func (p *printer) space(pos token.Pos) {
+	var (
+		i = p.offset(p.pos)
+		j = p.offset(pos)
+	)
+	if i < 0 || j < i {
+		return
+	}
+	gap := string(p.cfg.Src[i:j])
+	if strings.TrimSpace(gap) != "" || strings.Count(gap, "\n") != 1 {
+		// blank lines are paragraph breaks, printed as such.
+		return
+	}
+	if p.prev == " " {
+		// drop the trailing space of an operator.
+		p.buf.Truncate(p.buf.Len() - 1)
+	}
+	p.eol = true
+	p.endLine()
+	p.raw(gap[strings.Index(gap, "\n")+1:])
+}
+
+// offset returns the offset of the position pos in the source, or -1.
+func (p *printer) offset(pos token.Pos) int {
+	if p.cfg.Src == nil || pos < 0 {
+		return -1
+	}
+	off := int(pos)
+	if p.cfg.Fset != nil {
+		f := p.cfg.Fset.File(pos)
+		if f == nil {
+			return -1
+		}
+		off -= f.Base()
+	}
+	if off > len(p.cfg.Src) {
+		return -1
+	}
+	return off
+}
+
+func (p *printer) node(node Node) {
+	if p.err != nil {
+		return
+	}
+	if _, ok := node.(List); !ok && node != nil {
+		p.space(node.Pos())
+		defer func() {
+			p.pos = node.End()
+		}()
+	}
+
+	switch node := node.(type) {
+	case List:
+		p.list(node)
+
+	case *Bad:
+		p.err = fmt.Errorf("ast: cannot print invalid node at position %d", node.Pos())
+
+	case *Paragraph:
+		p.list(node.List)
+
+	case *Macro:
+		p.write(node.Name.Name)
+		for _, arg := range node.Args {
+			p.node(arg)
+		}
+
+	case *MacroDef:
+		p.macroDef(node)
+
+	case *Arg:
+		p.write("{")
+		p.list(node.List)
+		p.write("}")
+
+	case *Delimited:
+		p.node(node.Left)
+		p.list(node.Body)
+		p.node(node.Right)
+
+	case *Delim:
+		p.write(node.Macro)
+		p.write(node.Text)
+
+	case *Group:
+		p.write("{")
+		p.list(node.List)
+		p.write("}")
+
+	case *OptArg:
+		p.write("[")
+		p.list(node.List)
+		p.write("]")
+
+	case *Ident:
+		p.write(node.Name)
+
+	case *Env:
+		p.begin(node)
+		p.list(node.Body)
+		p.end(node)
+
+	case *Table:
+		p.table(node)
+
+	case *MathExpr:
+		right, ok := mathDelims[node.Delim]
+		closing := node.Right
+		if name := strings.TrimPrefix(node.Delim, `\begin`); name != node.Delim {
+			// environments end after their \end{name} command.
+			right, ok = `\end`+name, true
+			closing -= token.Pos(len(right))
+		}
+		if !ok {
+			p.err = fmt.Errorf("ast: invalid math expression delimiter %q", node.Delim)
+			return
+		}
+		p.write(node.Delim)
+		p.pos = node.Left + token.Pos(len(node.Delim))
+		math := p.math
+		p.math = true
+		p.list(node.List)
+		p.space(closing)
+		p.math = math
+		p.write(right)
+
+	case *Word:
+		p.write(node.Text)
+
+	case *Literal:
+		p.write(node.Text)
+
+	case *Dimen:
+		p.dimen(node)
+
+	case *KeyVal:
+		for i, pair := range node.Pairs {
+			if i > 0 {
+				p.write(",")
+			}
+			p.write(pair.Key)
+			if pair.Value != nil {
+				p.write("=")
+				p.list(pair.Value)
+			}
+		}
+
+	case *Symbol:
+		p.write(node.Text)
+
+	case *Verbatim:
+		// the arguments of user-defined macros may be single letters,
+		// e.g. x in \sq x, that must be separated from the macro name.
+		p.write(node.Text)
+
+	case *Comment:
+		p.write(node.Text)
+		p.eol = true
+
+	case *Sub:
+		p.write("_")
+		p.script(node.Node)
+
+	case *Sup:
+		p.sup(node)
+
+	case nil:
+		p.err = fmt.Errorf("ast: cannot print nil node")
+
+	default:
+		p.err = fmt.Errorf("ast: unknown node %T", node)
+	}
+}
+
+var mathDelims = map[string]string{
+	"$":  "$",
+	"$$": "$$",
+	`\(`: `\)`,
+	`\[`: `\]`,
+}
+
+func (p *printer) list(list List) {
+	for i, node := range list {
+		if par, ok := node.(*Paragraph); ok && i > 0 {
+			p.parBreak(par)
+		}
+		if !p.spaced(list, i) {
+			p.node(node)
+			continue
+		}
+		p.raw(" ")
+		p.node(node)
+		if i+1 < len(list) {
+			p.raw(" ")
+		}
+	}
+}
+
+// parBreak prints the paragraph break preceding par.
+func (p *printer) parBreak(par *Paragraph) {
+	// blank lines swallow the whitespace following them.
+	if sym, ok := par.List[0].(*Symbol); ok && strings.HasPrefix(sym.Text, " ") {
+		p.write(`\par`)
+		return
+	}
+	p.raw("\n\n")
+}
+
+// spaced reports whether the i-th node of list should be surrounded by
+// spaces.
+func (p *printer) spaced(list List, i int) bool {
+	if !p.math || p.cfg.Mode&SpaceOperators == 0 {
+		return false
+	}
+	return i > 0 && isOperator(list[i]) && !isOperator(list[i-1])
+}
+
+// isOperator reports whether node is a binary operator or a relation.
+func isOperator(node Node) bool {
+	switch node := node.(type) {
+	case *Symbol:
+		return symbols.IsSpaced(node.Text)
+	case *Macro:
+		return len(node.Args) == 0 && symbols.IsSpaced(node.Name.Name)
+	}
+	return false
+}
+
+func (p *printer) table(tbl *Table) {
+	env := tbl.Env
+	p.begin(env)
+	for _, row := range tbl.Rows {
+		for _, cell := range row {
+			p.list(cell.Rules)
+			p.list(cell.List)
+			if cell.Sep == "" {
+				continue
+			}
+			p.space(cell.SepPos)
+			p.write(cell.Sep)
+			p.pos = cell.SepPos + token.Pos(len(cell.Sep))
+			if cell.Space != nil {
+				p.node(cell.Space)
+			}
+		}
+	}
+	p.end(env)
+}
+
+// begin prints the \begin{name} command of env, with its arguments.
+func (p *printer) begin(env *Env) {
+	p.write(`\begin{` + env.Name.Name + `}`)
+	p.pos = env.Name.End() + 1
+	for _, arg := range env.Args {
+		p.node(arg)
+	}
+}
+
+// end prints the \end{name} command of env.
+func (p *printer) end(env *Env) {
+	end := `\end{` + env.Name.Name + `}`
+	p.space(env.EndPos - token.Pos(len(end)))
+	p.write(end)
+}
+
+func (p *printer) dimen(dim *Dimen) {
+	p.write(dim.Value)
+	p.write(dim.Unit)
+	if dim.Plus != nil {
+		p.raw(" plus ")
+		p.dimen(dim.Plus)
+	}
+	if dim.Minus != nil {
+		p.raw(" minus ")
+		p.dimen(dim.Minus)
+	}
+}
+
+// sup prints the superscript node, with its primes.
+func (p *printer) sup(node *Sup) {
+	list, ok := node.Node.(List)
+	if !ok || len(list) == 0 || !isPrime(list[0]) {
+		p.write("^")
+		p.script(node.Node)
+		return
+	}
+	for len(list) > 0 && isPrime(list[0]) {
+		p.write("'")
+		list = list[1:]
+	}
+	switch len(list) {
+	case 0:
+		// only primes.
+	case 1:
+		p.write("^")
+		p.script(list[0])
+	default:
+		p.write("^")
+		p.script(list)
+	}
+}
+
+func isPrime(node Node) bool {
+	sym, ok := node.(*Symbol)
+	return ok && sym.Text == "'"
+}
+
+func (p *printer) script(node Node) {
+	switch node := node.(type) {
+	case List:
+		p.write("{")
+		p.list(node)
+		p.write("}")
+	default:
+		if p.cfg.Mode&NormalizeBraces == 0 {
+			p.node(node)
+			return
+		}
+		p.write("{")
+		p.node(node)
+		p.write("}")
+	}
+}
+
+func (p *printer) macroDef(def *MacroDef) {
+	p.write(def.Cmd.Name)
+	if def.Cmd.Name == `\def` {
+		p.write(def.Name.Name)
+		for i := 1; i <= def.NArgs; i++ {
+			p.raw(fmt.Sprintf("#%d", i))
+		}
+		p.write("{")
+		p.raw(def.Body)
+		p.write("}")
+		return
+	}
+
+	p.write("{" + def.Name.Name + "}")
+	if def.NArgs > 0 {
+		p.write(fmt.Sprintf("[%d]", def.NArgs))
+	}
+	if def.Optional {
+		p.write("[")
+		p.raw(def.Default)
+		p.write("]")
+	}
+	p.write("{")
+	p.raw(def.Body)
+	p.write("}")
+}
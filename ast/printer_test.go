@@ -0,0 +1,193 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFprint(t *testing.T) {
+	expr := &MathExpr{
+		Delim: "$",
+		List: List{
+			&Word{Text: "x"},
+			&Sup{Node: &Literal{Text: "2"}},
+			&Symbol{Text: "+"},
+			&Macro{Name: &Ident{Name: `\alpha`}},
+			&Word{Text: "y"},
+			&Sub{Node: List{&Word{Text: "i"}, &Word{Text: "j"}}},
+			&Symbol{Text: "="},
+			&Symbol{Text: "-"},
+			&Macro{
+				Name: &Ident{Name: `\sqrt`},
+				Args: List{
+					&OptArg{List: List{&Literal{Text: "3"}}},
+					&Arg{List: List{&Literal{Text: "1"}, &Literal{Text: "2"}}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		mode PrintMode
+		node Node
+		want string
+	}{
+		{
+			node: expr,
+			want: `$x^2+\alpha y_{i j}=-\sqrt[3]{1 2}$`,
+		},
+		{
+			mode: NormalizeBraces,
+			node: expr,
+			want: `$x^{2}+\alpha y_{i j}=-\sqrt[3]{1 2}$`,
+		},
+		{
+			mode: SpaceOperators,
+			node: expr,
+			want: `$x^2 + \alpha y_{i j} = -\sqrt[3]{1 2}$`,
+		},
+		{
+			node: List{
+				&Paragraph{List: List{&Word{Text: "a"}, &Word{Text: "b"}}},
+				&Paragraph{List: List{&Symbol{Text: " "}, &Word{Text: "c"}}},
+				&Paragraph{List: List{
+					&Macro{
+						Name: &Ident{Name: `\verb`},
+						Args: List{&Verbatim{Text: "|a%b{|"}},
+					},
+				}},
+			},
+			want: "a{}b\\par c\n\n\\verb|a%b{|",
+		},
+		{
+			node: &Env{
+				Name: &Ident{Name: "itemize"},
+				Body: List{&Macro{Name: &Ident{Name: `\item`}}, &Word{Text: "x"}},
+			},
+			want: "\\begin{itemize}\\item x\\end{itemize}",
+		},
+		{
+			node: List{
+				&Comment{Text: "% TODO"},
+				&Word{Text: "a"},
+				&MathExpr{Delim: "$", List: List{
+					&Word{Text: "x"},
+					&Comment{Text: "%"},
+				}},
+				&Comment{Text: "% b"},
+			},
+			want: "% TODO\na$x%\n$% b\n",
+		},
+		{
+			node: List{
+				&MacroDef{Cmd: &Ident{Name: `\def`}, Name: &Ident{Name: `\x`}, NArgs: 2, Body: "#1#2"},
+				&MacroDef{Cmd: &Ident{Name: `\newcommand`}, Name: &Ident{Name: `\y`}, NArgs: 1, Optional: true, Default: "a", Body: "#1"},
+			},
+			want: `\def\x#1#2{#1#2}\newcommand{\y}[1][a]{#1}`,
+		},
+		{
+			mode: SpaceOperators,
+			node: &MathExpr{Delim: "$", List: List{&Table{
+				Env: &Env{
+					Name: &Ident{Name: "array"},
+					Args: List{&Arg{List: List{&Word{Text: "cc"}}}},
+				},
+				Rows: [][]Cell{
+					{
+						{List: List{&Word{Text: "a"}, &Symbol{Text: "="}, &Word{Text: "b"}}, Sep: "&"},
+						{List: List{&Word{Text: "c"}}, Sep: `\\`, Space: &OptArg{List: List{&Dimen{Value: "1", Unit: "ex"}}}},
+					},
+					{
+						{Rules: List{&Macro{Name: &Ident{Name: `\hline`}}}, List: List{&Word{Text: "d"}}},
+					},
+				},
+			}}},
+			want: `$\begin{array}{cc}a = b&c\\[1ex]\hline d\end{array}$`,
+		},
+		{
+			node: &Macro{
+				Name: &Ident{Name: `\vspace`},
+				Args: List{&Arg{List: List{&Dimen{
+					Value: "-2", Unit: "pt",
+					Plus:  &Dimen{Value: "1", Unit: "fil"},
+					Minus: &Dimen{Unit: `\baselineskip`},
+				}}}},
+			},
+			want: `\vspace{-2pt plus 1fil minus \baselineskip}`,
+		},
+		{
+			mode: NormalizeBraces,
+			node: &MathExpr{Delim: "$", List: List{
+				&Word{Text: "f"},
+				&Sup{Node: List{&Symbol{Text: "'"}, &Symbol{Text: "'"}}},
+				&Word{Text: "g"},
+				&Sup{Node: List{&Symbol{Text: "'"}, &Literal{Text: "2"}}},
+				&Word{Text: "h"},
+				&Sup{Node: List{&Symbol{Text: "'"}, &Word{Text: "a"}, &Word{Text: "b"}}},
+				&Word{Text: "k"},
+				&Sup{Node: &Symbol{Text: "'"}},
+			}},
+			want: `$f''g'^{2}h'^{a b}k^{'}$`,
+		},
+		{
+			node: &Macro{
+				Name: &Ident{Name: `\includegraphics`},
+				Args: List{
+					&OptArg{List: List{&KeyVal{Pairs: []*Pair{
+						{Key: "width", Value: List{&Dimen{Value: "3", Unit: "cm"}}},
+						{Key: "draft"},
+						{Key: "alt", Value: List{}},
+						{Key: "page", Value: List{&Group{List: List{&Literal{Text: "1"}, &Symbol{Text: ","}, &Literal{Text: "2"}}}}},
+					}}}},
+					&Arg{List: List{&Word{Text: "img"}}},
+				},
+			},
+			want: `\includegraphics[width=3cm,draft,alt=,page={1,2}]{img}`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			o := new(strings.Builder)
+			err := (&Printer{Mode: tc.mode}).Fprint(o, tc.node)
+			if err != nil {
+				t.Fatalf("could not print node: %+v", err)
+			}
+			if got, want := o.String(), tc.want; got != want {
+				t.Fatalf("invalid output:\ngot= %q\nwant=%q", got, want)
+			}
+		})
+	}
+}
+
+func TestFprintErrors(t *testing.T) {
+	for _, tc := range []struct {
+		node Node
+		want string
+	}{
+		{
+			node: List{&Word{Text: "x"}, &Bad{From: 1, To: 2}},
+			want: "ast: cannot print invalid node at position 1",
+		},
+		{
+			node: &MathExpr{Delim: "$$$"},
+			want: `ast: invalid math expression delimiter "$$$"`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			o := new(strings.Builder)
+			err := Fprint(o, tc.node)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+			if o.Len() != 0 {
+				t.Fatalf("unexpected output: %q", o)
+			}
+		})
+	}
+}
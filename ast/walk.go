@@ -34,6 +34,15 @@ func Walk(v Visitor, node Node) {
 			Walk(v, n.Name)
 		}
 		walkNodes(v, n.Args)
+		walkNodes(v, n.Expansion)
+
+	case *MacroDef:
+		if n.Cmd != nil {
+			Walk(v, n.Cmd)
+		}
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
 
 	case *Arg:
 		walkNodes(v, n.List)
@@ -44,12 +53,63 @@ func Walk(v Visitor, node Node) {
 	case *Ident:
 		// nothing to do.
 
+	case *Bad:
+		// nothing to do.
+
+	case *Env:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		walkNodes(v, n.Args)
+		walkNodes(v, n.Body)
+
+	case *Table:
+		if n.Env != nil {
+			Walk(v, n.Env)
+		}
+		for _, row := range n.Rows {
+			for _, cell := range row {
+				walkNodes(v, cell.Rules)
+				walkNodes(v, cell.List)
+				if cell.Space != nil {
+					Walk(v, cell.Space)
+				}
+			}
+		}
+
+	case *Delimited:
+		Walk(v, n.Left)
+		walkNodes(v, n.Body)
+		Walk(v, n.Right)
+
+	case *Delim:
+		// nothing to do.
+
+	case *Group:
+		walkNodes(v, n.List)
+
 	case *MathExpr:
 		walkNodes(v, n.List)
 
-	case *Word, *Literal, *Symbol:
+	case *Paragraph:
+		walkNodes(v, n.List)
+
+	case *Word, *Literal, *Symbol, *Verbatim, *Comment:
 		// nothing to do.
 
+	case *Dimen:
+		if n.Plus != nil {
+			Walk(v, n.Plus)
+		}
+		if n.Minus != nil {
+			Walk(v, n.Minus)
+		}
+
+	case *KeyVal:
+		for _, pair := range n.Pairs {
+			walkNodes(v, pair.Value)
+		}
+
 	case *Sub:
 		Walk(v, n.Node)
 
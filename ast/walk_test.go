@@ -65,6 +65,29 @@ func TestWalk(t *testing.T) {
 			},
 			want: "*ast.Sup *ast.Literal <nil> <nil>",
 		},
+		{
+			node: &Dimen{
+				Value: "1", Unit: "em",
+				Plus:  &Dimen{Value: "1", Unit: "fil"},
+				Minus: &Dimen{Value: "2", Unit: "pt"},
+			},
+			want: "*ast.Dimen *ast.Dimen <nil> *ast.Dimen <nil> <nil>",
+		},
+		{
+			node: &KeyVal{Pairs: []*Pair{
+				{Key: "width", Value: List{&Dimen{Value: "3", Unit: "cm"}}},
+				{Key: "draft"},
+				{Key: "page", Value: List{&Literal{Text: "1"}}},
+			}},
+			want: "*ast.KeyVal *ast.Dimen <nil> *ast.Literal <nil> <nil>",
+		},
+		{
+			node: List{
+				&Word{Text: "x"},
+				&Sub{Node: &Bad{}},
+			},
+			want: "ast.List *ast.Word <nil> *ast.Sub *ast.Bad <nil> <nil> <nil>",
+		},
 	} {
 		t.Run("", func(t *testing.T) {
 			o := new(strings.Builder)
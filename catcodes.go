@@ -0,0 +1,100 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"unicode"
+
+	"github.com/go-latex/latex/ast"
+)
+
+// Catcode is a TeX category code, describing how a character is scanned.
+type Catcode uint8
+
+const (
+	Escape      Catcode = iota // starts a macro name, e.g. \
+	BeginGroup                 // opens a group, e.g. {
+	EndGroup                   // closes a group, e.g. }
+	MathShift                  // opens or closes a math expression, e.g. $
+	AlignTab                   // separates table cells, e.g. &
+	EndOfLine                  // ends a line, e.g. \n
+	Parameter                  // introduces a macro parameter, e.g. #
+	Superscript                // introduces a superscript, e.g. ^
+	Subscript                  // introduces a subscript, e.g. _
+	Ignored                    // skipped by the scanner
+	Spacer                     // separates words, e.g. ' '
+	Letter                     // forms words and macro names, e.g. a
+	Other                      // any other printable character, e.g. 1 or +
+	Active                     // behaves as a macro, e.g. ~
+	CommentChar                // starts a comment, e.g. %
+	Invalid                    // reported as an error
+)
+
+// Catcodes maps characters to their category code.
+//
+// Characters missing from the table are letters if unicode.IsLetter
+// reports so, and other characters otherwise.
+type Catcodes map[rune]Catcode
+
+// DefaultCatcodes returns the category codes of LaTeX documents.
+//
+// Tabs are spaces, carriage returns are ignored, and '@' is not a letter.
+func DefaultCatcodes() Catcodes {
+	return Catcodes{
+		'\\':   Escape,
+		'{':    BeginGroup,
+		'}':    EndGroup,
+		'$':    MathShift,
+		'&':    AlignTab,
+		'\n':   EndOfLine,
+		'#':    Parameter,
+		'^':    Superscript,
+		'_':    Subscript,
+		'\t':   Spacer,
+		'\r':   Ignored,
+		' ':    Spacer,
+		'@':    Other,
+		'~':    Active,
+		'%':    CommentChar,
+		'\x7f': Invalid,
+	}
+}
+
+// Of returns the category code of r.
+func (cat Catcodes) Of(r rune) Catcode {
+	if c, ok := cat[r]; ok {
+		return c
+	}
+	if unicode.IsLetter(r) {
+		return Letter
+	}
+	return Other
+}
+
+func (cat Catcodes) clone() Catcodes {
+	o := make(Catcodes, len(cat))
+	for k, v := range cat {
+		o[k] = v
+	}
+	return o
+}
+
+// catcodeSwitch is a macro changing the category code of a character,
+// e.g. \makeatletter.
+type catcodeSwitch struct {
+	r   rune
+	cat Catcode
+}
+
+func (m catcodeSwitch) parseMacro(p *parser) ast.Node {
+	tok := p.s.tok
+	p.s.setCatcode(m.r, m.cat)
+	return &ast.Macro{
+		Name: &ast.Ident{
+			NamePos: tok.Pos,
+			Name:    tok.Text,
+		},
+	}
+}
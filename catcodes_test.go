@@ -0,0 +1,120 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"testing"
+
+	"github.com/go-latex/latex/ast"
+)
+
+func TestCatcodesOf(t *testing.T) {
+	cat := DefaultCatcodes()
+	for _, tc := range []struct {
+		r    rune
+		want Catcode
+	}{
+		{'\\', Escape},
+		{'{', BeginGroup},
+		{'$', MathShift},
+		{'&', AlignTab},
+		{'\n', EndOfLine},
+		{'\t', Spacer},
+		{'\r', Ignored},
+		{'~', Active},
+		{'%', CommentChar},
+		{'a', Letter},
+		{'é', Letter},
+		{'@', Other},
+		{'1', Other},
+		{'—', Other},
+	} {
+		if got, want := cat.Of(tc.r), tc.want; got != want {
+			t.Errorf("invalid category code for %q: got=%d, want=%d", tc.r, got, want)
+		}
+	}
+}
+
+func TestParseMakeAtLetter(t *testing.T) {
+	p := NewParser()
+	err := p.RegisterMacro(`\my@macro`, "A")
+	if err != nil {
+		t.Fatalf("could not register macro: %+v", err)
+	}
+
+	node, err := p.ParseExpr(`a@b\makeatletter\my@macro{a@b}\makeatother a@b`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	want := ast.List{
+		&ast.Word{Text: "a"},
+		&ast.Symbol{Text: "@"},
+		&ast.Word{Text: "b"},
+		&ast.Macro{Name: &ast.Ident{Name: `\makeatletter`}},
+		&ast.Macro{
+			Name: &ast.Ident{Name: `\my@macro`},
+			Args: ast.List{&ast.Arg{List: ast.List{&ast.Word{Text: "a@b"}}}},
+		},
+		&ast.Macro{Name: &ast.Ident{Name: `\makeatother`}},
+		&ast.Symbol{Text: " "},
+		&ast.Word{Text: "a"},
+		&ast.Symbol{Text: "@"},
+		&ast.Word{Text: "b"},
+	}
+	if got, want := sprint(node), sprint(want); got != want {
+		t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+	}
+
+	_, err = p.ParseExpr(`\makeatletter`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+	if got := p.Catcodes.Of('@'); got != Other {
+		t.Fatalf("category code change leaked to parser: got=%d", got)
+	}
+}
+
+func TestParserCatcodes(t *testing.T) {
+	p := NewParser()
+	p.Catcodes['@'] = Letter
+	p.Catcodes['|'] = Invalid
+	p.Catcodes['\t'] = Ignored
+
+	node, err := p.ParseExpr("x@y\tz")
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+	want := ast.List{
+		&ast.Word{Text: "x@y"},
+		&ast.Word{Text: "z"},
+	}
+	if got, want := sprint(node), sprint(want); got != want {
+		t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+	}
+
+	_, err = p.ParseExpr(`$|x|$`)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if got, want := err.Error(), `1:2: invalid character "|" (and 1 more errors)`; got != want {
+		t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+	}
+
+	node, err = ParseExpr("x@y\tz")
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+	want = ast.List{
+		&ast.Word{Text: "x"},
+		&ast.Symbol{Text: "@"},
+		&ast.Word{Text: "y"},
+		&ast.Symbol{Text: "\t"},
+		&ast.Word{Text: "z"},
+	}
+	if got, want := sprint(node), sprint(want); got != want {
+		t.Fatalf("category codes leaked to default parser:\ngot: %v\nwant:%v", got, want)
+	}
+}
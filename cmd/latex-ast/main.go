@@ -0,0 +1,176 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command latex-ast dumps the syntax tree of LaTeX documents and formulas
+// as JSON.
+//
+// Without an explicit path, latex-ast processes the standard input.
+// Given a file, it operates on that file.
+// With -e, it processes the given expression.
+// With -d, latex-ast decodes a JSON syntax tree and prints its LaTeX
+// source instead.
+//
+// Positions are those of a token.FileSet holding the processed source:
+// the first byte of the source is at position 1.
+//
+// Usage:
+//
+//	latex-ast [flags] [path ...]
+//
+// Example:
+//
+//	$> latex-ast -e '$x^2$'
+//	[
+//	  {
+//	    "type": "MathExpr",
+//	    "delim": "$",
+//	    "display": false,
+//	    "left": 1,
+//	    "list": [
+//	      ...
+//	    ],
+//	    "right": 5
+//	  }
+//	]
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/ast/astjson"
+	"github.com/go-latex/latex/token"
+)
+
+func main() {
+	log.SetPrefix("latex-ast: ")
+	log.SetFlags(0)
+
+	var (
+		expr   = flag.String("e", "", "process the given expression instead of files")
+		math   = flag.Bool("math", false, "parse sources as math expressions")
+		uni    = flag.Bool("u", false, "parse Unicode math characters as their TeX spelling (e.g. α as \\alpha)")
+		decode = flag.Bool("d", false, "decode JSON syntax trees and print their LaTeX source")
+		indent = flag.String("indent", "  ", "indentation of the JSON output; compact output when empty")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: latex-ast [flags] [path ...]\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	dumper := dumper{
+		decode: *decode,
+		indent: *indent,
+		parser: latex.NewParser(),
+		printer: &ast.Printer{
+			Math: *math,
+		},
+	}
+	if *math {
+		dumper.parser.Mode = latex.MathMode
+	}
+	dumper.parser.UnicodeMath = *uni
+
+	switch {
+	case *expr != "":
+		if flag.NArg() > 0 {
+			log.Fatalf("cannot use -e with files")
+		}
+		err := dumper.process("<expression>", []byte(*expr))
+		if err != nil {
+			latex.PrintError(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+
+	case flag.NArg() == 0:
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("could not read standard input: %+v", err)
+		}
+		err = dumper.process("<standard input>", src)
+		if err != nil {
+			latex.PrintError(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	rc := 0
+	for _, fname := range flag.Args() {
+		src, err := ioutil.ReadFile(fname)
+		if err == nil {
+			err = dumper.process(fname, src)
+		}
+		if err != nil {
+			latex.PrintError(os.Stderr, err)
+			rc = 2
+		}
+	}
+	os.Exit(rc)
+}
+
+type dumper struct {
+	decode bool
+	indent string
+
+	parser  *latex.Parser
+	printer *ast.Printer
+}
+
+func (d *dumper) process(fname string, src []byte) error {
+	if d.decode {
+		return d.print(fname, src)
+	}
+
+	fset := token.NewFileSet()
+	node, err := d.parser.ParseFile(fset, fname, src)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	switch d.indent {
+	case "":
+		out, err = astjson.Marshal(node)
+	default:
+		out, err = astjson.MarshalIndent(node, "", d.indent)
+	}
+	if err != nil {
+		return fmt.Errorf("could not encode %s: %w", fname, err)
+	}
+	out = append(out, '\n')
+
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// print prints the LaTeX source of the JSON syntax tree src.
+func (d *dumper) print(fname string, src []byte) error {
+	node, err := astjson.Unmarshal(src)
+	if err != nil {
+		return fmt.Errorf("could not decode %s: %w", fname, err)
+	}
+
+	out := new(bytes.Buffer)
+	err = d.printer.Fprint(out, node)
+	if err != nil {
+		return fmt.Errorf("could not print %s: %w", fname, err)
+	}
+	if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		out.WriteString("\n")
+	}
+
+	_, err = os.Stdout.Write(out.Bytes())
+	return err
+}
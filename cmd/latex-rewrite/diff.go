@@ -0,0 +1,177 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// edit replaces the bytes [beg,end) of a source with text.
+type edit struct {
+	beg, end int
+	text     string
+}
+
+// apply returns src with the non-overlapping edits applied.
+func apply(src []byte, edits []edit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].beg < edits[j].beg })
+
+	var (
+		o    = new(bytes.Buffer)
+		last = 0
+	)
+	for _, e := range edits {
+		o.Write(src[last:e.beg])
+		o.WriteString(e.text)
+		last = e.end
+	}
+	o.Write(src[last:])
+	return o.Bytes()
+}
+
+// context is the number of unchanged lines surrounding the changes of a
+// diff.
+const context = 3
+
+// chunk replaces the lines [beg,end) of a source with lines.
+type chunk struct {
+	beg, end int
+	lines    []string
+}
+
+// diff returns the unified diff between src and the result of applying the
+// non-overlapping edits to src.
+func diff(name string, src []byte, edits []edit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].beg < edits[j].beg })
+
+	var (
+		lines  = splitLines(string(src))
+		starts = make([]int, len(lines)+1) // offset of each line
+		chunks []chunk
+	)
+	for i, line := range lines {
+		starts[i+1] = starts[i] + len(line)
+	}
+	lineOf := func(off int) int {
+		return sort.Search(len(lines), func(i int) bool { return starts[i+1] > off })
+	}
+
+	// group the edits touching the same lines into chunks.
+	for i := 0; i < len(edits); {
+		var (
+			beg = lineOf(edits[i].beg)
+			end = lineOf(edits[i].end-1) + 1
+			j   = i + 1
+		)
+		if edits[i].end <= edits[i].beg {
+			end = beg + 1
+		}
+		for ; j < len(edits) && lineOf(edits[j].beg) < end; j++ {
+			end = max(end, lineOf(edits[j].end-1)+1)
+		}
+		end = min(end, len(lines))
+
+		text := apply(src[starts[beg]:starts[end]], shift(edits[i:j], -starts[beg]))
+		chunks = append(chunks, chunk{beg: beg, end: end, lines: splitLines(string(text))})
+		i = j
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	o := new(bytes.Buffer)
+	fmt.Fprintf(o, "--- %s.orig\n+++ %s\n", name, name)
+	delta := 0 // difference between the new and old line numbers
+	for i := 0; i < len(chunks); {
+		// merge the chunks whose contexts overlap into a hunk.
+		j := i + 1
+		for ; j < len(chunks) && chunks[j].beg-chunks[j-1].end <= 2*context; j++ {
+		}
+		var (
+			beg  = max(chunks[i].beg-context, 0)
+			end  = min(chunks[j-1].end+context, len(lines))
+			hunk = new(bytes.Buffer)
+			n    = 0 // number of lines of the new hunk
+			cur  = beg
+		)
+		for _, c := range chunks[i:j] {
+			for _, line := range lines[cur:c.beg] {
+				writeLine(hunk, " ", line)
+				n++
+			}
+			for _, line := range lines[c.beg:c.end] {
+				writeLine(hunk, "-", line)
+			}
+			for _, line := range c.lines {
+				writeLine(hunk, "+", line)
+				n++
+			}
+			cur = c.end
+		}
+		for _, line := range lines[cur:end] {
+			writeLine(hunk, " ", line)
+			n++
+		}
+		fmt.Fprintf(o, "@@ -%s +%s @@\n", hunkRange(beg, end-beg), hunkRange(beg+delta, n))
+		o.Write(hunk.Bytes())
+		delta += n - (end - beg)
+		i = j
+	}
+	return o.Bytes()
+}
+
+func shift(edits []edit, off int) []edit {
+	o := make([]edit, len(edits))
+	for i, e := range edits {
+		o[i] = edit{beg: e.beg + off, end: e.end + off, text: e.text}
+	}
+	return o
+}
+
+// hunkRange returns the range of n lines starting at line beg (0-based),
+// as written in a unified diff hunk header.
+func hunkRange(beg, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", beg)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", beg+1)
+	}
+	return fmt.Sprintf("%d,%d", beg+1, n)
+}
+
+func writeLine(o *bytes.Buffer, prefix, line string) {
+	o.WriteString(prefix)
+	o.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		o.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// splitLines splits s into lines, keeping their newline character.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,305 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command latex-rewrite searches and rewrites the math formulas of LaTeX
+// documents, in the style of gofmt -r.
+//
+// The rule given with -r is either a pattern, or a rewrite rule of the form
+// 'pattern -> replacement', whose sides are math formulas.
+// The metavariables of the rule, given with -m, are single-letter names
+// matching any node, or any sequence of nodes when enclosed in braces.
+// See the documentation of the rewrite package for details.
+//
+// Without an explicit path, latex-rewrite processes the standard input.
+// Given a file, it operates on that file.
+// For a pattern, latex-rewrite prints the position and the source of each
+// match. For a rewrite rule, it prints the diff of each rewritten source,
+// where the source of each match is replaced with its printed replacement,
+// leaving the rest of the source untouched.
+// latex-rewrite warns when the rule matches nothing.
+//
+// Usage:
+//
+//	latex-rewrite -r rule [flags] [path ...]
+//
+// Example:
+//
+//	$> latex-rewrite -r '\frac{d a}{d b} -> \dv{a}{b}' -m a,b -macros '\dv=AA' doc.tex
+//	--- doc.tex.orig
+//	+++ doc.tex
+//	@@ -1 +1 @@
+//	-The velocity $v = \frac{dx}{dt}$ is the derivative of the position.
+//	+The velocity $v = \dv{x}{t}$ is the derivative of the position.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/rewrite"
+	"github.com/go-latex/latex/token"
+)
+
+func main() {
+	log.SetPrefix("latex-rewrite: ")
+	log.SetFlags(0)
+
+	var (
+		rule   = flag.String("r", "", "search pattern or rewrite rule (e.g., '\\frac{a}{b} -> a/b')")
+		vars   = flag.String("m", "", "comma-separated list of the metavariables of the rule (e.g., a,b)")
+		macros = flag.String("macros", "", "comma-separated list of additional macros with their signature (e.g., '\\dv=AA,\\R=')")
+		list   = flag.Bool("l", false, "list files with matches or rewrites, instead of printing them")
+		write  = flag.Bool("w", false, "write result to (source) file instead of printing a diff")
+		math   = flag.Bool("math", false, "parse sources as math expressions")
+		uni    = flag.Bool("u", false, "parse Unicode math characters as their TeX spelling (e.g. α as \\alpha)")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: latex-rewrite -r rule [flags] [path ...]\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if *rule == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rw := rewriter{
+		list:   *list,
+		write:  *write,
+		math:   *math,
+		parser: latex.NewParser(),
+	}
+	if *math {
+		rw.parser.Mode = latex.MathMode
+	}
+	rw.parser.UnicodeMath = *uni
+	if *macros != "" {
+		for _, macro := range strings.Split(*macros, ",") {
+			i := strings.Index(macro, "=")
+			if i < 0 {
+				log.Fatalf("invalid macro %q: missing signature", macro)
+			}
+			err := rw.parser.RegisterMacro(strings.TrimSpace(macro[:i]), strings.TrimSpace(macro[i+1:]))
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+		}
+	}
+
+	var names []string
+	if *vars != "" {
+		names = strings.Split(*vars, ",")
+	}
+
+	var err error
+	switch {
+	case strings.Contains(*rule, "->"):
+		rw.rule, err = rewrite.ParseRule(rw.parser, *rule, names...)
+	default:
+		rw.pattern, err = rewrite.ParsePattern(rw.parser, *rule, names...)
+	}
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	if flag.NArg() == 0 {
+		if *write {
+			log.Fatalf("cannot use -w with standard input")
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("could not read standard input: %+v", err)
+		}
+		err = rw.process("<standard input>", src)
+		if err != nil {
+			latex.PrintError(os.Stderr, err)
+			os.Exit(2)
+		}
+		rw.warn(names)
+		return
+	}
+
+	rc := 0
+	for _, fname := range flag.Args() {
+		src, err := ioutil.ReadFile(fname)
+		if err == nil {
+			err = rw.process(fname, src)
+		}
+		if err != nil {
+			latex.PrintError(os.Stderr, err)
+			rc = 2
+		}
+	}
+	if rc == 0 {
+		rw.warn(names)
+	}
+	os.Exit(rc)
+}
+
+type rewriter struct {
+	list  bool
+	write bool
+	math  bool
+
+	parser  *latex.Parser
+	pattern *rewrite.Pattern // search pattern, or nil
+	rule    *rewrite.Rule    // rewrite rule, or nil
+
+	n int // number of matches
+}
+
+func (rw *rewriter) process(fname string, src []byte) error {
+	fset := token.NewFileSet()
+	node, err := rw.parser.ParseFile(fset, fname, src)
+	if err != nil {
+		return err
+	}
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+
+	if rw.pattern != nil {
+		return rw.find(fname, src, fset, node)
+	}
+
+	// splice the replacement of each match into the source, so the rest of
+	// the source is left untouched.
+	var edits []edit
+	matches := rw.rule.Pattern.Find(node, rw.math)
+	rw.n += len(matches)
+	for _, m := range matches {
+		text, err := rw.print(rw.rule.Replace(m))
+		if err != nil {
+			return fmt.Errorf("could not print %s: %w", fname, err)
+		}
+		var (
+			beg = offset(m.Pos())
+			end = offset(m.End())
+		)
+		if beg > 0 && mergesWith(string(src[:beg]), text) {
+			text = " " + text
+		}
+		if end < len(src) && mergesWith(text, string(src[end:])) {
+			text += " "
+		}
+		edits = append(edits, edit{beg: beg, end: end, text: text})
+	}
+	edits = outermost(edits)
+
+	if len(edits) == 0 {
+		return nil
+	}
+
+	switch {
+	case rw.list:
+		fmt.Println(fname)
+	case rw.write:
+		// see below.
+	default:
+		_, err = os.Stdout.Write(diff(fname, src, edits))
+		return err
+	}
+
+	if rw.write {
+		fi, err := os.Stat(fname)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(fname, apply(src, edits), fi.Mode().Perm())
+		if err != nil {
+			return fmt.Errorf("could not write %s: %w", fname, err)
+		}
+	}
+	return nil
+}
+
+// warn warns when the rule matched nothing, e.g. when its metavariables
+// were not given with -m and are matched literally.
+func (rw *rewriter) warn(vars []string) {
+	if rw.n > 0 {
+		return
+	}
+	if len(vars) == 0 {
+		log.Printf("warning: no match of the rule, which has no metavariables (see -m)")
+		return
+	}
+	log.Printf("warning: no match of the rule")
+}
+
+// find prints the matches of the search pattern in the source src.
+func (rw *rewriter) find(fname string, src []byte, fset *token.FileSet, node ast.Node) error {
+	matches := rw.pattern.Find(node, rw.math)
+	rw.n += len(matches)
+	if len(matches) == 0 {
+		return nil
+	}
+	if rw.list {
+		fmt.Println(fname)
+		return nil
+	}
+	for _, m := range matches {
+		var (
+			beg = fset.Position(m.Pos())
+			end = fset.Position(m.End())
+		)
+		fmt.Printf("%s: %s\n", beg, src[beg.Offset:end.Offset])
+	}
+	return nil
+}
+
+// outermost returns the edits that are not enclosed in another edit, e.g.
+// the replacement of a match of the rule within a larger match, whose
+// replacement already holds the rewritten inner match.
+func outermost(edits []edit) []edit {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].beg != edits[j].beg {
+			return edits[i].beg < edits[j].beg
+		}
+		return edits[i].end > edits[j].end
+	})
+	var o []edit
+	for _, e := range edits {
+		if len(o) > 0 && e.end <= o[len(o)-1].end {
+			continue
+		}
+		o = append(o, e)
+	}
+	return o
+}
+
+// mergesWith reports whether the trailing control word of prev would
+// absorb the leading letter of next, e.g. \alpha followed by x.
+func mergesWith(prev, next string) bool {
+	i := strings.LastIndex(prev, `\`)
+	if i < 0 || len(prev)-i < 2 || len(next) == 0 || !isLetter(next[0]) {
+		return false
+	}
+	for _, c := range []byte(prev[i+1:]) {
+		if !isLetter(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// print returns the LaTeX source of the math node.
+func (rw *rewriter) print(node ast.Node) (string, error) {
+	o := new(strings.Builder)
+	err := (&ast.Printer{Math: true}).Fprint(o, node)
+	return o.String(), err
+}
@@ -0,0 +1,157 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command latexfmt formats LaTeX documents and formulas.
+//
+// Without an explicit path, latexfmt processes the standard input.
+// Given a file, it operates on that file.
+// By default, latexfmt prints the reformatted sources to standard output.
+//
+// Usage:
+//
+//	latexfmt [flags] [path ...]
+//
+// Example:
+//
+//	$> echo '$x^2+y_i=\frac{a}{b}$' | latexfmt -s -b
+//	$x^{2} + y_{i} = \frac{a}{b}$
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+func main() {
+	log.SetPrefix("latexfmt: ")
+	log.SetFlags(0)
+
+	var (
+		list   = flag.Bool("l", false, "list files whose formatting differs from latexfmt's")
+		write  = flag.Bool("w", false, "write result to (source) file instead of stdout")
+		math   = flag.Bool("math", false, "parse sources as math expressions")
+		spaces = flag.Bool("s", false, "surround binary operators and relations with spaces")
+		braces = flag.Bool("b", false, "enclose sub- and superscript arguments in braces")
+		uni    = flag.Bool("u", false, "replace Unicode math characters with their TeX spelling (e.g. α with \\alpha)")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: latexfmt [flags] [path ...]\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	fmter := formatter{
+		list:   *list,
+		write:  *write,
+		parser: latex.NewParser(),
+		printer: &ast.Printer{
+			Math: *math,
+		},
+	}
+	if *math {
+		fmter.parser.Mode = latex.MathMode
+	}
+	fmter.parser.UnicodeMath = *uni
+	if *spaces {
+		fmter.printer.Mode |= ast.SpaceOperators
+	}
+	if *braces {
+		fmter.printer.Mode |= ast.NormalizeBraces
+	}
+
+	if flag.NArg() == 0 {
+		if *write {
+			log.Fatalf("cannot use -w with standard input")
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("could not read standard input: %+v", err)
+		}
+		err = fmter.process("<standard input>", src)
+		if err != nil {
+			latex.PrintError(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	rc := 0
+	for _, fname := range flag.Args() {
+		src, err := ioutil.ReadFile(fname)
+		if err == nil {
+			err = fmter.process(fname, src)
+		}
+		if err != nil {
+			latex.PrintError(os.Stderr, err)
+			rc = 2
+		}
+	}
+	os.Exit(rc)
+}
+
+type formatter struct {
+	list  bool
+	write bool
+
+	parser  *latex.Parser
+	printer *ast.Printer
+}
+
+func (f *formatter) process(fname string, src []byte) error {
+	fset := token.NewFileSet()
+	node, err := f.parser.ParseFile(fset, fname, src)
+	if err != nil {
+		return err
+	}
+
+	// keep the line breaks and the indentation of the source.
+	printer := *f.printer
+	printer.Src = src
+	printer.Fset = fset
+
+	out := new(bytes.Buffer)
+	err = printer.Fprint(out, node)
+	if err != nil {
+		return fmt.Errorf("could not format %s: %w", fname, err)
+	}
+	if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+		out.WriteString("\n")
+	}
+
+	if !f.list && !f.write {
+		_, err = os.Stdout.Write(out.Bytes())
+		return err
+	}
+
+	if bytes.Equal(src, out.Bytes()) {
+		return nil
+	}
+
+	if f.list {
+		fmt.Println(fname)
+	}
+
+	if f.write {
+		fi, err := os.Stat(fname)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(fname, out.Bytes(), fi.Mode().Perm())
+		if err != nil {
+			return fmt.Errorf("could not write %s: %w", fname, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+)
+
+func TestFormat(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "latexfmt-")
+	if err != nil {
+		t.Fatalf("could not create tmp dir: %+v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, tc := range []struct {
+		name string
+		src  string
+		want string // expected output, or src if empty
+	}{
+		{
+			name: "macro-call",
+			src:  "\\newcommand{\\R}{\\mathbb{R}} Let $x\\in\\R$.\n",
+		},
+		{
+			name: "macro-call-newline",
+			src:  "\\newcommand{\\R}{\\mathbb{R}}\nLet $x\\in\\R$.\n",
+		},
+		{
+			name: "macro-paragraphs",
+			src:  "\\newcommand{\\R}{\\mathbb{R}}\n\nLet $x\\in\\R$.\n\n$\\R^2$\n",
+		},
+		{
+			name: "indentation",
+			src:  "\\begin{quote}\n  a\n  b % c\n\\end{quote}\n",
+		},
+		{
+			name: "math-lines",
+			src:  "\\begin{align}\n  a&=b\\\\\n  c&=d% e\n    +f\n\\end{align}\n\\[\n  x\n\\]\n",
+		},
+		{
+			name: "comment-indentation",
+			src:  "\\begin{itemize}\n  \\item a % b\n    c\n\\end{itemize}\n",
+		},
+		{
+			name: "document",
+			src:  "\\documentclass[a4paper]{article}\n\\usepackage{amsmath}\n\\begin{document}\n\\section*{Intro}\\label{sec:intro}\nSee \\eqref{eq:a} and \\cite[p.~2]{knuth}.\n\\end{document}\n",
+		},
+		{
+			name: "tabs",
+			src:  "a\tb\n\t\\textbf{c}\n",
+		},
+		{
+			name: "macro-args",
+			src:  "\\newcommand{\\half}[1]{\\frac{#1}{2}}\\def\\sq#1#2{#1^#2}$\\half{x+1}=\\sq x2$\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fname := filepath.Join(tmp, tc.name+".tex")
+			err := ioutil.WriteFile(fname, []byte(tc.src), 0644)
+			if err != nil {
+				t.Fatalf("could not write source file: %+v", err)
+			}
+
+			fmter := formatter{
+				write:   true,
+				parser:  latex.NewParser(),
+				printer: &ast.Printer{},
+			}
+			err = fmter.process(fname, []byte(tc.src))
+			if err != nil {
+				t.Fatalf("could not format: %+v", err)
+			}
+
+			got, err := ioutil.ReadFile(fname)
+			if err != nil {
+				t.Fatalf("could not read formatted file: %+v", err)
+			}
+			want := tc.want
+			if want == "" {
+				want = tc.src
+			}
+			if string(got) != want {
+				t.Fatalf("invalid output:\ngot= %q\nwant=%q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+// Mode describes the mode a Parser starts parsing in.
+type Mode int
+
+const (
+	TextMode Mode = iota // parse expressions as LaTeX text.
+	MathMode             // parse expressions as if they were enclosed in $...$.
+)
+
+// Parser parses LaTeX expressions and documents, with a configurable set
+// of macros and environments.
+//
+// A Parser may be used concurrently from multiple goroutines, as long as
+// it is not modified (with RegisterMacro or RegisterEnv) at the same time.
+// Macros defined by a parsed document (e.g. with \newcommand) are local
+// to that document and do not modify the Parser.
+type Parser struct {
+	Mode Mode // mode to start parsing in.
+
+	// Catcodes are the category codes used to scan sources.
+	// Changes made by a parsed document (e.g. with \makeatletter) are
+	// local to that document and do not modify the Parser.
+	Catcodes Catcodes
+
+	// UnicodeMath reports whether Unicode math characters are parsed as
+	// their TeX spelling, e.g. α as \alpha, ≤ as \leq or xᵢ² as x_i^2.
+	UnicodeMath bool
+
+	macros map[string]macroParser
+	envs   map[string]builtinEnv
+}
+
+// NewParser returns a new parser, starting in text mode with the default
+// category codes and knowing about the builtin set of macros and
+// environments.
+func NewParser() *Parser {
+	p := &Parser{Mode: TextMode, Catcodes: DefaultCatcodes()}
+	p.addBuiltinMacros()
+	p.addBuiltinEnvs()
+	return p
+}
+
+var defaults struct {
+	once sync.Once
+	p    *Parser
+}
+
+// defaultParser returns the parser used by ParseExpr and ParseFile.
+func defaultParser() *Parser {
+	defaults.once.Do(func() {
+		defaults.p = NewParser()
+	})
+	return defaults.p
+}
+
+// RegisterMacro registers the macro name, with the arguments described by
+// signature.
+// Each character of signature describes an argument of the macro:
+//   - 'A': a mandatory argument, e.g. {a},
+//   - 'O': an optional argument, e.g. [n],
+//   - 'V': a verbatim argument,
+//   - 'L': a length argument, e.g. {2pt plus 1fil},
+//   - 'S': an optional length argument, e.g. [1ex],
+//   - 'K': an optional argument holding key-value options, e.g. [scale=2,draft].
+//
+// e.g.:
+//
+//	p.RegisterMacro(`\mathbbm`, "A")
+//	p.RegisterMacro(`\SI`, "KAA")
+//
+// RegisterMacro replaces any previously registered macro with the same name.
+func (p *Parser) RegisterMacro(name, signature string) error {
+	if !strings.HasPrefix(name, `\`) || len(name) < 2 {
+		return fmt.Errorf("latex: invalid macro name %q", name)
+	}
+	err := validateSignature(signature)
+	if err != nil {
+		return fmt.Errorf("latex: invalid signature for macro %q: %w", name, err)
+	}
+	p.macros[name] = builtinMacro(signature)
+	return nil
+}
+
+// RegisterEnv registers the environment name, with the arguments described
+// by signature (see RegisterMacro).
+// If math is true, the body of the environment is parsed in math mode.
+//
+// RegisterEnv replaces any previously registered environment with the
+// same name.
+func (p *Parser) RegisterEnv(name, signature string, math bool) error {
+	if name == "" || strings.ContainsAny(name, `\{} `) {
+		return fmt.Errorf("latex: invalid environment name %q", name)
+	}
+	err := validateSignature(signature)
+	if err != nil {
+		return fmt.Errorf("latex: invalid signature for environment %q: %w", name, err)
+	}
+	p.envs[name] = builtinEnv{args: signature, math: math}
+	return nil
+}
+
+func validateSignature(sig string) error {
+	for _, c := range sig {
+		switch c {
+		case 'A', 'a', 'O', 'o', 'V', 'v', 'L', 'l', 'S', 's', 'K', 'k':
+			// ok
+		default:
+			return fmt.Errorf("invalid argument kind %q", c)
+		}
+	}
+	return nil
+}
+
+// ParseExpr parses a LaTeX expression.
+//
+// If the expression contains syntax errors, the returned error is an
+// ErrorList describing the offending tokens.
+// The parser recovers from errors: the returned node holds the whole
+// expression, with the invalid parts replaced by *ast.Bad nodes.
+//
+// The positions of the returned nodes are byte offsets into x.
+func (p *Parser) ParseExpr(x string) (ast.Node, error) {
+	return newParser(p, nil, x).parse()
+}
+
+// ParseFile parses the source code of a single LaTeX source file and returns
+// the corresponding AST.
+//
+// If src != nil, ParseFile parses the source from src and the filename is
+// only used when recording position information. The type of the argument
+// for the src parameter must be string, []byte, or io.Reader.
+// If src == nil, ParseFile parses the file specified by filename.
+//
+// The file is added to fset, which must not be nil.
+// Positions of the returned nodes and errors are relative to fset.
+func (p *Parser) ParseFile(fset *token.FileSet, filename string, src interface{}) (ast.Node, error) {
+	if fset == nil {
+		panic("latex: no token.FileSet provided (fset == nil)")
+	}
+
+	text, err := readSource(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	file := fset.AddFile(filename, -1, len(text))
+	file.SetLinesForContent(text)
+
+	return newParser(p, file, string(text)).parse()
+}
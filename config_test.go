@@ -0,0 +1,151 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-latex/latex/ast"
+)
+
+func TestParserRegisterMacro(t *testing.T) {
+	p1 := NewParser()
+	err := p1.RegisterMacro(`\SI`, "OAA")
+	if err != nil {
+		t.Fatalf("could not register macro: %+v", err)
+	}
+
+	node, err := p1.ParseExpr(`\SI[mode=text]{3}{m}`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	want := ast.List{
+		&ast.Macro{
+			Name: &ast.Ident{Name: `\SI`},
+			Args: ast.List{
+				&ast.OptArg{List: ast.List{
+					&ast.Word{Text: "mode"},
+					&ast.Symbol{Text: "="},
+					&ast.Word{Text: "text"},
+				}},
+				&ast.Arg{List: ast.List{&ast.Literal{Text: "3"}}},
+				&ast.Arg{List: ast.List{&ast.Word{Text: "m"}}},
+			},
+		},
+	}
+	if got, want := sprint(node), sprint(want); got != want {
+		t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+	}
+
+	p2 := NewParser()
+	_, err = p2.ParseExpr(`\SI{3}{m}`)
+	if err == nil {
+		t.Fatalf("macro registration leaked to another parser")
+	}
+
+	_, err = ParseExpr(`\SI{3}{m}`)
+	if err == nil {
+		t.Fatalf("macro registration leaked to default parser")
+	}
+
+	_, err = p1.ParseExpr(`\newcommand{\R}{x}\R`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+	_, err = p1.ParseExpr(`\R`)
+	if err == nil {
+		t.Fatalf("macro definition leaked to parser")
+	}
+
+	for _, tc := range []struct {
+		name, sig string
+		want      string
+	}{
+		{`SI`, "", `latex: invalid macro name "SI"`},
+		{`\`, "", `latex: invalid macro name "\\"`},
+		{`\SI`, "X", `latex: invalid signature for macro "\\SI": invalid argument kind 'X'`},
+	} {
+		err := p1.RegisterMacro(tc.name, tc.sig)
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+		if got, want := err.Error(), tc.want; got != want {
+			t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+		}
+	}
+}
+
+func TestParserRegisterEnv(t *testing.T) {
+	p := NewParser()
+	err := p.RegisterEnv("dmath", "O", true)
+	if err != nil {
+		t.Fatalf("could not register environment: %+v", err)
+	}
+
+	node, err := p.ParseExpr(`\begin{dmath}[label=eq] x + 1\end{dmath}`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	want := ast.List{
+		&ast.Env{
+			Name: &ast.Ident{Name: "dmath"},
+			Args: ast.List{
+				&ast.OptArg{List: ast.List{
+					&ast.Word{Text: "label"},
+					&ast.Symbol{Text: "="},
+					&ast.Word{Text: "eq"},
+				}},
+			},
+			Body: ast.List{
+				&ast.Word{Text: "x"},
+				&ast.Symbol{Text: "+"},
+				&ast.Literal{Text: "1"},
+			},
+		},
+	}
+	if got, want := sprint(node), sprint(want); got != want {
+		t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+	}
+
+	for _, name := range []string{"", `\dmath`, "d math"} {
+		err := p.RegisterEnv(name, "", false)
+		if err == nil {
+			t.Fatalf("expected an error for %q", name)
+		}
+	}
+	err = p.RegisterEnv("dmath", "AX", false)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestParserMode(t *testing.T) {
+	p := NewParser()
+	p.Mode = MathMode
+
+	node, err := p.ParseExpr(`x^2 + \alpha`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	want := ast.List{
+		&ast.Word{Text: "x"},
+		&ast.Sup{Node: &ast.Literal{Text: "2"}},
+		&ast.Symbol{Text: "+"},
+		&ast.Macro{Name: &ast.Ident{Name: `\alpha`}},
+	}
+	if got, want := sprint(node), sprint(want); got != want {
+		t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+	}
+}
+
+func sprint(node ast.Node) string {
+	o := new(strings.Builder)
+	ast.Print(o, node)
+	return o.String()
+}
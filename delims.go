@@ -0,0 +1,93 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/internal/symbols"
+	"github.com/go-latex/latex/token"
+)
+
+// delimiters is the set of valid \left, \middle and \right delimiters.
+var delimiters = symbols.UnionOf(
+	symbols.LeftDelim,
+	symbols.RightDelim,
+	symbols.AmbiDelim,
+	symbols.NewSet(`\lbrace`, `\rbrace`),
+)
+
+// parseDelimited parses a \left ... \middle ... \right construct.
+func (p *parser) parseDelimited(tok token.Token) ast.Node {
+	if p.state != mathState {
+		p.errorf(tok.Pos, "%s not allowed in text mode", tok.Text)
+		return p.bad(tok)
+	}
+
+	node := &ast.Delimited{
+		Left: p.parseDelim(tok),
+	}
+
+	for p.s.Next() {
+		tok := p.s.tok
+		switch {
+		case tok.Kind == token.Macro && tok.Text == `\right`:
+			node.Right = p.parseDelim(tok)
+			return node
+		case tok.Kind == token.Macro && tok.Text == `\middle`:
+			node.Body = append(node.Body, p.parseDelim(tok))
+		case tok.Kind == token.Rbrace,
+			tok.Kind == token.Symbol && (tok.Text == "$" || tok.Text == "$$"),
+			tok.Kind == token.Macro && (tok.Text == `\end` || tok.Text == `\)` || tok.Text == `\]`):
+			// the enclosing math expression or group ends before \right.
+			p.s.unread()
+			return p.missingRight(node)
+		default:
+			if n := p.parseNode(tok); n != nil {
+				node.Body = append(node.Body, n)
+			}
+		}
+	}
+	return p.missingRight(node)
+}
+
+// missingRight reports and records the missing \right of node.
+func (p *parser) missingRight(node *ast.Delimited) ast.Node {
+	pos := p.s.tok.Pos
+	p.errorf(node.Left.Pos(), `missing \right for %s%s`, node.Left.Macro, node.Left.Text)
+	node.Body = append(node.Body, &ast.Bad{From: pos, To: pos})
+	node.Right = &ast.Delim{MacroPos: pos, Macro: `\right`, DelimPos: pos, Text: "."}
+	return node
+}
+
+// parseDelim parses the delimiter following the \left, \middle or \right
+// command tok.
+func (p *parser) parseDelim(tok token.Token) *ast.Delim {
+	delim := &ast.Delim{
+		MacroPos: tok.Pos,
+		Macro:    tok.Text,
+	}
+
+	next := p.next()
+	for next.Kind == token.Space || next.Kind == token.Comment || p.unicodeMath(next) {
+		next = p.next()
+	}
+	delim.DelimPos = next.Pos
+	if !delimiters.Has(next.Text) {
+		p.errorf(next.Pos, "invalid delimiter %q for %s", next.Text, tok.Text)
+		p.s.unread()
+		delim.Text = "."
+		return delim
+	}
+	delim.Text = next.Text
+	return delim
+}
+
+// parseStrayDelim parses a \middle or \right command outside of a
+// \left ... \right construct.
+func (p *parser) parseStrayDelim(tok token.Token) ast.Node {
+	p.errorf(tok.Pos, `%s without matching \left`, tok.Text)
+	delim := p.parseDelim(tok)
+	return &ast.Bad{From: delim.Pos(), To: delim.End()}
+}
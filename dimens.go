@@ -0,0 +1,167 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strings"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/tex/units"
+	"github.com/go-latex/latex/token"
+)
+
+// parseLengthArg parses a length argument, enclosed in braces or, for an
+// optional argument, in brackets.
+// The length is parsed into an *ast.Dimen, or into a \stretch call.
+// Otherwise, an error is reported and the argument is parsed as a list of
+// nodes.
+// parseLengthArg reports whether a mandatory argument could be parsed.
+func (p *parser) parseLengthArg(args *ast.List, optional bool) bool {
+	var (
+		ldelim = '{'
+		rdelim = token.Rbrace
+	)
+	if optional {
+		if p.s.peekRune() != '[' {
+			return true
+		}
+		ldelim, rdelim = '[', token.Rbrack
+	}
+	if !p.expect(ldelim) {
+		pos := p.s.tok.Pos
+		*args = append(*args, &ast.Bad{From: pos, To: pos})
+		return false
+	}
+
+	var (
+		lpos = p.s.tok.Pos
+		list ast.List
+	)
+	toks, rpos, ok := p.scanBalanced(rdelim)
+	switch dim, valid := parseDimen(toks); {
+	case !ok:
+		list = ast.List{&ast.Bad{From: lpos, To: rpos}}
+	case valid:
+		list = ast.List{dim}
+	default:
+		if !isStretch(toks) {
+			pos := rpos
+			if len(toks) > 0 {
+				pos = toks[0].Pos
+			}
+			p.errorf(pos, "invalid length %q", strings.TrimSpace(tokensText(toks)))
+		}
+		toks = append(toks, token.Token{Kind: rdelim, Pos: rpos, Text: closing[rdelim]})
+		p.s.push(toks, p.s.depth)
+		list, rpos = p.parseList(lpos, rdelim)
+	}
+
+	if optional {
+		*args = append(*args, &ast.OptArg{Lbrack: lpos, List: list, Rbrack: rpos})
+		return true
+	}
+	*args = append(*args, &ast.Arg{Lbrace: lpos, List: list, Rbrace: rpos})
+	return ok
+}
+
+// isStretch reports whether toks hold a \stretch{factor} glue, which is
+// parsed as a macro call.
+func isStretch(toks []token.Token) bool {
+	for _, tok := range toks {
+		if tok.Kind != token.Space {
+			return tok.Kind == token.Macro && tok.Text == `\stretch`
+		}
+	}
+	return false
+}
+
+// parseDimen parses toks as a TeX dimension, optionally followed by the
+// "plus" and "minus" components of a glue specification.
+// parseDimen reports whether toks hold a valid dimension.
+func parseDimen(toks []token.Token) (*ast.Dimen, bool) {
+	var rest []token.Token
+	for _, tok := range toks {
+		if tok.Kind != token.Space {
+			rest = append(rest, tok)
+		}
+	}
+
+	dim, rest, ok := scanDimen(rest)
+	if !ok {
+		return nil, false
+	}
+	if len(rest) > 0 && isKeyword(rest[0], "plus") {
+		dim.Plus, rest, ok = scanDimen(rest[1:])
+		if !ok {
+			return nil, false
+		}
+	}
+	if len(rest) > 0 && isKeyword(rest[0], "minus") {
+		dim.Minus, rest, ok = scanDimen(rest[1:])
+		if !ok {
+			return nil, false
+		}
+	}
+	return dim, len(rest) == 0
+}
+
+// scanDimen scans the leading dimension of toks: optional signs, a number
+// and a unit or a length macro.
+// A bare number, without unit, is accepted when it is followed by nothing
+// else than a glue component.
+// scanDimen returns the dimension and the remaining tokens.
+func scanDimen(toks []token.Token) (*ast.Dimen, []token.Token, bool) {
+	var (
+		dim  = new(ast.Dimen)
+		num  = false
+		i    = 0
+		text = new(strings.Builder)
+	)
+	for ; i < len(toks) && toks[i].Kind == token.Symbol && (toks[i].Text == "+" || toks[i].Text == "-"); i++ {
+		text.WriteString(toks[i].Text)
+	}
+	if i < len(toks) && toks[i].Kind == token.Number {
+		text.WriteString(toks[i].Text)
+		num = true
+		i++
+	}
+	if i == 0 && len(toks) > 0 && toks[0].Kind != token.Macro {
+		return nil, nil, false
+	}
+	if len(toks) > 0 {
+		dim.ValuePos = toks[0].Pos
+	}
+	dim.Value = text.String()
+
+	switch {
+	case i < len(toks) && toks[i].Kind == token.Macro:
+		dim.UnitPos = toks[i].Pos
+		dim.Unit = toks[i].Text
+		i++
+	case i < len(toks) && toks[i].Kind == token.Word && isUnit(toks[i].Text):
+		if !num {
+			return nil, nil, false
+		}
+		dim.UnitPos = toks[i].Pos
+		dim.Unit = toks[i].Text
+		i++
+	case num && (i == len(toks) || isKeyword(toks[i], "plus") || isKeyword(toks[i], "minus")):
+		dim.UnitPos = toks[i-1].Pos + token.Pos(len(toks[i-1].Text))
+	default:
+		return nil, nil, false
+	}
+	return dim, toks[i:], true
+}
+
+func isUnit(s string) bool {
+	_, err := units.ParseUnit(s)
+	return err == nil
+}
+
+// isKeyword reports whether tok is the TeX keyword kw.
+// As in TeX, keywords are case-insensitive.
+func isKeyword(tok token.Token, kw string) bool {
+	return tok.Kind == token.Word && strings.EqualFold(tok.Text, kw)
+}
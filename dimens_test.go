@@ -0,0 +1,182 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"testing"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+func TestParseDimen(t *testing.T) {
+	arg := func(nodes ...ast.Node) ast.Node { return &ast.Arg{List: nodes} }
+	opt := func(nodes ...ast.Node) ast.Node { return &ast.OptArg{List: nodes} }
+	macro := func(name string, args ...ast.Node) ast.Node {
+		return &ast.Macro{Name: &ast.Ident{Name: name}, Args: args}
+	}
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `\hspace{2.5em}`,
+			want:  ast.List{macro(`\hspace`, arg(&ast.Dimen{Value: "2.5", Unit: "em"}))},
+		},
+		{
+			input: `\hspace{ - 3 PT }`,
+			want:  ast.List{macro(`\hspace`, arg(&ast.Dimen{Value: "-3", Unit: "PT"}))},
+		},
+		{
+			input: `\hspace{1fil}`,
+			want:  ast.List{macro(`\hspace`, arg(&ast.Dimen{Value: "1", Unit: "fil"}))},
+		},
+		{
+			input: `\hspace{2}`,
+			want:  ast.List{macro(`\hspace`, arg(&ast.Dimen{Value: "2"}))},
+		},
+		{
+			input: `\hspace*{1cm}\vspace*{-2ex}\hspace{1cm}*`,
+			want: ast.List{
+				macro(`\hspace*`, arg(&ast.Dimen{Value: "1", Unit: "cm"})),
+				macro(`\vspace*`, arg(&ast.Dimen{Value: "-2", Unit: "ex"})),
+				macro(`\hspace`, arg(&ast.Dimen{Value: "1", Unit: "cm"})),
+				&ast.Symbol{Text: "*"},
+			},
+		},
+		{
+			input: `\vspace{0.5\textwidth}`,
+			want:  ast.List{macro(`\vspace`, arg(&ast.Dimen{Value: "0.5", Unit: `\textwidth`}))},
+		},
+		{
+			input: `\vspace{-\baselineskip}`,
+			want:  ast.List{macro(`\vspace`, arg(&ast.Dimen{Value: "-", Unit: `\baselineskip`}))},
+		},
+		{
+			input: `\vspace{1em plus 1fil minus 2pt}`,
+			want: ast.List{macro(`\vspace`, arg(&ast.Dimen{
+				Value: "1", Unit: "em",
+				Plus:  &ast.Dimen{Value: "1", Unit: "fil"},
+				Minus: &ast.Dimen{Value: "2", Unit: "pt"},
+			}))},
+		},
+		{
+			input: `\vspace{\fill minus .5ex}`,
+			want: ast.List{macro(`\vspace`, arg(&ast.Dimen{
+				Unit:  `\fill`,
+				Minus: &ast.Dimen{Value: ".5", Unit: "ex"},
+			}))},
+		},
+		{
+			input: `\hspace{\stretch{2}}`,
+			want: ast.List{macro(`\hspace`, arg(
+				macro(`\stretch`, arg(&ast.Literal{Text: "2"})),
+			))},
+		},
+		{
+			input: `\rule{1cm}{2mm}\rule[-1ex]{1in}{2bp}`,
+			want: ast.List{
+				macro(`\rule`,
+					arg(&ast.Dimen{Value: "1", Unit: "cm"}),
+					arg(&ast.Dimen{Value: "2", Unit: "mm"}),
+				),
+				macro(`\rule`,
+					opt(&ast.Dimen{Value: "-1", Unit: "ex"}),
+					arg(&ast.Dimen{Value: "1", Unit: "in"}),
+					arg(&ast.Dimen{Value: "2", Unit: "bp"}),
+				),
+			},
+		},
+		{
+			input: `$\raisebox{1ex}[0pt]{x}$`,
+			want: ast.List{&ast.MathExpr{List: ast.List{
+				macro(`\raisebox`,
+					arg(&ast.Dimen{Value: "1", Unit: "ex"}),
+					opt(&ast.Dimen{Value: "0", Unit: "pt"}),
+					arg(&ast.Word{Text: "x"}),
+				),
+			}}},
+		},
+		{
+			input: `\setlength{\parindent}{18mu}`,
+			want: ast.List{macro(`\setlength`,
+				arg(macro(`\parindent`)),
+				arg(&ast.Dimen{Value: "18", Unit: "mu"}),
+			)},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			p := NewParser()
+			p.RegisterMacro(`\parindent`, "")
+			node, err := p.ParseExpr(tc.input)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.input, err)
+			}
+			if got, want := sprint(node), sprint(tc.want); got != want {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+}
+
+func TestDimenPos(t *testing.T) {
+	node, err := ParseExpr(`\hspace{-2pt plus 1fil}`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+	dim := node.(ast.List)[0].(*ast.Macro).Args[0].(*ast.Arg).List[0].(*ast.Dimen)
+	for _, tc := range []struct {
+		name string
+		got  token.Pos
+		want token.Pos
+	}{
+		{"pos", dim.Pos(), 8},
+		{"unit", dim.UnitPos, 10},
+		{"plus", dim.Plus.Pos(), 18},
+		{"end", dim.End(), 22},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("invalid %s position: got=%d, want=%d", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestParseDimenErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `\hspace{pt}`,
+			want:  `1:9: invalid length "pt"`,
+		},
+		{
+			input: `\hspace{2pt plus}`,
+			want:  `1:9: invalid length "2pt plus"`,
+		},
+		{
+			input: `\vspace{}`,
+			want:  `1:9: invalid length ""`,
+		},
+		{
+			input: `\rule[x]{1pt}{2px}`,
+			want:  `1:7: invalid length "x" (and 1 more errors)`,
+		},
+		{
+			input: `\hspace{1em`,
+			want:  `1:8: missing closing "}"`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strings"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+// builtinEnv describes the arguments of an environment and how its body
+// should be parsed.
+type builtinEnv struct {
+	args     string // signature of the environment arguments. (see parser.parseArgs)
+	math     bool   // whether the body of the environment is in math mode.
+	verbatim bool   // whether the body of the environment is scanned verbatim.
+	expr     bool   // whether the environment is parsed into an ast.MathExpr.
+	display  bool   // whether the math is displayed, outside of math mode.
+	table    bool   // whether the environment is parsed into an ast.Table.
+	colspec  bool   // whether the last argument of the table is its column specification.
+}
+
+func (p *Parser) addBuiltinEnvs() {
+	p.envs = map[string]builtinEnv{
+		// text
+		"document":    {},
+		"abstract":    {},
+		"center":      {},
+		"flushleft":   {},
+		"flushright":  {},
+		"quote":       {},
+		"quotation":   {},
+		"verse":       {},
+		"itemize":     {},
+		"enumerate":   {args: "O"},
+		"description": {},
+		"list":        {args: "AA"},
+		"minipage":    {args: "OA"},
+		"proof":       {args: "O"},
+
+		"thebibliography": {args: "A"},
+
+		// verbatim
+		"verbatim":   {verbatim: true},
+		"verbatim*":  {verbatim: true},
+		"Verbatim":   {args: "K", verbatim: true},
+		"lstlisting": {args: "K", verbatim: true},
+		"minted":     {args: "KA", verbatim: true},
+		"comment":    {verbatim: true},
+
+		// floats
+		"figure":  {args: "O"},
+		"figure*": {args: "O"},
+		"table":   {args: "O"},
+		"table*":  {args: "O"},
+
+		// tables
+		"tabular":  {args: "OA", table: true, colspec: true},
+		"tabular*": {args: "AOA", table: true, colspec: true},
+
+		// math
+		"math":        {math: true, expr: true},
+		"displaymath": {math: true, expr: true, display: true},
+		"equation":    {math: true, expr: true, display: true},
+		"equation*":   {math: true, expr: true, display: true},
+		"eqnarray":    {math: true, display: true, table: true},
+		"eqnarray*":   {math: true, display: true, table: true},
+		"align":       {math: true, display: true, table: true},
+		"align*":      {math: true, display: true, table: true},
+		"alignat":     {args: "A", math: true, display: true, table: true},
+		"alignat*":    {args: "A", math: true, display: true, table: true},
+		"flalign":     {math: true, display: true, table: true},
+		"flalign*":    {math: true, display: true, table: true},
+		"gather":      {math: true, display: true, table: true},
+		"gather*":     {math: true, display: true, table: true},
+		"multline":    {math: true, display: true, table: true},
+		"multline*":   {math: true, display: true, table: true},
+		"array":       {args: "OA", math: true, table: true, colspec: true},
+		"aligned":     {args: "O", math: true, table: true},
+		"gathered":    {args: "O", math: true, table: true},
+		"split":       {math: true, table: true},
+		"cases":       {math: true, table: true},
+		"matrix":      {math: true, table: true},
+		"pmatrix":     {math: true, table: true},
+		"bmatrix":     {math: true, table: true},
+		"Bmatrix":     {math: true, table: true},
+		"vmatrix":     {math: true, table: true},
+		"Vmatrix":     {math: true, table: true},
+		"smallmatrix": {math: true, table: true},
+	}
+}
+
+// parseEnv parses a \begin{name} ... \end{name} environment.
+// Unknown environments are parsed without arguments, in the current mode.
+func (p *parser) parseEnv(tok token.Token) ast.Node {
+	name, rbrace, ok := p.parseEnvName()
+	if !ok {
+		return &ast.Bad{From: tok.Pos, To: rbrace + 1}
+	}
+
+	env := &ast.Env{
+		BeginPos: tok.Pos,
+		Name:     name,
+	}
+	spec := p.cfg.envs[name.Name]
+	p.parseArgs(spec.args, &env.Args)
+
+	if spec.verbatim {
+		if p.rawScanning() {
+			return p.parseVerbatimEnv(env)
+		}
+		p.errorf(tok.Pos, "verbatim environment %q not allowed inside a macro expansion", name.Name)
+	}
+
+	macros := p.openScope()
+	defer func() {
+		p.macros = macros
+	}()
+
+	if (spec.expr || spec.display) && p.state == mathState {
+		p.errorf(tok.Pos, "environment %q not allowed in math mode", name.Name)
+	}
+
+	if spec.math {
+		state := p.state
+		p.state = mathState
+		defer func() {
+			p.state = state
+		}()
+	}
+
+	if spec.table {
+		return p.parseTable(env, spec)
+	}
+
+	p.parseEnvBody(env)
+	if spec.expr {
+		return &ast.MathExpr{
+			Delim:   `\begin{` + name.Name + `}`,
+			Display: spec.display,
+			Left:    env.BeginPos,
+			List:    env.Body,
+			Right:   env.EndPos,
+		}
+	}
+	return env
+}
+
+// parseEnvBody parses the body of the environment env, up to and
+// including its \end{name} command.
+func (p *parser) parseEnvBody(env *ast.Env) {
+	var body paragraphs
+	for p.s.Next() {
+		tok := p.s.tok
+		switch {
+		case tok.Kind == token.Macro && tok.Text == `\end`:
+			env.Body = body.list()
+			p.parseEnvEnd(env, tok)
+			return
+		case p.parBreak(tok):
+			body.end()
+		default:
+			body.add(p.parseNode(tok))
+		}
+	}
+
+	env.EndPos = p.s.tok.Pos
+	body.add(&ast.Bad{From: env.EndPos, To: env.EndPos})
+	env.Body = body.list()
+	p.errorf(env.BeginPos, `missing \end{%s}`, env.Name.Name)
+}
+
+// parseEnvEnd parses the \end{name} command tok of the environment env.
+func (p *parser) parseEnvEnd(env *ast.Env, tok token.Token) {
+	end, rbrace, ok := p.parseEnvName()
+	env.EndPos = rbrace + 1
+	if ok && end.Name != env.Name.Name {
+		p.errorf(end.Pos(), `\end{%s} does not match \begin{%s}`, end.Name, env.Name.Name)
+	}
+}
+
+// parseVerbatimEnv parses the body of a verbatim environment, up to and
+// including its \end{name} command.
+func (p *parser) parseVerbatimEnv(env *ast.Env) ast.Node {
+	body, end, ok := p.s.scanVerbatimEnv(env.Name.Name)
+	env.Body = ast.List{&ast.Verbatim{VerbPos: body.Pos, Text: body.Text}}
+	env.EndPos = end
+	if ok {
+		env.EndPos += token.Pos(len(`\end{` + env.Name.Name + `}`))
+	} else {
+		env.Body = append(env.Body, &ast.Bad{From: end, To: end})
+		p.errorf(env.BeginPos, `missing \end{%s}`, env.Name.Name)
+	}
+	return env
+}
+
+// parseEnvName parses the {name} argument of \begin and \end.
+// parseEnvName returns the name, the position of the closing brace and
+// whether the name could be parsed.
+func (p *parser) parseEnvName() (*ast.Ident, token.Pos, bool) {
+	if !p.expect('{') {
+		pos := p.s.tok.Pos
+		return &ast.Ident{NamePos: pos}, pos, false
+	}
+
+	var (
+		lbrace = p.s.tok.Pos
+		name   = &ast.Ident{NamePos: lbrace + 1}
+		text   = new(strings.Builder)
+		ok     = true
+	)
+
+	for p.s.Next() {
+		tok := p.s.tok
+		switch tok.Kind {
+		case token.Rbrace:
+			name.Name = text.String()
+			if name.Name == "" && ok {
+				p.error(lbrace, "empty environment name")
+				ok = false
+			}
+			return name, tok.Pos, ok
+		case token.Word, token.Number, token.Symbol:
+			if text.Len() == 0 {
+				name.NamePos = tok.Pos
+			}
+			text.WriteString(tok.Text)
+		default:
+			if ok {
+				p.errorf(tok.Pos, "invalid token %q in environment name", tok.Text)
+			}
+			ok = false
+		}
+	}
+
+	p.error(lbrace, `missing closing "}"`)
+	name.Name = text.String()
+	return name, p.s.tok.Pos, false
+}
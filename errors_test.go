@@ -0,0 +1,52 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-latex/latex/token"
+)
+
+func TestErrorList(t *testing.T) {
+	var list ErrorList
+	if err := list.Err(); err != nil {
+		t.Fatalf("empty error list should be nil: %v", err)
+	}
+
+	list.Add(token.Position{Line: 2, Column: 3}, "b")
+	list.Add(token.Position{Line: 1, Column: 5}, "a")
+	list.Add(token.Position{Line: 2, Column: 1}, "c")
+
+	list.RemoveMultiples()
+	o := new(strings.Builder)
+	PrintError(o, list.Err())
+
+	want := "1:5: a\n2:1: c\n"
+	if got := o.String(); got != want {
+		t.Fatalf("invalid error list:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got, want := list.Error(), "1:5: a (and 1 more errors)"; got != want {
+		t.Fatalf("invalid error: got=%q, want=%q", got, want)
+	}
+
+	if got, want := (Error{Msg: "no position"}).Error(), "no position"; got != want {
+		t.Fatalf("invalid error: got=%q, want=%q", got, want)
+	}
+
+	o.Reset()
+	PrintError(o, fmt.Errorf("boom"))
+	if got, want := o.String(), "boom\n"; got != want {
+		t.Fatalf("invalid error: got=%q, want=%q", got, want)
+	}
+
+	list.Reset()
+	if list.Len() != 0 {
+		t.Fatalf("invalid length after reset: %d", list.Len())
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package expr provides the semantic tree of math formulas.
+//
+// The AST of a math formula is a flat list of symbols, macros and scripts.
+// The semantic tree of the formula groups them into operations applied to
+// their operands, following the usual precedence of math operators, e.g.
+// a+b\cdot c^2 is the sum of a and of the product of b by c squared.
+//
+// The precedence levels, from the lowest to the highest, are:
+//   - lists, e.g. a,b;
+//   - relations and arrows, e.g. a<b or x\to0;
+//   - sums and differences, e.g. a+b or A\cup B;
+//   - explicit products, e.g. a\cdot b, a/b or A\cap B;
+//   - signs, e.g. -a;
+//   - implicit products, e.g. 2x;
+//   - function applications and big operators, e.g. \sin x or \sum_i x_i;
+//   - sub- and superscripts, e.g. x^2.
+package expr // import "github.com/go-latex/latex/expr"
+
+import (
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+// Expr is a node of a semantic tree.
+type Expr interface {
+	Pos() token.Pos // position of first character belonging to the node
+	End() token.Pos // position of first character immediately after the node
+
+	isExpr()
+}
+
+// Op is an operator, a relation or a delimiter, as written in the source,
+// e.g. + or \leq.
+type Op struct {
+	OpPos token.Pos // position of the operator
+	Text  string    // operator text, or "" for an implicit product
+}
+
+func (op Op) Pos() token.Pos { return op.OpPos }
+func (op Op) End() token.Pos { return op.OpPos + token.Pos(len(op.Text)) }
+
+// Bad is a placeholder for a missing or malformed expression.
+type Bad struct {
+	From, To token.Pos
+}
+
+func (x *Bad) isExpr()        {}
+func (x *Bad) Pos() token.Pos { return x.From }
+func (x *Bad) End() token.Pos { return x.To }
+
+// Ident is a variable or a constant, e.g. x or \pi.
+type Ident struct {
+	NamePos token.Pos // identifier position
+	Name    string    // identifier name
+}
+
+func (x *Ident) isExpr()        {}
+func (x *Ident) Pos() token.Pos { return x.NamePos }
+func (x *Ident) End() token.Pos { return x.NamePos + token.Pos(len(x.Name)) }
+
+// Number is a numeric literal, e.g. 3.14.
+type Number struct {
+	ValuePos token.Pos // literal position
+	Value    string    // literal value
+}
+
+func (x *Number) isExpr()        {}
+func (x *Number) Pos() token.Pos { return x.ValuePos }
+func (x *Number) End() token.Pos { return x.ValuePos + token.Pos(len(x.Value)) }
+
+// Atom is an AST node with no further structure in the semantic tree,
+// e.g. \mathbf{v} or \text{if}.
+type Atom struct {
+	Node ast.Node
+}
+
+func (x *Atom) isExpr()        {}
+func (x *Atom) Pos() token.Pos { return x.Node.Pos() }
+func (x *Atom) End() token.Pos { return x.Node.End() }
+
+// Paren is an expression enclosed in delimiters, e.g. (a+b) or |x|.
+type Paren struct {
+	Left  Op   // opening delimiter
+	X     Expr // enclosed expression, or nil
+	Right Op   // closing delimiter
+}
+
+func (x *Paren) isExpr()        {}
+func (x *Paren) Pos() token.Pos { return x.Left.Pos() }
+func (x *Paren) End() token.Pos { return x.Right.End() }
+
+// Unary is a prefix operation, e.g. -x or \neg p.
+type Unary struct {
+	Op Op   // operator
+	X  Expr // operand
+}
+
+func (x *Unary) isExpr()        {}
+func (x *Unary) Pos() token.Pos { return x.Op.Pos() }
+func (x *Unary) End() token.Pos { return x.X.End() }
+
+// Binary is a binary operation, e.g. a+b, a\cdot b, or the implicit
+// product 2x, whose operator text is empty.
+type Binary struct {
+	X  Expr // left operand
+	Op Op   // operator
+	Y  Expr // right operand
+}
+
+func (x *Binary) isExpr()        {}
+func (x *Binary) Pos() token.Pos { return x.X.Pos() }
+func (x *Binary) End() token.Pos { return x.Y.End() }
+
+// Relation is a relation, or a chain of relations, between expressions,
+// e.g. a=b or 0<x\leq1.
+type Relation struct {
+	X   []Expr // related expressions
+	Ops []Op   // relations between consecutive expressions
+}
+
+func (x *Relation) isExpr()        {}
+func (x *Relation) Pos() token.Pos { return x.X[0].Pos() }
+func (x *Relation) End() token.Pos { return x.X[len(x.X)-1].End() }
+
+// Tuple is a list of expressions, e.g. the arguments a,b of f(a,b).
+type Tuple struct {
+	X    []Expr // expressions
+	Seps []Op   // separators between consecutive expressions
+}
+
+func (x *Tuple) isExpr()        {}
+func (x *Tuple) Pos() token.Pos { return x.X[0].Pos() }
+func (x *Tuple) End() token.Pos { return x.X[len(x.X)-1].End() }
+
+// Script is an expression with a subscript, a superscript or primes, e.g.
+// x_i, x^2 or f'.
+type Script struct {
+	X         Expr      // scripted expression, or nil, e.g. for {}^{14}C
+	Sub       Expr      // subscript, or nil
+	Sup       Expr      // superscript, or nil
+	Primes    int       // number of primes
+	ScriptPos token.Pos // position of the first script
+	EndPos    token.Pos // end of the last script
+}
+
+func (x *Script) isExpr() {}
+func (x *Script) Pos() token.Pos {
+	if x.X != nil {
+		return x.X.Pos()
+	}
+	return x.ScriptPos
+}
+func (x *Script) End() token.Pos { return x.EndPos }
+
+// Frac is a fraction, e.g. \frac{a}{b} or {a \over b}.
+type Frac struct {
+	Op     Op        // fraction macro
+	Num    Expr      // numerator
+	Den    Expr      // denominator
+	EndPos token.Pos // end of the fraction
+}
+
+func (x *Frac) isExpr() {}
+func (x *Frac) Pos() token.Pos {
+	if x.Op.Text == `\over` {
+		return x.Num.Pos()
+	}
+	return x.Op.Pos()
+}
+func (x *Frac) End() token.Pos { return x.EndPos }
+
+// Root is a root, e.g. \sqrt{x} or \sqrt[3]{x}.
+type Root struct {
+	Op     Op        // root macro
+	Index  Expr      // index of the root, or nil for a square root
+	X      Expr      // radicand
+	EndPos token.Pos // end of the root
+}
+
+func (x *Root) isExpr()        {}
+func (x *Root) Pos() token.Pos { return x.Op.Pos() }
+func (x *Root) End() token.Pos { return x.EndPos }
+
+// Call is the application of a named function to its argument, e.g.
+// \sin x or \log_2(n).
+type Call struct {
+	Func Expr // function, e.g. \sin, or \sin^2 for \sin^2 x
+	Arg  Expr // argument
+}
+
+func (x *Call) isExpr()        {}
+func (x *Call) Pos() token.Pos { return x.Func.Pos() }
+func (x *Call) End() token.Pos { return x.Arg.End() }
+
+// BigOp is a big operator applied to its operand, with its bounds, e.g.
+// \sum_{i=1}^n x_i, \int_0^1 f(x)dx or \lim_{x\to0} f(x).
+type BigOp struct {
+	Op     Op        // operator
+	Lower  Expr      // lower bound, or nil
+	Upper  Expr      // upper bound, or nil
+	X      Expr      // operand, or nil
+	EndPos token.Pos // end of the bounds
+}
+
+func (x *BigOp) isExpr()        {}
+func (x *BigOp) Pos() token.Pos { return x.Op.Pos() }
+func (x *BigOp) End() token.Pos {
+	if x.X != nil {
+		return x.X.End()
+	}
+	return x.EndPos
+}
+
+var (
+	_ Expr = (*Bad)(nil)
+	_ Expr = (*Ident)(nil)
+	_ Expr = (*Number)(nil)
+	_ Expr = (*Atom)(nil)
+	_ Expr = (*Paren)(nil)
+	_ Expr = (*Unary)(nil)
+	_ Expr = (*Binary)(nil)
+	_ Expr = (*Relation)(nil)
+	_ Expr = (*Tuple)(nil)
+	_ Expr = (*Script)(nil)
+	_ Expr = (*Frac)(nil)
+	_ Expr = (*Root)(nil)
+	_ Expr = (*Call)(nil)
+	_ Expr = (*BigOp)(nil)
+)
@@ -0,0 +1,652 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/internal/symbols"
+	"github.com/go-latex/latex/token"
+)
+
+// Error is an error in the structure of a math formula, e.g. a missing
+// operand.
+// The position Pos, if valid, points to the beginning of the offending
+// node.
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return "expr: " + e.Msg
+}
+
+// Parse returns the semantic tree of the math formula node.
+// node is either a *ast.MathExpr, or nodes in math mode, e.g. parsed by a
+// parser in math mode.
+//
+// The formula is canonicalized first, so notation variants share the same
+// semantic tree, e.g. \left(x\right) and (x), or x^{2} and x^2.
+// Named functions, e.g. \sin, are applied to their argument, while
+// juxtaposed identifiers, e.g. f(x), are multiplied: the semantic tree of
+// an unknown function application is an implicit product.
+//
+// Parse always returns a tree.
+// If the formula is malformed, the missing or malformed operands are
+// replaced with *Bad expressions, and the first error is returned.
+func Parse(node ast.Node) (Expr, error) {
+	if list, ok := node.(ast.List); ok && len(list) == 1 {
+		if expr, ok := list[0].(*ast.MathExpr); ok {
+			node = expr
+		}
+	}
+	if expr, ok := node.(*ast.MathExpr); ok {
+		node = expr.List
+	}
+	node = (&ast.Canonicalizer{Math: true}).Canonicalize(node)
+
+	list, ok := node.(ast.List)
+	if !ok {
+		list = ast.List{node}
+	}
+	p := &parser{errs: new(errors)}
+	x := p.sub(list)
+	if x == nil {
+		x = p.bad(node.Pos(), "empty formula")
+	}
+	if p.errs.err != nil {
+		return x, p.errs.err
+	}
+	return x, nil
+}
+
+type errors struct {
+	err *Error // first error
+}
+
+type parser struct {
+	list ast.List // nodes, with their words split into letters
+	i    int      // index of the current node
+	ambi []string // open ambiguous delimiters, e.g. |
+	errs *errors
+}
+
+// sub returns the expression of the nodes in list, or nil if list is
+// empty.
+func (p *parser) sub(list ast.List) Expr {
+	q := &parser{list: letters(list), errs: p.errs}
+	if len(q.list) == 0 {
+		return nil
+	}
+	x := q.tuple()
+	for q.i < len(q.list) {
+		node := q.list[q.i]
+		q.errorf(node.Pos(), "unexpected %s", text(node))
+		q.i++
+		x = &Binary{X: x, Y: &Bad{From: node.Pos(), To: node.End()}}
+	}
+	return x
+}
+
+func (p *parser) errorf(pos token.Pos, format string, args ...interface{}) {
+	if p.errs.err != nil {
+		return
+	}
+	p.errs.err = &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// bad returns a *Bad expression at pos, and reports the error msg.
+func (p *parser) bad(pos token.Pos, msg string) *Bad {
+	p.errorf(pos, "%s", msg)
+	return &Bad{From: pos, To: pos}
+}
+
+// peek returns the current node, or nil.
+func (p *parser) peek() ast.Node {
+	if p.i < len(p.list) {
+		return p.list[p.i]
+	}
+	return nil
+}
+
+// pos returns the position of the current node, or the end of the list.
+func (p *parser) pos() token.Pos {
+	if node := p.peek(); node != nil {
+		return node.Pos()
+	}
+	return p.list[len(p.list)-1].End()
+}
+
+// next returns the current node as an operator, and advances to the next
+// node.
+func (p *parser) next() Op {
+	node := p.list[p.i]
+	p.i++
+	if d, ok := node.(*ast.Delim); ok {
+		return Op{OpPos: d.DelimPos, Text: d.Text}
+	}
+	return Op{OpPos: node.Pos(), Text: name(node)}
+}
+
+// tuple parses a list of expressions, e.g. a,b.
+func (p *parser) tuple() Expr {
+	x := p.relation()
+	if !isSeparator(p.peek()) {
+		return x
+	}
+	tuple := &Tuple{X: []Expr{x}}
+	for isSeparator(p.peek()) {
+		tuple.Seps = append(tuple.Seps, p.next())
+		tuple.X = append(tuple.X, p.relation())
+	}
+	return tuple
+}
+
+// relation parses a chain of relations, e.g. a<b\leq c.
+func (p *parser) relation() Expr {
+	x := p.sum()
+	if !isRelation(p.peek()) {
+		return x
+	}
+	rel := &Relation{X: []Expr{x}}
+	for isRelation(p.peek()) {
+		rel.Ops = append(rel.Ops, p.next())
+		rel.X = append(rel.X, p.sum())
+	}
+	return rel
+}
+
+// sum parses sums and differences, e.g. a+b-c.
+func (p *parser) sum() Expr {
+	x := p.product()
+	for isAdditive(p.peek()) {
+		op := p.next()
+		x = &Binary{X: x, Op: op, Y: p.product()}
+	}
+	return x
+}
+
+// product parses explicit products, e.g. a\cdot b.
+func (p *parser) product() Expr {
+	x := p.unary()
+	for isMultiplicative(p.peek()) {
+		op := p.next()
+		x = &Binary{X: x, Op: op, Y: p.unary()}
+	}
+	return x
+}
+
+// unary parses signed expressions, e.g. -x.
+func (p *parser) unary() Expr {
+	if isPrefix(p.peek()) {
+		op := p.next()
+		return &Unary{Op: op, X: p.unary()}
+	}
+	return p.implicit(false)
+}
+
+// implicit parses implicit products, e.g. 2x.
+// If fn is true, the product stops before named functions and big
+// operators, e.g. the argument of \sin x\cos x is x.
+func (p *parser) implicit(fn bool) Expr {
+	x := p.postfix()
+	for p.isOperand(p.peek()) && !(fn && isFunc(p.peek())) {
+		x = &Binary{X: x, Y: p.postfix()}
+	}
+	return x
+}
+
+// postfix parses an operand with its sub- and superscripts, e.g. x_i^2.
+func (p *parser) postfix() Expr {
+	return p.scripts(p.operand())
+}
+
+// scripts parses the sub- and superscripts applied to x.
+func (p *parser) scripts(x Expr) Expr {
+	var s *Script
+	for {
+		switch node := p.peek().(type) {
+		case *ast.Sub:
+			if s != nil && s.Sub != nil {
+				// double subscript, e.g. x_i_j.
+				x, s = s, nil
+			}
+			if s == nil {
+				s = &Script{X: x, ScriptPos: node.Pos()}
+			}
+			p.i++
+			s.Sub = p.script(node.Node, node.Pos())
+			s.EndPos = node.End()
+
+		case *ast.Sup:
+			if s != nil && (s.Sup != nil || s.Primes > 0) {
+				x, s = s, nil
+			}
+			if s == nil {
+				s = &Script{X: x, ScriptPos: node.Pos()}
+			}
+			p.i++
+			list := script(node.Node)
+			for len(list) > 0 && name(list[0]) == "'" {
+				s.Primes++
+				list = list[1:]
+			}
+			if s.X == nil && s.Primes > 0 {
+				// unlike a superscript, e.g. of {}^{14}C, primes need an
+				// operand.
+				s.X = p.bad(node.Pos(), `missing operand before "'"`)
+			}
+			s.Sup = p.sub(list)
+			s.EndPos = node.End()
+
+		default:
+			if s == nil {
+				return x
+			}
+			return s
+		}
+	}
+}
+
+// script returns the expression of a sub- or superscript.
+func (p *parser) script(node ast.Node, pos token.Pos) Expr {
+	x := p.sub(script(node))
+	if x == nil {
+		x = p.bad(pos, "empty script")
+	}
+	return x
+}
+
+// operand parses an operand.
+// operand returns nil for the sub- and superscripts without operand, e.g.
+// the ones of {}^{14}C.
+func (p *parser) operand() Expr {
+	node := p.peek()
+	if node == nil || !p.isOperand(node) && !isOpen(node) {
+		switch node.(type) {
+		case *ast.Sub, *ast.Sup:
+			return nil
+		}
+		if node == nil {
+			return p.bad(p.pos(), "missing operand")
+		}
+		return p.bad(p.pos(), fmt.Sprintf("missing operand before %s", text(node)))
+	}
+	p.i++
+
+	switch node := node.(type) {
+	case *ast.Word:
+		return &Ident{NamePos: node.WordPos, Name: node.Text}
+
+	case *ast.Literal:
+		return &Number{ValuePos: node.LitPos, Value: node.Text}
+
+	case *ast.Group:
+		return p.group(node)
+
+	case *ast.Delimited:
+		paren := &Paren{
+			Left:  Op{OpPos: node.Left.DelimPos, Text: node.Left.Text},
+			X:     p.sub(node.Body),
+			Right: Op{OpPos: node.Right.DelimPos, Text: node.Right.Text},
+		}
+		return paren
+
+	case *ast.Symbol:
+		if isOpen(node) {
+			return p.paren()
+		}
+
+	case *ast.Macro:
+		if isOpen(node) {
+			// e.g. \langle or \vert.
+			return p.paren()
+		}
+		return p.macro(node)
+	}
+	return &Atom{Node: node}
+}
+
+// group parses the nodes of a group, e.g. {a \over b}.
+func (p *parser) group(g *ast.Group) Expr {
+	for i, node := range g.List {
+		if name(node) != `\over` {
+			continue
+		}
+		return &Frac{
+			Op:     Op{OpPos: node.Pos(), Text: `\over`},
+			Num:    p.operands(g.List[:i], g.Lbrace+1),
+			Den:    p.operands(g.List[i+1:], node.End()),
+			EndPos: g.End(),
+		}
+	}
+	if x := p.sub(g.List); x != nil {
+		return x
+	}
+	// empty group, e.g. of {}^{14}C.
+	return &Atom{Node: g}
+}
+
+// operands returns the expression of the nodes of list, which must not be
+// empty.
+func (p *parser) operands(list ast.List, pos token.Pos) Expr {
+	x := p.sub(list)
+	if x == nil {
+		x = p.bad(pos, "missing operand")
+	}
+	return x
+}
+
+// paren parses an expression enclosed in delimiters, whose opening
+// delimiter was just read.
+func (p *parser) paren() Expr {
+	left := p.list[p.i-1]
+	paren := &Paren{Left: Op{OpPos: left.Pos(), Text: name(left)}}
+	if isAmbi(left) {
+		p.ambi = append(p.ambi, paren.Left.Text)
+		defer func() { p.ambi = p.ambi[:len(p.ambi)-1] }()
+	}
+	if !p.isClose(p.peek()) {
+		paren.X = p.tuple()
+	}
+	if !p.isClose(p.peek()) {
+		p.errorf(p.pos(), "missing closing delimiter for %s", paren.Left.Text)
+		paren.Right = Op{OpPos: p.pos()}
+		return paren
+	}
+	paren.Right = p.next()
+	return paren
+}
+
+// macro parses a macro operand.
+func (p *parser) macro(m *ast.Macro) Expr {
+	op := Op{OpPos: m.Name.NamePos, Text: m.Name.Name}
+	switch {
+	case isBigOp(m) || isLimit(m) && isScript(p.peek()):
+		return p.bigOp(op)
+
+	case op.Text == `\operatorname` && len(m.Args) == 1:
+		return p.call(p.scripts(&Atom{Node: m}))
+
+	case isFunc(m):
+		var fn Expr = &Ident{NamePos: op.OpPos, Name: op.Text}
+		if len(m.Args) > 0 {
+			// e.g. \exp{x}.
+			return &Call{Func: fn, Arg: p.args(m)[0]}
+		}
+		return p.call(p.scripts(fn))
+
+	case op.Text == `\frac` && len(m.Args) == 2:
+		args := p.args(m)
+		return &Frac{Op: op, Num: args[0], Den: args[1], EndPos: m.End()}
+
+	case op.Text == `\sqrt`:
+		root := &Root{Op: op, EndPos: m.End()}
+		for i, arg := range p.args(m) {
+			switch m.Args[i].(type) {
+			case *ast.OptArg:
+				root.Index = arg
+			default:
+				root.X = arg
+			}
+		}
+		if root.X == nil {
+			root.X = p.bad(m.End(), "missing radicand")
+		}
+		return root
+
+	case len(m.Args) == 0:
+		return &Ident{NamePos: op.OpPos, Name: op.Text}
+	}
+	return &Atom{Node: m}
+}
+
+// args returns the expressions of the arguments of the macro m.
+func (p *parser) args(m *ast.Macro) []Expr {
+	args := make([]Expr, len(m.Args))
+	for i, arg := range m.Args {
+		var list ast.List
+		switch arg := arg.(type) {
+		case *ast.Arg:
+			list = arg.List
+		case *ast.OptArg:
+			list = arg.List
+		default:
+			list = ast.List{arg}
+		}
+		args[i] = p.operands(list, arg.Pos())
+	}
+	return args
+}
+
+// call parses the argument of the named function fn, e.g. the argument 2x
+// of \sin 2x, or (x) of \sin(x)y.
+func (p *parser) call(fn Expr) Expr {
+	switch node := p.peek(); {
+	case !p.isOperand(node):
+		// e.g. the \sin of f=\sin.
+		return fn
+	case isOpen(node):
+		return &Call{Func: fn, Arg: p.postfix()}
+	}
+	return &Call{Func: fn, Arg: p.implicit(true)}
+}
+
+// bigOp parses the bounds and the operand of the big operator op.
+func (p *parser) bigOp(op Op) Expr {
+	big := &BigOp{Op: op, EndPos: op.End()}
+	for isScript(p.peek()) {
+		switch node := p.peek().(type) {
+		case *ast.Sub:
+			if big.Lower != nil {
+				return big
+			}
+			p.i++
+			big.Lower = p.script(node.Node, node.Pos())
+		case *ast.Sup:
+			if big.Upper != nil {
+				return big
+			}
+			p.i++
+			big.Upper = p.script(node.Node, node.Pos())
+		}
+		big.EndPos = p.list[p.i-1].End()
+	}
+	if p.isOperand(p.peek()) || isPrefix(p.peek()) {
+		big.X = p.product()
+	}
+	return big
+}
+
+// isOperand reports whether node starts an operand, rather than being an
+// operator, a script or a closing delimiter.
+func (p *parser) isOperand(node ast.Node) bool {
+	switch node.(type) {
+	case nil, *ast.Sub, *ast.Sup, *ast.Delim:
+		return false
+	}
+	switch {
+	case isSeparator(node), isRelation(node), isAdditive(node),
+		isMultiplicative(node), p.isClose(node):
+		return false
+	}
+	return true
+}
+
+// isClose reports whether node closes the innermost delimiters.
+func (p *parser) isClose(node ast.Node) bool {
+	if isAmbi(node) {
+		n := len(p.ambi)
+		return n > 0 && p.ambi[n-1] == name(node)
+	}
+	name := name(node)
+	return symbols.RightDelim.Has(name) && !symbols.RelationSymbols.Has(name)
+}
+
+// letters returns the nodes of list, with its words split into
+// single-letter words, as each letter is a variable in math mode.
+func letters(list ast.List) ast.List {
+	var o ast.List
+	for _, node := range list {
+		w, ok := node.(*ast.Word)
+		if !ok {
+			o = append(o, node)
+			continue
+		}
+		for i, r := range w.Text {
+			o = append(o, &ast.Word{WordPos: w.WordPos + token.Pos(i), Text: string(r)})
+		}
+	}
+	return o
+}
+
+// script returns the nodes of a sub- or superscript.
+func script(node ast.Node) ast.List {
+	if list, ok := node.(ast.List); ok {
+		return list
+	}
+	return ast.List{node}
+}
+
+// name returns the text of a symbol, or the name of a macro without
+// arguments.
+func name(node ast.Node) string {
+	switch node := node.(type) {
+	case *ast.Symbol:
+		return node.Text
+	case *ast.Macro:
+		if len(node.Args) == 0 {
+			return node.Name.Name
+		}
+	}
+	return ""
+}
+
+// text returns a description of node, for error messages.
+func text(node ast.Node) string {
+	if name := name(node); name != "" {
+		return fmt.Sprintf("%q", name)
+	}
+	switch node := node.(type) {
+	case *ast.Delim:
+		return fmt.Sprintf("%q", node.Macro+node.Text)
+	case *ast.Sub:
+		return "subscript"
+	case *ast.Sup:
+		return "superscript"
+	}
+	return strings.ToLower(strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+}
+
+func isScript(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Sub, *ast.Sup:
+		return true
+	}
+	return false
+}
+
+func isSeparator(node ast.Node) bool {
+	switch name(node) {
+	case ",", ";":
+		return true
+	}
+	return false
+}
+
+// isRelation reports whether node is a relation, an arrow or a \middle
+// delimiter, e.g. the | of \left\{x \middle| x>0\right\}.
+func isRelation(node ast.Node) bool {
+	if _, ok := node.(*ast.Delim); ok {
+		return true
+	}
+	name := name(node)
+	switch name {
+	case `\dots`, `\ldots`:
+		return false
+	}
+	return symbols.RelationSymbols.Has(name) || symbols.ArrowSymbols.Has(name)
+}
+
+// additiveOps lists the binary operators with the precedence of the sum.
+var additiveOps = map[string]bool{
+	"+":         true,
+	"-":         true,
+	`\pm`:       true,
+	`\mp`:       true,
+	`\oplus`:    true,
+	`\ominus`:   true,
+	`\cup`:      true,
+	`\sqcup`:    true,
+	`\uplus`:    true,
+	`\vee`:      true,
+	`\setminus`: true,
+}
+
+func isAdditive(node ast.Node) bool {
+	return additiveOps[name(node)]
+}
+
+// isMultiplicative reports whether node is a binary operator with the
+// precedence of the product, e.g. \cdot, \cap or /.
+func isMultiplicative(node ast.Node) bool {
+	name := name(node)
+	if name == "/" {
+		return true
+	}
+	return symbols.BinaryOperators.Has(name) && !additiveOps[name]
+}
+
+// isPrefix reports whether node is a prefix operator, e.g. - or \neg.
+func isPrefix(node ast.Node) bool {
+	switch name(node) {
+	case "+", "-", `\pm`, `\mp`, `\neg`:
+		return true
+	}
+	return false
+}
+
+func isOpen(node ast.Node) bool {
+	name := name(node)
+	return isAmbi(node) ||
+		symbols.LeftDelim.Has(name) && !symbols.RelationSymbols.Has(name)
+}
+
+// isAmbi reports whether node is a delimiter which may both open and close
+// delimited expressions, e.g. |.
+func isAmbi(node ast.Node) bool {
+	switch name(node) {
+	case "|", `\|`, `\vert`, `\Vert`:
+		return true
+	}
+	return false
+}
+
+// isFunc reports whether node is a named function, e.g. \sin, or a big
+// operator.
+func isFunc(node ast.Node) bool {
+	m, ok := node.(*ast.Macro)
+	if !ok {
+		return false
+	}
+	return isBigOp(m) || symbols.FunctionNames.Has(strings.TrimPrefix(m.Name.Name, `\`)) ||
+		m.Name.Name == `\operatorname`
+}
+
+// isBigOp reports whether m is a big operator, e.g. \sum or \int.
+func isBigOp(m *ast.Macro) bool {
+	return len(m.Args) == 0 &&
+		(symbols.OverUnderSymbols.Has(m.Name.Name) || symbols.DropSubSymbols.Has(m.Name.Name))
+}
+
+// isLimit reports whether m is a function taking its bounds as a
+// subscript, e.g. \lim or \max.
+func isLimit(m *ast.Macro) bool {
+	return len(m.Args) == 0 && symbols.OverUnderFunctions.Has(strings.TrimPrefix(m.Name.Name, `\`))
+}
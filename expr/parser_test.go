@@ -0,0 +1,171 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package expr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+)
+
+func newParser() *latex.Parser {
+	p := latex.NewParser()
+	p.Mode = latex.MathMode
+	p.RegisterMacro(`\over`, "")
+	return p
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{`a+b\cdot c^2`, `(+ a (\cdot b (^ c 2)))`},
+		{`a-b+c`, `(+ (- a b) c)`},
+		{`2x+3y=\frac{1}{2}`, `(= (+ (mul 2 x) (mul 3 y)) (\frac 1 2))`},
+		{`-x^{2}-\left(a-b\right)`, `(- (- (^ x 2)) (paren ( (- a b) )))`},
+		{`a \cdot -b`, `(\cdot a (- b))`},
+		{`\neg p \wedge q`, `(\wedge (\neg p) q)`},
+		{`A\cup B\cap C`, `(\cup A (\cap B C))`},
+		{`e^{i\pi}+1=0`, `(= (+ (^ e (mul i \pi)) 1) 0)`},
+		{`\sin 2x \cos x`, `(mul (call \sin (mul 2 x)) (call \cos x))`},
+		{`\sin^2(x)y`, `(mul (call (^ \sin 2) (paren ( x ))) y)`},
+		{`\log_2 n`, `(call (_ \log 2) n)`},
+		{`\max(a,b)`, `(call \max (paren ( (tuple a b) )))`},
+		{`\exp{x}`, `(call \exp x)`},
+		{`\operatorname{tr} A`, `(call \operatorname{tr} A)`},
+		{`f(x)`, `(mul f (paren ( x )))`},
+		{`\sum_{i=1}^n a_i b_i + 1`, `(+ (\sum (= i 1) n (mul (_ a i) (_ b i))) 1)`},
+		{`\int_0^1 x^2 dx`, `(\int 0 1 (mul (mul (^ x 2) d) x))`},
+		{`\lim_{x\to0}\frac{\sin x}{x}=1`, `(= (\lim (\rightarrow x 0) nil (\frac (call \sin x) x)) 1)`},
+		{`0<x\leq 1, y\ne 2`, `(tuple (rel 0 < x \leq 1) (\neq y 2))`},
+		{`|x-y|\geq\sqrt[3]{z}`, `(\geq (paren | (- x y) |) (\sqrt 3 z))`},
+		{`[0,1)`, `(paren [ (tuple 0 1) ))`},
+		{`\left\{x \middle| x>0\right\}`, `(paren \{ (rel x | x > 0) \})`},
+		{`{a\over b+c}`, `(\over a (+ b c))`},
+		{`f'(x)+f''`, `(+ (mul (' f) (paren ( x ))) ('' f))`},
+		{`x^2_i`, `(_^ x i 2)`},
+		{`{}^{14}C`, `(mul (^ {} 14) C)`},
+		{`\mathbf{v}\cdot\mathbf{w}`, `(\cdot \mathbf{v} \mathbf{w})`},
+		{`\langle x, y\rangle`, `(paren \langle (tuple x y) \rangle)`},
+		{`\vert x\vert + \|y\|`, `(+ (paren \vert x \vert) (paren \| y \|))`},
+		{`\lfloor x\rfloor\lceil y\rceil`, `(mul (paren \lfloor x \rfloor) (paren \lceil y \rceil))`},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			node, err := newParser().ParseExpr(tc.input)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.input, err)
+			}
+			x, err := Parse(node)
+			if err != nil {
+				t.Fatalf("could not build semantic tree: %+v", err)
+			}
+			if got, want := sprint(x), tc.want; got != want {
+				t.Fatalf("invalid semantic tree:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+		err   string
+	}{
+		{`a+`, `(+ a BAD)`, `expr: missing operand`},
+		{`a=\cdot b`, `(= a (\cdot BAD b))`, `expr: missing operand before "\\cdot"`},
+		{`(a+b`, `(paren ( (+ a b) )`, `expr: missing closing delimiter for (`},
+		{`a)`, `(mul a BAD)`, `expr: unexpected ")"`},
+		{`\frac{}{x}`, `(\frac BAD x)`, `expr: missing operand`},
+		{`'`, `(' BAD)`, `expr: missing operand before "'"`},
+		{`a+''`, `(+ a ('' BAD))`, `expr: missing operand before "'"`},
+		{`\langle x`, `(paren \langle x )`, `expr: missing closing delimiter for \langle`},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			node, err := newParser().ParseExpr(tc.input)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.input, err)
+			}
+			x, err := Parse(node)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.err; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+			if got, want := sprint(x), tc.want; got != want {
+				t.Fatalf("invalid semantic tree:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseMathExpr(t *testing.T) {
+	const src = `The identity $\sin^2 x + \cos^2 x = 1$ holds.`
+	node, err := latex.NewParser().ParseExpr(src)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	var got []string
+	ast.Inspect(node, func(node ast.Node) bool {
+		expr, ok := node.(*ast.MathExpr)
+		if !ok {
+			return true
+		}
+		x, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("could not build semantic tree: %+v", err)
+		}
+		got = append(got, sprint(x))
+		return false
+	})
+	want := []string{`(= (+ (call (^ \sin 2) x) (call (^ \cos 2) x)) 1)`}
+	if got, want := strings.Join(got, "\n"), strings.Join(want, "\n"); got != want {
+		t.Fatalf("invalid semantic trees:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	const src = `\frac{a}{b} + \sqrt{x_1}`
+	node, err := newParser().ParseExpr(src)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+	x, err := Parse(node)
+	if err != nil {
+		t.Fatalf("could not build semantic tree: %+v", err)
+	}
+
+	var got []string
+	Inspect(x, func(x Expr) bool {
+		if x != nil {
+			got = append(got, src[x.Pos():x.End()])
+		}
+		return true
+	})
+	want := []string{
+		`\frac{a}{b} + \sqrt{x_1}`,
+		`\frac{a}{b}`,
+		`a`,
+		`b`,
+		`\sqrt{x_1}`,
+		`x_1`,
+		`x`,
+		`1`,
+	}
+	if got, want := strings.Join(got, "\n"), strings.Join(want, "\n"); got != want {
+		t.Fatalf("invalid positions:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func sprint(x Expr) string {
+	o := new(strings.Builder)
+	Print(o, x)
+	return o.String()
+}
@@ -0,0 +1,149 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package expr
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-latex/latex/ast"
+)
+
+// Print prints the semantic tree x to o, as an S-expression.
+//
+// Operations are printed as their operator followed by their operands,
+// e.g. (+ a (\cdot b c)) for a+b\cdot c, and implicit products as
+// (mul a b).
+// Relation chains are printed as (rel a < b \leq c), lists as
+// (tuple a b), delimited expressions as (paren ( x )), and function
+// applications as (call \sin x).
+// Scripts are printed as (_ x i), (^ x 2) or (_^ x i 2), with primes
+// as (' f), big operators as (\sum lower upper x), and missing operands
+// as nil.
+func Print(o io.Writer, x Expr) {
+	switch x := x.(type) {
+	case nil:
+		fmt.Fprintf(o, "nil")
+
+	case *Bad:
+		fmt.Fprintf(o, "BAD")
+
+	case *Ident:
+		fmt.Fprintf(o, "%s", x.Name)
+
+	case *Number:
+		fmt.Fprintf(o, "%s", x.Value)
+
+	case *Atom:
+		src := new(strings.Builder)
+		err := (&ast.Printer{Math: true}).Fprint(src, x.Node)
+		if err != nil {
+			fmt.Fprintf(o, "BAD")
+			return
+		}
+		fmt.Fprintf(o, "%s", src)
+
+	case *Paren:
+		fmt.Fprintf(o, "(paren %s ", x.Left.Text)
+		Print(o, x.X)
+		fmt.Fprintf(o, " %s)", x.Right.Text)
+
+	case *Unary:
+		fmt.Fprintf(o, "(%s ", x.Op.Text)
+		Print(o, x.X)
+		fmt.Fprintf(o, ")")
+
+	case *Binary:
+		op := x.Op.Text
+		if op == "" {
+			op = "mul"
+		}
+		fmt.Fprintf(o, "(%s ", op)
+		Print(o, x.X)
+		fmt.Fprintf(o, " ")
+		Print(o, x.Y)
+		fmt.Fprintf(o, ")")
+
+	case *Relation:
+		switch len(x.Ops) {
+		case 1:
+			fmt.Fprintf(o, "(%s ", x.Ops[0].Text)
+			Print(o, x.X[0])
+			fmt.Fprintf(o, " ")
+			Print(o, x.X[1])
+		default:
+			fmt.Fprintf(o, "(rel ")
+			Print(o, x.X[0])
+			for i, op := range x.Ops {
+				fmt.Fprintf(o, " %s ", op.Text)
+				Print(o, x.X[i+1])
+			}
+		}
+		fmt.Fprintf(o, ")")
+
+	case *Tuple:
+		fmt.Fprintf(o, "(tuple")
+		for _, x := range x.X {
+			fmt.Fprintf(o, " ")
+			Print(o, x)
+		}
+		fmt.Fprintf(o, ")")
+
+	case *Script:
+		op := ""
+		if x.Sub != nil {
+			op += "_"
+		}
+		op += strings.Repeat("'", x.Primes)
+		if x.Sup != nil {
+			op += "^"
+		}
+		fmt.Fprintf(o, "(%s ", op)
+		Print(o, x.X)
+		for _, script := range []Expr{x.Sub, x.Sup} {
+			if script != nil {
+				fmt.Fprintf(o, " ")
+				Print(o, script)
+			}
+		}
+		fmt.Fprintf(o, ")")
+
+	case *Frac:
+		fmt.Fprintf(o, "(%s ", x.Op.Text)
+		Print(o, x.Num)
+		fmt.Fprintf(o, " ")
+		Print(o, x.Den)
+		fmt.Fprintf(o, ")")
+
+	case *Root:
+		fmt.Fprintf(o, "(%s ", x.Op.Text)
+		if x.Index != nil {
+			Print(o, x.Index)
+			fmt.Fprintf(o, " ")
+		}
+		Print(o, x.X)
+		fmt.Fprintf(o, ")")
+
+	case *Call:
+		fmt.Fprintf(o, "(call ")
+		Print(o, x.Func)
+		fmt.Fprintf(o, " ")
+		Print(o, x.Arg)
+		fmt.Fprintf(o, ")")
+
+	case *BigOp:
+		fmt.Fprintf(o, "(%s ", x.Op.Text)
+		Print(o, x.Lower)
+		fmt.Fprintf(o, " ")
+		Print(o, x.Upper)
+		fmt.Fprintf(o, " ")
+		Print(o, x.X)
+		fmt.Fprintf(o, ")")
+
+	default:
+		panic(fmt.Errorf("expr: unknown expression %T", x))
+	}
+}
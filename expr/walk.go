@@ -0,0 +1,100 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package expr
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each expression encountered by
+// Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of x with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(x Expr) (w Visitor)
+}
+
+// Walk traverses a semantic tree in depth-first order: It starts by calling
+// v.Visit(x); x must not be nil. If the visitor w returned by v.Visit(x)
+// is not nil, Walk is invoked recursively with visitor w for each of the
+// non-nil children of x, followed by a call of w.Visit(nil).
+func Walk(v Visitor, x Expr) {
+	if v = v.Visit(x); v == nil {
+		return
+	}
+
+	switch x := x.(type) {
+	case *Bad, *Ident, *Number, *Atom:
+		// nothing to do.
+
+	case *Paren:
+		walk(v, x.X)
+
+	case *Unary:
+		walk(v, x.X)
+
+	case *Binary:
+		walk(v, x.X)
+		walk(v, x.Y)
+
+	case *Relation:
+		for _, x := range x.X {
+			walk(v, x)
+		}
+
+	case *Tuple:
+		for _, x := range x.X {
+			walk(v, x)
+		}
+
+	case *Script:
+		walk(v, x.X)
+		walk(v, x.Sub)
+		walk(v, x.Sup)
+
+	case *Frac:
+		walk(v, x.Num)
+		walk(v, x.Den)
+
+	case *Root:
+		walk(v, x.Index)
+		walk(v, x.X)
+
+	case *Call:
+		walk(v, x.Func)
+		walk(v, x.Arg)
+
+	case *BigOp:
+		walk(v, x.Lower)
+		walk(v, x.Upper)
+		walk(v, x.X)
+
+	default:
+		panic(fmt.Errorf("expr.Walk: unexpected expression type %T", x))
+	}
+
+	v.Visit(nil)
+}
+
+func walk(v Visitor, x Expr) {
+	if x != nil {
+		Walk(v, x)
+	}
+}
+
+type inspector func(Expr) bool
+
+func (f inspector) Visit(x Expr) Visitor {
+	if f(x) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a semantic tree in depth-first order: It starts by
+// calling f(x); x must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of x, followed by a call
+// of f(nil).
+func Inspect(x Expr, f func(Expr) bool) {
+	Walk(inspector(f), x)
+}
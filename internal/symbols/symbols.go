@@ -0,0 +1,17 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package symbols holds the tables of TeX math symbols shared by the
+// parser, the syntax tree tools and the renderer.
+package symbols // import "github.com/go-latex/latex/internal/symbols"
+
+//go:generate go run ./gen-symbols.go
+
+var (
+	SpacedSymbols = UnionOf(BinaryOperators, RelationSymbols, ArrowSymbols)
+)
+
+func IsSpaced(s string) bool {
+	return SpacedSymbols.Has(s)
+}
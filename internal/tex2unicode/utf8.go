@@ -8,6 +8,7 @@ package tex2unicode // import "github.com/go-latex/latex/internal/tex2unicode"
 import (
 	"fmt"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -29,11 +30,8 @@ func Index(v string, math bool) rune {
 		return 0x2212
 	}
 
-	if len(v) == 1 {
-		r, _ := utf8.DecodeRune([]byte(v))
-		if r != utf8.RuneError {
-			return r
-		}
+	if r, size := utf8.DecodeRuneInString(v); r != utf8.RuneError && size == len(v) {
+		return r
 	}
 
 	r, ok := tex2uni[strings.Replace(v, `\`, "", 1)]
@@ -57,6 +55,35 @@ func Symbols() []string {
 	return names
 }
 
+// Name returns the name of the TeX math symbol associated with the unicode
+// rune r, e.g. "alpha" for 'α'.
+//
+// ASCII and Latin characters, as well as combining marks, have no
+// associated name.
+// When several symbols are associated with r, the shortest name is
+// returned, e.g. "ne" rather than "neq" for '≠'.
+func Name(r rune) (string, bool) {
+	name, ok := uni2tex[r]
+	return name, ok
+}
+
+var uni2tex = func() map[rune]string {
+	db := make(map[rune]string, len(tex2uni))
+	for name, r := range tex2uni {
+		switch {
+		case r < utf8.RuneSelf,
+			unicode.Is(unicode.Latin, r),
+			unicode.In(r, unicode.Mn, unicode.Me):
+			continue
+		}
+		if cur, dup := db[r]; dup && (len(cur) < len(name) || len(cur) == len(name) && cur < name) {
+			continue
+		}
+		db[r] = name
+	}
+	return db
+}()
+
 var (
 	tex2uni = map[string]rune{
 		`widehat`:                  0x0302,
@@ -587,6 +614,7 @@ var (
 		`}`:                        125,
 		`_`:                        95,
 		`#`:                        35,
+		`&`:                        38,
 		`imath`:                    0x131,
 		`circumflexaccent`:         770,
 		`combiningbreve`:           774,
@@ -52,6 +52,8 @@ func TestIndex(t *testing.T) {
 		{v: `\l`, want: 'ł', math: true},
 		{v: `\L`, want: 'Ł', math: true},
 		{v: `\ast`, want: '∗', math: true},
+		{v: `∷`, want: '∷', math: true},
+		{v: `⇗`, want: '⇗'},
 	} {
 		t.Run(tc.v, func(t *testing.T) {
 			got := Index(tc.v, tc.math)
@@ -78,3 +80,27 @@ func TestHasSymbol(t *testing.T) {
 		})
 	}
 }
+
+func TestName(t *testing.T) {
+	for _, tc := range []struct {
+		r    rune
+		want string
+	}{
+		{'α', "alpha"},
+		{'≤', "leq"},
+		{'∑', "sum"},
+		{'≠', "ne"},
+		{'∅', "emptyset"},
+		{'⟨', "langle"},
+		{'|', ""},
+		{'ß', ""},
+		{'\u0302', ""},
+	} {
+		t.Run(string(tc.r), func(t *testing.T) {
+			got, ok := Name(tc.r)
+			if got != tc.want || ok != (tc.want != "") {
+				t.Fatalf("got=(%q, %v), want=%q", got, ok, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strings"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+// parseKeyValArg parses an optional argument holding a comma-separated
+// list of key-value options, e.g. [width=3cm,angle=90].
+// The options are parsed into an *ast.KeyVal, enclosed in an *ast.OptArg.
+func (p *parser) parseKeyValArg(args *ast.List) {
+	// see parseOptMacroArg.
+	if p.s.peekRune() != '[' {
+		return
+	}
+
+	p.expect('[')
+	var (
+		opt  = &ast.OptArg{Lbrack: p.s.tok.Pos}
+		ok   bool
+		toks []token.Token
+	)
+	toks, opt.Rbrack, ok = p.scanBalanced(token.Rbrack)
+	*args = append(*args, opt)
+	if !ok {
+		opt.List = ast.List{&ast.Bad{From: opt.Lbrack, To: opt.Rbrack}}
+		return
+	}
+
+	kv := new(ast.KeyVal)
+	for _, entry := range splitTokens(toks, ",") {
+		entry = trimSpaces(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		var (
+			key   = entry
+			value []token.Token
+			pair  ast.Pair
+		)
+		if i := indexToken(entry, "="); i >= 0 {
+			key = trimSpaces(entry[:i])
+			value = trimSpaces(entry[i+1:])
+			pair.Assign = entry[i].Pos
+			end := opt.Rbrack
+			if len(value) > 0 {
+				end = value[len(value)-1].Pos + token.Pos(len(value[len(value)-1].Text))
+			}
+			pair.Value = p.parseKeyValue(value, end)
+		}
+		if len(key) == 0 {
+			p.errorf(entry[0].Pos, "missing key in option %q", strings.TrimSpace(tokensText(entry)))
+			continue
+		}
+		pair.KeyPos = key[0].Pos
+		pair.Key = tokensText(key)
+		kv.Pairs = append(kv.Pairs, &pair)
+	}
+	opt.List = ast.List{kv}
+}
+
+// parseKeyValue parses the tokens of the value of an option, ending at
+// the end position.
+// Values made of a number and a unit, e.g. 3cm, or of an optional factor
+// and a known length register, e.g. 0.5\textwidth, are parsed into an
+// *ast.Dimen.
+func (p *parser) parseKeyValue(toks []token.Token, end token.Pos) ast.List {
+	if dim, ok := parseDimen(toks); ok && p.isKeyValUnit(dim.Unit) {
+		return ast.List{dim}
+	}
+	if len(toks) == 0 {
+		return ast.List{}
+	}
+	toks = append(toks, token.Token{Kind: token.Rbrack, Pos: end, Text: "]"})
+	p.s.push(toks, p.s.depth)
+	list, _ := p.parseList(toks[0].Pos, token.Rbrack)
+	return list
+}
+
+// isKeyValUnit reports whether unit is a unit of a dimension value: a
+// physical unit, e.g. cm, or a length register known to the parser, e.g.
+// \textwidth.
+func (p *parser) isKeyValUnit(unit string) bool {
+	if strings.HasPrefix(unit, `\`) {
+		_, ok := p.macro(unit)
+		return ok
+	}
+	return isUnit(unit)
+}
+
+// splitTokens splits toks at each symbol sep that is not enclosed in
+// braces.
+func splitTokens(toks []token.Token, sep string) [][]token.Token {
+	var (
+		o     [][]token.Token
+		depth = 0
+		beg   = 0
+	)
+	for i, tok := range toks {
+		switch {
+		case tok.Kind == token.Lbrace:
+			depth++
+		case tok.Kind == token.Rbrace:
+			depth--
+		case depth == 0 && tok.Kind == token.Symbol && tok.Text == sep:
+			o = append(o, toks[beg:i])
+			beg = i + 1
+		}
+	}
+	return append(o, toks[beg:])
+}
+
+// indexToken returns the index of the first symbol sep of toks that is not
+// enclosed in braces, or -1.
+func indexToken(toks []token.Token, sep string) int {
+	if parts := splitTokens(toks, sep); len(parts) > 1 {
+		return len(parts[0])
+	}
+	return -1
+}
+
+// trimSpaces returns toks without its leading and trailing space tokens.
+func trimSpaces(toks []token.Token) []token.Token {
+	for len(toks) > 0 && toks[0].Kind == token.Space {
+		toks = toks[1:]
+	}
+	for len(toks) > 0 && toks[len(toks)-1].Kind == token.Space {
+		toks = toks[:len(toks)-1]
+	}
+	return toks
+}
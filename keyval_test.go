@@ -0,0 +1,206 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"testing"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+func TestParseKeyVal(t *testing.T) {
+	kv := func(pairs ...*ast.Pair) ast.Node {
+		return &ast.OptArg{List: ast.List{&ast.KeyVal{Pairs: pairs}}}
+	}
+	arg := func(nodes ...ast.Node) ast.Node { return &ast.Arg{List: nodes} }
+	macro := func(name string, args ...ast.Node) ast.Node {
+		return &ast.Macro{Name: &ast.Ident{Name: name}, Args: args}
+	}
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `\includegraphics[width=3cm,angle=90]{img}`,
+			want: ast.List{macro(`\includegraphics`,
+				kv(
+					&ast.Pair{Key: "width", Value: ast.List{&ast.Dimen{Value: "3", Unit: "cm"}}},
+					&ast.Pair{Key: "angle", Value: ast.List{&ast.Literal{Text: "90"}}},
+				),
+				arg(&ast.Word{Text: "img"}),
+			)},
+		},
+		{
+			input: `\includegraphics[width=0.5\textwidth]{a}\includegraphics[width=\linewidth]{a}`,
+			want: ast.List{
+				macro(`\includegraphics`,
+					kv(&ast.Pair{Key: "width", Value: ast.List{&ast.Dimen{Value: "0.5", Unit: `\textwidth`}}}),
+					arg(&ast.Word{Text: "a"}),
+				),
+				macro(`\includegraphics`,
+					kv(&ast.Pair{Key: "width", Value: ast.List{&ast.Dimen{Unit: `\linewidth`}}}),
+					arg(&ast.Word{Text: "a"}),
+				),
+			},
+		},
+		{
+			input: `\includegraphics{img}`,
+			want:  ast.List{macro(`\includegraphics`, arg(&ast.Word{Text: "img"}))},
+		},
+		{
+			input: `\SI[per-mode=symbol]{3}{\metre}`,
+			want: ast.List{macro(`\SI`,
+				kv(&ast.Pair{Key: "per-mode", Value: ast.List{&ast.Word{Text: "symbol"}}}),
+				arg(&ast.Literal{Text: "3"}),
+				arg(macro(`\metre`)),
+			)},
+		},
+		{
+			input: `\includegraphics[ draft , trim = 1 2 3 4, clip ,]{img}`,
+			want: ast.List{macro(`\includegraphics`,
+				kv(
+					&ast.Pair{Key: "draft"},
+					&ast.Pair{Key: "trim", Value: ast.List{
+						&ast.Literal{Text: "1"},
+						&ast.Symbol{Text: " "},
+						&ast.Literal{Text: "2"},
+						&ast.Symbol{Text: " "},
+						&ast.Literal{Text: "3"},
+						&ast.Symbol{Text: " "},
+						&ast.Literal{Text: "4"},
+					}},
+					&ast.Pair{Key: "clip"},
+				),
+				arg(&ast.Word{Text: "img"}),
+			)},
+		},
+		{
+			input: `\includegraphics[page={1,2},line width=0.5\textwidth,alt=,x=a=b]{img}`,
+			want: ast.List{macro(`\includegraphics`,
+				kv(
+					&ast.Pair{Key: "page", Value: ast.List{&ast.Group{List: ast.List{
+						&ast.Literal{Text: "1"},
+						&ast.Symbol{Text: ","},
+						&ast.Literal{Text: "2"},
+					}}}},
+					&ast.Pair{Key: "line width", Value: ast.List{
+						&ast.Dimen{Value: "0.5", Unit: `\textwidth`},
+					}},
+					&ast.Pair{Key: "alt", Value: ast.List{}},
+					&ast.Pair{Key: "x", Value: ast.List{
+						&ast.Word{Text: "a"},
+						&ast.Symbol{Text: "="},
+						&ast.Word{Text: "b"},
+					}},
+				),
+				arg(&ast.Word{Text: "img"}),
+			)},
+		},
+		{
+			input: "\\begin{lstlisting}[language=Go, caption={a, b}]\nx\n\\end{lstlisting}",
+			want: ast.List{&ast.Env{
+				Name: &ast.Ident{Name: "lstlisting"},
+				Args: ast.List{kv(
+					&ast.Pair{Key: "language", Value: ast.List{&ast.Word{Text: "Go"}}},
+					&ast.Pair{Key: "caption", Value: ast.List{&ast.Group{List: ast.List{
+						&ast.Word{Text: "a"},
+						&ast.Symbol{Text: ","},
+						&ast.Symbol{Text: " "},
+						&ast.Word{Text: "b"},
+					}}}},
+				)},
+				Body: ast.List{&ast.Verbatim{Text: "\nx\n"}},
+			}},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			p := NewParser()
+			p.RegisterMacro(`\SI`, "KAA")
+			p.RegisterMacro(`\metre`, "")
+			node, err := p.ParseExpr(tc.input)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.input, err)
+			}
+			if got, want := sprint(node), sprint(tc.want); got != want {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+}
+
+func TestKeyVal(t *testing.T) {
+	node, err := ParseExpr(`\includegraphics[draft, scale=2, alt=, scale = 3]{img}`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+	kv := node.(ast.List)[0].(*ast.Macro).Args[0].(*ast.OptArg).List[0].(*ast.KeyVal)
+
+	for _, tc := range []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"scale", `ast.List{ast.Lit{"3"}}`, true},
+		{"draft", `ast.List{}`, true},
+		{"alt", `ast.List{}`, true},
+		{"width", `ast.List{}`, false},
+	} {
+		v, ok := kv.Lookup(tc.key)
+		if ok != tc.ok {
+			t.Errorf("invalid lookup of %q: got=%v, want=%v", tc.key, ok, tc.ok)
+		}
+		if got, want := sprint(v), tc.want; got != want {
+			t.Errorf("invalid value of %q: got=%s, want=%s", tc.key, got, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		got  token.Pos
+		want token.Pos
+	}{
+		{"pos", kv.Pos(), 17},
+		{"key", kv.Pairs[1].KeyPos, 24},
+		{"assign", kv.Pairs[1].Assign, 29},
+		{"draft", kv.Pairs[0].End(), 22},
+		{"alt", kv.Pairs[2].End(), 37},
+		{"end", kv.End(), 48},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("invalid %s position: got=%d, want=%d", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestParseKeyValErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `\includegraphics[a,=3]{img}`,
+			want:  `1:20: missing key in option "=3"`,
+		},
+		{
+			input: `\includegraphics[a=\unknown]{img}`,
+			want:  `1:20: unknown macro "\\unknown"`,
+		},
+		{
+			input: `\includegraphics[a=b`,
+			want:  `1:17: missing closing "]" (and 1 more errors)`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
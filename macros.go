@@ -9,14 +9,30 @@ import (
 
 	"github.com/go-latex/latex/ast"
 	"github.com/go-latex/latex/internal/tex2unicode"
+	"github.com/go-latex/latex/token"
 )
 
 type macroParser interface {
 	parseMacro(p *parser) ast.Node
 }
 
-func (p *parser) addBuiltinMacros() {
-	p.macros = map[string]macroParser{
+func (p *Parser) addBuiltinMacros() {
+	p.macros = builtinMacros()
+
+	// add all known UTF-8 symbols
+	for _, k := range tex2unicode.Symbols() {
+		_, ok := p.macros[`\`+k]
+		if ok {
+			continue
+		}
+		p.macros[`\`+k] = builtinMacro("")
+	}
+}
+
+// builtinMacros returns the LaTeX macros known to the parser, besides the
+// names of the Unicode math symbols.
+func builtinMacros() map[string]macroParser {
+	return map[string]macroParser{
 		// binary operators
 		`\amalg`:           builtinMacro(""),
 		`\ast`:             builtinMacro(""),
@@ -75,13 +91,16 @@ func (p *parser) addBuiltinMacros() {
 		`\dots`:       builtinMacro(""),
 		`\equiv`:      builtinMacro(""),
 		`\frown`:      builtinMacro(""),
+		`\ge`:         builtinMacro(""),
 		`\geq`:        builtinMacro(""),
 		`\gg`:         builtinMacro(""),
 		`\in`:         builtinMacro(""),
+		`\le`:         builtinMacro(""),
 		`\leq`:        builtinMacro(""),
 		`\ll`:         builtinMacro(""),
 		`\mid`:        builtinMacro(""),
 		`\models`:     builtinMacro(""),
+		`\ne`:         builtinMacro(""),
 		`\neq`:        builtinMacro(""),
 		`\ni`:         builtinMacro(""),
 		`\parallel`:   builtinMacro(""),
@@ -127,6 +146,7 @@ func (p *parser) addBuiltinMacros() {
 		`\rightleftharpoons`:  builtinMacro(""),
 		`\searrow`:            builtinMacro(""),
 		`\swarrow`:            builtinMacro(""),
+		`\to`:                 builtinMacro(""),
 		`\uparrow`:            builtinMacro(""),
 		`\updownarrow`:        builtinMacro(""),
 		`\Downarrow`:          builtinMacro(""),
@@ -218,14 +238,12 @@ func (p *parser) addBuiltinMacros() {
 
 		// left delim
 		`\{`:      builtinMacro(""),
-		`\(`:      builtinMacro(""),
 		`\langle`: builtinMacro(""),
 		`\lceil`:  builtinMacro(""),
 		`\lfloor`: builtinMacro(""),
 
 		// right delim
 		`\}`:      builtinMacro(""),
-		`\)`:      builtinMacro(""),
 		`\rangle`: builtinMacro(""),
 		`\rceil`:  builtinMacro(""),
 		`\rfloor`: builtinMacro(""),
@@ -282,6 +300,215 @@ func (p *parser) addBuiltinMacros() {
 		`\hbar`:    builtinMacro(""),
 		`\nabla`:   builtinMacro(""),
 
+		// ordinary symbols
+		`\Im`:          builtinMacro(""),
+		`\Re`:          builtinMacro(""),
+		`\aleph`:       builtinMacro(""),
+		`\angle`:       builtinMacro(""),
+		`\bot`:         builtinMacro(""),
+		`\clubsuit`:    builtinMacro(""),
+		`\dag`:         builtinMacro(""),
+		`\ddag`:        builtinMacro(""),
+		`\diamondsuit`: builtinMacro(""),
+		`\ell`:         builtinMacro(""),
+		`\emptyset`:    builtinMacro(""),
+		`\exists`:      builtinMacro(""),
+		`\flat`:        builtinMacro(""),
+		`\forall`:      builtinMacro(""),
+		`\heartsuit`:   builtinMacro(""),
+		`\hslash`:      builtinMacro(""),
+		`\iiint`:       builtinMacro(""),
+		`\iint`:        builtinMacro(""),
+		`\infty`:       builtinMacro(""),
+		`\mho`:         builtinMacro(""),
+		`\natural`:     builtinMacro(""),
+		`\neg`:         builtinMacro(""),
+		`\notin`:       builtinMacro(""),
+		`\partial`:     builtinMacro(""),
+		`\prime`:       builtinMacro(""),
+		`\sharp`:       builtinMacro(""),
+		`\spadesuit`:   builtinMacro(""),
+		`\top`:         builtinMacro(""),
+		`\varkappa`:    builtinMacro(""),
+		`\varnothing`:  builtinMacro(""),
+		`\varphi`:      builtinMacro(""),
+		`\varpi`:       builtinMacro(""),
+		`\varrho`:      builtinMacro(""),
+		`\varsigma`:    builtinMacro(""),
+		`\vartheta`:    builtinMacro(""),
+		`\wp`:          builtinMacro(""),
+
+		// symbols of the amssymb package
+		`\Bumpeq`:              builtinMacro(""),
+		`\Cap`:                 builtinMacro(""),
+		`\Cup`:                 builtinMacro(""),
+		`\Game`:                builtinMacro(""),
+		`\Lleftarrow`:          builtinMacro(""),
+		`\Lsh`:                 builtinMacro(""),
+		`\Rrightarrow`:         builtinMacro(""),
+		`\Rsh`:                 builtinMacro(""),
+		`\Subset`:              builtinMacro(""),
+		`\Supset`:              builtinMacro(""),
+		`\Vdash`:               builtinMacro(""),
+		`\Vvdash`:              builtinMacro(""),
+		`\approxeq`:            builtinMacro(""),
+		`\backepsilon`:         builtinMacro(""),
+		`\backprime`:           builtinMacro(""),
+		`\backsim`:             builtinMacro(""),
+		`\backsimeq`:           builtinMacro(""),
+		`\barwedge`:            builtinMacro(""),
+		`\because`:             builtinMacro(""),
+		`\beth`:                builtinMacro(""),
+		`\between`:             builtinMacro(""),
+		`\bigstar`:             builtinMacro(""),
+		`\blacksquare`:         builtinMacro(""),
+		`\blacktriangle`:       builtinMacro(""),
+		`\blacktriangledown`:   builtinMacro(""),
+		`\blacktriangleleft`:   builtinMacro(""),
+		`\blacktriangleright`:  builtinMacro(""),
+		`\boxdot`:              builtinMacro(""),
+		`\boxminus`:            builtinMacro(""),
+		`\boxplus`:             builtinMacro(""),
+		`\boxtimes`:            builtinMacro(""),
+		`\bumpeq`:              builtinMacro(""),
+		`\checkmark`:           builtinMacro(""),
+		`\circeq`:              builtinMacro(""),
+		`\circlearrowleft`:     builtinMacro(""),
+		`\circlearrowright`:    builtinMacro(""),
+		`\circledR`:            builtinMacro(""),
+		`\circledS`:            builtinMacro(""),
+		`\circledast`:          builtinMacro(""),
+		`\circledcirc`:         builtinMacro(""),
+		`\circleddash`:         builtinMacro(""),
+		`\complement`:          builtinMacro(""),
+		`\curlyeqprec`:         builtinMacro(""),
+		`\curlyeqsucc`:         builtinMacro(""),
+		`\curlyvee`:            builtinMacro(""),
+		`\curlywedge`:          builtinMacro(""),
+		`\curvearrowleft`:      builtinMacro(""),
+		`\curvearrowright`:     builtinMacro(""),
+		`\daleth`:              builtinMacro(""),
+		`\dashleftarrow`:       builtinMacro(""),
+		`\dashrightarrow`:      builtinMacro(""),
+		`\digamma`:             builtinMacro(""),
+		`\divideontimes`:       builtinMacro(""),
+		`\doublebarwedge`:      builtinMacro(""),
+		`\downdownarrows`:      builtinMacro(""),
+		`\downharpoonleft`:     builtinMacro(""),
+		`\downharpoonright`:    builtinMacro(""),
+		`\eqcirc`:              builtinMacro(""),
+		`\eqslantgtr`:          builtinMacro(""),
+		`\eqslantless`:         builtinMacro(""),
+		`\fallingdotseq`:       builtinMacro(""),
+		`\geqq`:                builtinMacro(""),
+		`\geqslant`:            builtinMacro(""),
+		`\ggg`:                 builtinMacro(""),
+		`\gimel`:               builtinMacro(""),
+		`\gnapprox`:            builtinMacro(""),
+		`\gneqq`:               builtinMacro(""),
+		`\gnsim`:               builtinMacro(""),
+		`\gtrapprox`:           builtinMacro(""),
+		`\gtrdot`:              builtinMacro(""),
+		`\gtreqless`:           builtinMacro(""),
+		`\gtreqqless`:          builtinMacro(""),
+		`\gtrless`:             builtinMacro(""),
+		`\gtrsim`:              builtinMacro(""),
+		`\intercal`:            builtinMacro(""),
+		`\leftarrowtail`:       builtinMacro(""),
+		`\leftleftarrows`:      builtinMacro(""),
+		`\leftrightarrows`:     builtinMacro(""),
+		`\leftrightharpoons`:   builtinMacro(""),
+		`\leftrightsquigarrow`: builtinMacro(""),
+		`\leftthreetimes`:      builtinMacro(""),
+		`\leqq`:                builtinMacro(""),
+		`\leqslant`:            builtinMacro(""),
+		`\lessapprox`:          builtinMacro(""),
+		`\lessdot`:             builtinMacro(""),
+		`\lesseqgtr`:           builtinMacro(""),
+		`\lesseqqgtr`:          builtinMacro(""),
+		`\lessgtr`:             builtinMacro(""),
+		`\lesssim`:             builtinMacro(""),
+		`\llcorner`:            builtinMacro(""),
+		`\lll`:                 builtinMacro(""),
+		`\lnapprox`:            builtinMacro(""),
+		`\lneqq`:               builtinMacro(""),
+		`\lnsim`:               builtinMacro(""),
+		`\looparrowleft`:       builtinMacro(""),
+		`\looparrowright`:      builtinMacro(""),
+		`\lrcorner`:            builtinMacro(""),
+		`\ltimes`:              builtinMacro(""),
+		`\maltese`:             builtinMacro(""),
+		`\measuredangle`:       builtinMacro(""),
+		`\multimap`:            builtinMacro(""),
+		`\nLeftarrow`:          builtinMacro(""),
+		`\nLeftrightarrow`:     builtinMacro(""),
+		`\nRightarrow`:         builtinMacro(""),
+		`\nVDash`:              builtinMacro(""),
+		`\nVdash`:              builtinMacro(""),
+		`\ncong`:               builtinMacro(""),
+		`\nexists`:             builtinMacro(""),
+		`\ngeq`:                builtinMacro(""),
+		`\ngtr`:                builtinMacro(""),
+		`\nleftarrow`:          builtinMacro(""),
+		`\nleftrightarrow`:     builtinMacro(""),
+		`\nleq`:                builtinMacro(""),
+		`\nless`:               builtinMacro(""),
+		`\nmid`:                builtinMacro(""),
+		`\nparallel`:           builtinMacro(""),
+		`\nprec`:               builtinMacro(""),
+		`\nrightarrow`:         builtinMacro(""),
+		`\nsim`:                builtinMacro(""),
+		`\nsubseteq`:           builtinMacro(""),
+		`\nsucc`:               builtinMacro(""),
+		`\nsupseteq`:           builtinMacro(""),
+		`\ntriangleleft`:       builtinMacro(""),
+		`\ntrianglelefteq`:     builtinMacro(""),
+		`\ntriangleright`:      builtinMacro(""),
+		`\ntrianglerighteq`:    builtinMacro(""),
+		`\nvDash`:              builtinMacro(""),
+		`\nvdash`:              builtinMacro(""),
+		`\pitchfork`:           builtinMacro(""),
+		`\precapprox`:          builtinMacro(""),
+		`\precnapprox`:         builtinMacro(""),
+		`\precnsim`:            builtinMacro(""),
+		`\precsim`:             builtinMacro(""),
+		`\rightarrowtail`:      builtinMacro(""),
+		`\rightleftarrows`:     builtinMacro(""),
+		`\rightrightarrows`:    builtinMacro(""),
+		`\rightsquigarrow`:     builtinMacro(""),
+		`\rightthreetimes`:     builtinMacro(""),
+		`\risingdotseq`:        builtinMacro(""),
+		`\rtimes`:              builtinMacro(""),
+		`\smallsetminus`:       builtinMacro(""),
+		`\sphericalangle`:      builtinMacro(""),
+		`\subseteqq`:           builtinMacro(""),
+		`\subsetneq`:           builtinMacro(""),
+		`\subsetneqq`:          builtinMacro(""),
+		`\succapprox`:          builtinMacro(""),
+		`\succnapprox`:         builtinMacro(""),
+		`\succnsim`:            builtinMacro(""),
+		`\succsim`:             builtinMacro(""),
+		`\supseteqq`:           builtinMacro(""),
+		`\supsetneq`:           builtinMacro(""),
+		`\supsetneqq`:          builtinMacro(""),
+		`\therefore`:           builtinMacro(""),
+		`\triangledown`:        builtinMacro(""),
+		`\trianglelefteq`:      builtinMacro(""),
+		`\triangleq`:           builtinMacro(""),
+		`\trianglerighteq`:     builtinMacro(""),
+		`\twoheadleftarrow`:    builtinMacro(""),
+		`\twoheadrightarrow`:   builtinMacro(""),
+		`\ulcorner`:            builtinMacro(""),
+		`\upharpoonleft`:       builtinMacro(""),
+		`\upharpoonright`:      builtinMacro(""),
+		`\upuparrows`:          builtinMacro(""),
+		`\urcorner`:            builtinMacro(""),
+		`\vDash`:               builtinMacro(""),
+		`\vartriangle`:         builtinMacro(""),
+		`\vartriangleleft`:     builtinMacro(""),
+		`\vartriangleright`:    builtinMacro(""),
+		`\veebar`:              builtinMacro(""),
+
 		// math font
 		`\mathbf`:      builtinMacro("A"),
 		`\mathit`:      builtinMacro("A"),
@@ -306,6 +533,71 @@ func (p *parser) addBuiltinMacros() {
 		`\textscr`:     builtinMacro("A"),
 		`\textregular`: builtinMacro("A"),
 
+		`\emph`:     builtinMacro("A"),
+		`\text`:     builtinMacro("A"),
+		`\footnote`: builtinMacro("OA"),
+		`\LaTeX`:    builtinMacro(""),
+		`\TeX`:      builtinMacro(""),
+		`\today`:    builtinMacro(""),
+
+		// document structure
+		`\documentclass`:     builtinMacro("OA"),
+		`\usepackage`:        builtinMacro("OA"),
+		`\title`:             builtinMacro("A"),
+		`\author`:            builtinMacro("A"),
+		`\date`:              builtinMacro("A"),
+		`\maketitle`:         builtinMacro(""),
+		`\tableofcontents`:   builtinMacro(""),
+		`\appendix`:          builtinMacro(""),
+		`\part`:              builtinMacro("*OA"),
+		`\chapter`:           builtinMacro("*OA"),
+		`\section`:           builtinMacro("*OA"),
+		`\subsection`:        builtinMacro("*OA"),
+		`\subsubsection`:     builtinMacro("*OA"),
+		`\paragraph`:         builtinMacro("*OA"),
+		`\subparagraph`:      builtinMacro("*OA"),
+		`\item`:              builtinMacro("O"),
+		`\caption`:           builtinMacro("OA"),
+		`\label`:             builtinMacro("A"),
+		`\ref`:               builtinMacro("A"),
+		`\eqref`:             builtinMacro("A"),
+		`\pageref`:           builtinMacro("A"),
+		`\cite`:              builtinMacro("OA"),
+		`\bibliography`:      builtinMacro("A"),
+		`\bibliographystyle`: builtinMacro("A"),
+		`\bibitem`:           builtinMacro("OA"),
+		`\input`:             builtinMacro("A"),
+		`\include`:           builtinMacro("A"),
+		`\centering`:         builtinMacro(""),
+		`\noindent`:          builtinMacro(""),
+		`\newpage`:           builtinMacro(""),
+		`\clearpage`:         builtinMacro(""),
+
+		// font and size declarations (see also font names)
+		`\sl`:           builtinMacro(""),
+		`\sc`:           builtinMacro(""),
+		`\em`:           builtinMacro(""),
+		`\normalfont`:   builtinMacro(""),
+		`\bfseries`:     builtinMacro(""),
+		`\mdseries`:     builtinMacro(""),
+		`\itshape`:      builtinMacro(""),
+		`\slshape`:      builtinMacro(""),
+		`\scshape`:      builtinMacro(""),
+		`\upshape`:      builtinMacro(""),
+		`\rmfamily`:     builtinMacro(""),
+		`\sffamily`:     builtinMacro(""),
+		`\ttfamily`:     builtinMacro(""),
+		`\tiny`:         builtinMacro(""),
+		`\scriptsize`:   builtinMacro(""),
+		`\footnotesize`: builtinMacro(""),
+		`\small`:        builtinMacro(""),
+		`\normalsize`:   builtinMacro(""),
+		`\large`:        builtinMacro(""),
+		`\Large`:        builtinMacro(""),
+		`\LARGE`:        builtinMacro(""),
+		`\huge`:         builtinMacro(""),
+		`\Huge`:         builtinMacro(""),
+
 		// space, symbols
 		`\ `:      builtinMacro(""),
 		`\,`:      builtinMacro(""),
@@ -318,24 +610,62 @@ func (p *parser) addBuiltinMacros() {
 		`\ddots`:  builtinMacro(""),
 		`\ldots`:  builtinMacro(""),
 		`\vdots`:  builtinMacro(""),
-		`\hspace`: builtinMacro("A"),
+		`\hspace`: builtinMacro("*L"),
+
+		// lengths and boxes
+		`\vspace`:       builtinMacro("*L"),
+		`\rule`:         builtinMacro("SLL"),
+		`\raisebox`:     builtinMacro("LSSA"),
+		`\setlength`:    builtinMacro("AL"),
+		`\addtolength`:  builtinMacro("AL"),
+		`\stretch`:      builtinMacro("A"),
+		`\fill`:         builtinMacro(""),
+		`\textwidth`:    builtinMacro(""),
+		`\textheight`:   builtinMacro(""),
+		`\linewidth`:    builtinMacro(""),
+		`\columnwidth`:  builtinMacro(""),
+		`\paperwidth`:   builtinMacro(""),
+		`\paperheight`:  builtinMacro(""),
+		`\baselineskip`: builtinMacro(""),
+		`\parindent`:    builtinMacro(""),
+		`\parskip`:      builtinMacro(""),
+
+		// graphics
+		`\includegraphics`: builtinMacro("KA"),
+
+		// line breaks and table rules
+		`\\`:       builtinMacro("S"),
+		`\newline`: builtinMacro(""),
+		`\hline`:   builtinMacro(""),
+		`\cline`:   builtinMacro("A"),
 
 		// catch-all
 		//
 		`\overline`:     builtinMacro("A"),
 		`\operatorname`: builtinMacro("A"),
-	}
 
-	// add all known UTF-8 symbols
-	for _, k := range tex2unicode.Symbols() {
-		_, ok := p.macros[`\`+k]
-		if ok {
-			continue
-		}
-		p.macros[`\`+k] = builtinMacro("")
+		// verbatim
+		`\verb`:       builtinMacro("V"),
+		`\verb*`:      builtinMacro("V"),
+		`\url`:        builtinMacro("V"),
+		`\lstinline`:  builtinMacro("KV"),
+		`\mintinline`: builtinMacro("KAV"),
+
+		// category codes
+		`\makeatletter`: catcodeSwitch{'@', Letter},
+		`\makeatother`:  catcodeSwitch{'@', Other},
+
+		// macro definitions
+		`\newcommand`:     defineNew,
+		`\renewcommand`:   defineRenew,
+		`\providecommand`: defineProvide,
+		`\def`:            texDef{},
 	}
 }
 
+// builtinMacro is the signature of the arguments of a builtin macro (see
+// parser.parseArgs).
+// A leading '*' denotes a starred variant of the macro, e.g. \section*.
 type builtinMacro string
 
 func (m builtinMacro) parseMacro(p *parser) ast.Node {
@@ -346,16 +676,15 @@ func (m builtinMacro) parseMacro(p *parser) ast.Node {
 		},
 	}
 
-	for _, typ := range strings.ToLower(string(m)) {
-		switch typ {
-		case 'a':
-			p.parseMacroArg(node)
-		case 'o':
-			p.parseOptMacroArg(node)
-		case 'v':
-			p.parseVerbatimMacroArg(node)
+	sig := string(m)
+	if strings.HasPrefix(sig, "*") {
+		sig = sig[1:]
+		if next := p.s.peek(); next.Kind == token.Symbol && next.Text == "*" {
+			p.next()
+			node.Name.Name += "*"
 		}
 	}
+	p.parseArgs(sig, &node.Args)
 
 	return node
 }
@@ -326,7 +326,12 @@ var (
 		`\ddots`:  builtinMacro(""),
 		`\ldots`:  builtinMacro(""),
 		`\vdots`:  builtinMacro(""),
-		`\hspace`: builtinMacro("A"),
+		`\hspace`: builtinMacro("L"),
+
+		// lengths and boxes
+		`\vspace`:   builtinMacro("L"),
+		`\rule`:     builtinMacro("SLL"),
+		`\raisebox`: builtinMacro("LSSA"),
 
 		// catch-all
 		//
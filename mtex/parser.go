@@ -7,8 +7,8 @@ package mtex
 import (
 	"fmt"
 	"math"
-	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/go-latex/latex"
 	"github.com/go-latex/latex/ast"
@@ -16,6 +16,7 @@ import (
 	"github.com/go-latex/latex/internal/tex2unicode"
 	"github.com/go-latex/latex/mtex/symbols"
 	"github.com/go-latex/latex/tex"
+	"github.com/go-latex/latex/tex/units"
 )
 
 // Parse parses a LaTeX math expression and returns the TeX-like box model
@@ -25,11 +26,20 @@ func Parse(expr string, fontSize, DPI float64, backend font.Backend) (tex.Node,
 	return p.parse(expr, fontSize, DPI)
 }
 
+// latexParser parses the expressions to render, accepting Unicode math
+// characters in place of their TeX spelling.
+var latexParser = func() *latex.Parser {
+	p := latex.NewParser()
+	p.UnicodeMath = true
+	return p
+}()
+
 type parser struct {
 	be font.Backend
 
 	expr   string
 	macros map[string]handler
+	err    error // first error encountered while rendering the expression
 }
 
 func newParser(be font.Backend) *parser {
@@ -44,7 +54,7 @@ func newParser(be font.Backend) *parser {
 
 func (p *parser) parse(x string, size, dpi float64) (tex.Node, error) {
 	p.expr = x
-	node, err := latex.ParseExpr(x)
+	node, err := latexParser.ParseExpr(x)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse latex expression %q: %w", x, err)
 	}
@@ -57,11 +67,23 @@ func (p *parser) parse(x string, size, dpi float64) (tex.Node, error) {
 
 	v := visitor{p: p, state: state}
 	ast.Walk(&v, node)
+	if p.err != nil {
+		return nil, fmt.Errorf("could not parse latex expression %q: %w", x, p.err)
+	}
 	nodes := tex.HListOf(v.nodes, true)
 
 	return nodes, nil
 }
 
+// errorf records the first error encountered while rendering the
+// expression.
+func (p *parser) errorf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	p.err = fmt.Errorf(format, args...)
+}
+
 type visitor struct {
 	p     *parser
 	nodes []tex.Node
@@ -77,11 +99,22 @@ func (v *visitor) Visit(n ast.Node) ast.Visitor {
 		case v.math:
 			h := v.p.handler(n.Text)
 			if h == nil {
-				panic("no handler for symbol [" + n.Text + "]")
+				v.p.errorf("unsupported symbol %q", n.Text)
+				return nil
 			}
 			v.nodes = append(v.nodes, h.Handle(v.p, n, v.state, v.math))
+		case spaceWidth[n.Text] != 0:
+			v.nodes = append(v.nodes, handleSpace(v.p, n, v.state, v.math))
 		default:
-			v.nodes = append(v.nodes, tex.NewChar(string(n.Text), v.state, v.math))
+			text := n.Text
+			if c, ok := textSymbols[text]; ok {
+				text = c
+			}
+			if strings.TrimSpace(text) == "" {
+				// ends of lines and indentations are typeset as a space.
+				text = " "
+			}
+			v.nodes = append(v.nodes, tex.NewChar(text, v.state, v.math))
 		}
 	case *ast.Word:
 		var nodes []tex.Node
@@ -109,14 +142,47 @@ func (v *visitor) Visit(n ast.Node) ast.Visitor {
 		v.state.Font.Type = oldt
 		return nil
 
+	case *ast.Delimited:
+		v.nodes = append(v.nodes, v.p.handleDelimited(n, v.state, v.math))
+		return nil
+
+	case *ast.Sup, *ast.Sub:
+		v.nodes = append(v.nodes, v.p.handleScript(n, v.state, v.math))
+		return nil
+
+	case *ast.Group:
+		// restore the state modified by declarations inside the group.
+		state := v.state
+		for _, x := range n.List {
+			ast.Walk(v, x)
+		}
+		v.state = state
+		return nil
+
+	case *ast.MacroDef:
+		// definitions have been expanded by the latex parser.
+		return nil
+
+	case *ast.Comment:
+		return nil
+
 	case *ast.Macro:
+		if n.Expansion != nil {
+			// user-defined macros are rendered as their expansion.
+			for _, x := range n.Expansion {
+				ast.Walk(v, x)
+			}
+			return nil
+		}
 		if n.Name == nil {
-			panic("macro with nil identifier")
+			v.p.errorf("macro with nil identifier")
+			return nil
 		}
 		macro := n.Name.Name
 		h := v.p.handler(macro)
 		if h == nil {
-			panic(fmt.Errorf("unknown macro %q", macro))
+			v.p.errorf("unsupported macro %q", macro)
+			return nil
 		}
 		v.nodes = append(v.nodes, h.Handle(v.p, n, v.state, v.math))
 		return nil
@@ -125,7 +191,8 @@ func (v *visitor) Visit(n ast.Node) ast.Visitor {
 		return v
 
 	default:
-		panic(fmt.Errorf("unknown ast node %T", n))
+		v.p.errorf("unsupported node %T", n)
+		return nil
 	}
 	return v
 }
@@ -143,7 +210,7 @@ func (p *parser) handler(name string) handler {
 	if symbols.IsSpaced(name) || symbols.PunctuationSymbols.Has(name) {
 		return handlerFunc(handleSymbol)
 	}
-	if name == `\hspace` {
+	if name == `\hspace` || name == `\hspace*` {
 		return handlerFunc(handleCustomSpace)
 	}
 	if symbols.FunctionNames.Has(name[1:]) { // drop leading `\`
@@ -158,15 +225,24 @@ func (p *parser) handler(name string) handler {
 		return handlerFunc(handleTFrac)
 	case `\binom`:
 		return handlerFunc(handleBinom)
+	case `\vspace`, `\vspace*`:
+		return handlerFunc(handleVSpace)
+	case `\rule`:
+		return handlerFunc(handleRule)
+	case `\raisebox`:
+		return handlerFunc(handleRaiseBox)
 		// case `\genfrac`:
 		// 	return handlerFunc(handleGenFrac)
 	case `\sqrt`:
 		return handlerFunc(handleSqrt)
 	case `\overline`:
 		return handlerFunc(handleOverline)
+	case `\mathbb`, `\mathfrak`, `\mathscr`:
+		return handlerFunc(handleMathAlphabet)
 	}
 	_, ok := p.macros[name]
-	if ok {
+	if ok || utf8.RuneCountInString(name) == 1 {
+		// Unicode math characters without a TeX spelling are typeset as is.
 		return handlerFunc(handleSymbol)
 	}
 	return nil
@@ -221,24 +297,39 @@ func handleSymbol(p *parser, node ast.Node, state tex.State, math bool) tex.Node
 		}
 
 	case symbols.PunctuationSymbols.Has(sym):
-		switch sym {
-		case ".":
+		if sym == "." {
 			pos := strings.Index(p.expr[pos:], sym)
 			if (pos > 0 && isdigit(p.expr[pos-1])) &&
 				(pos < len(p.expr)-1 && isdigit(p.expr[pos+1])) {
 				// do not space dots as decimal separators.
 				return ch
 			}
-			return tex.HListOf([]tex.Node{
-				ch,
-				p.makeSpace(state, 0.2),
-			}, true)
 		}
-		panic("not implemented")
+		return tex.HListOf([]tex.Node{
+			ch,
+			p.makeSpace(state, 0.2),
+		}, true)
 	}
 	return ch
 }
 
+// textSymbols maps the ligatures and escaped characters of text mode to
+// the character they stand for.
+var textSymbols = map[string]string{
+	"--":  "\u2013", // en dash
+	"---": "\u2014", // em dash
+	"``":  "\u201c", // left double quotation mark
+	"''":  "\u201d", // right double quotation mark
+	"`":   "\u2018", // left single quotation mark
+	`\&`:  "&",
+	`\%`:  "%",
+	`\$`:  "$",
+	`\#`:  "#",
+	`\_`:  "_",
+	`\{`:  "{",
+	`\}`:  "}",
+}
+
 var spaceWidth = map[string]float64{
 	`\,`:         0.16667,  // 3/18 em = 3 mu
 	`\thinspace`: 0.16667,  // 3/18 em = 3 mu
@@ -277,12 +368,47 @@ func handleSpace(p *parser, node ast.Node, state tex.State, math bool) tex.Node
 
 func handleCustomSpace(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
 	macro := node.(*ast.Macro)
-	arg := macro.Args[0].(*ast.Arg).List[0].(*ast.Literal).Text
-	val, err := strconv.ParseFloat(arg, 64)
-	if err != nil {
-		panic(fmt.Errorf("could not parse customspace: %+v", err))
+	return tex.NewKern(p.length(macro.Args[0], state))
+}
+
+// handleVSpace renders a vertical space as an empty box of that height,
+// above the baseline.
+func handleVSpace(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
+	macro := node.(*ast.Macro)
+	return tex.VListOf([]tex.Node{tex.NewKern(p.length(macro.Args[0], state))})
+}
+
+// handleRule renders \rule[raise]{width}{height}.
+func handleRule(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
+	var (
+		macro = node.(*ast.Macro)
+		args  = macro.Args
+		raise = 0.0
+	)
+	if _, ok := args[0].(*ast.OptArg); ok {
+		raise = p.length(args[0], state)
+		args = args[1:]
 	}
-	return p.makeSpace(state, val)
+	var (
+		width  = p.length(args[0], state)
+		height = p.length(args[1], state)
+	)
+	return tex.NewRule(width, height+raise, -raise, state)
+}
+
+// handleRaiseBox renders \raisebox{lift}[height][depth]{text}.
+// The optional height and depth arguments are ignored: the box keeps the
+// dimensions of the raised text.
+func handleRaiseBox(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
+	var (
+		macro = node.(*ast.Macro)
+		lift  = p.length(macro.Args[0], state)
+		text  = argList(macro.Args[len(macro.Args)-1])
+	)
+	state.Font.Type = "rm"
+	box := tex.VListOf([]tex.Node{p.handleNode(text, state, false)})
+	box.SetShift(-lift)
+	return box
 }
 
 func handleFunction(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
@@ -300,8 +426,8 @@ func handleFrac(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
 	var (
 		macro     = node.(*ast.Macro)
 		thickness = state.Backend().UnderlineThickness(state.Font, state.DPI)
-		numNode   = ast.List(macro.Args[0].(*ast.Arg).List)
-		denNode   = ast.List(macro.Args[1].(*ast.Arg).List)
+		numNode   = argList(macro.Args[0])
+		denNode   = argList(macro.Args[1])
 	)
 
 	num := p.handleNode(numNode, state, math)
@@ -319,8 +445,8 @@ func handleDFrac(p *parser, node ast.Node, state tex.State, math bool) tex.Node
 	var (
 		macro     = node.(*ast.Macro)
 		thickness = state.Backend().UnderlineThickness(state.Font, state.DPI)
-		numNode   = ast.List(macro.Args[0].(*ast.Arg).List)
-		denNode   = ast.List(macro.Args[1].(*ast.Arg).List)
+		numNode   = argList(macro.Args[0])
+		denNode   = argList(macro.Args[1])
 	)
 
 	num := p.handleNode(numNode, state, math)
@@ -333,8 +459,8 @@ func handleTFrac(p *parser, node ast.Node, state tex.State, math bool) tex.Node
 	var (
 		macro     = node.(*ast.Macro)
 		thickness = state.Backend().UnderlineThickness(state.Font, state.DPI)
-		numNode   = ast.List(macro.Args[0].(*ast.Arg).List)
-		denNode   = ast.List(macro.Args[1].(*ast.Arg).List)
+		numNode   = argList(macro.Args[0])
+		denNode   = argList(macro.Args[1])
 	)
 
 	num := p.handleNode(numNode, state, math)
@@ -346,8 +472,8 @@ func handleTFrac(p *parser, node ast.Node, state tex.State, math bool) tex.Node
 func handleBinom(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
 	var (
 		macro   = node.(*ast.Macro)
-		numNode = ast.List(macro.Args[0].(*ast.Arg).List)
-		denNode = ast.List(macro.Args[1].(*ast.Arg).List)
+		numNode = argList(macro.Args[0])
+		denNode = argList(macro.Args[1])
 	)
 
 	num := p.handleNode(numNode, state, math)
@@ -414,13 +540,13 @@ func handleSqrt(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
 			state, math,
 		)
 		body = p.handleNode(
-			ast.List(macro.Args[1].(*ast.Arg).List),
+			argList(macro.Args[1]),
 			state, math,
 		).(*tex.HList)
 	case 1:
 		// ok
 		body = p.handleNode(
-			ast.List(macro.Args[0].(*ast.Arg).List),
+			argList(macro.Args[0]),
 			state, math,
 		).(*tex.HList)
 	default:
@@ -483,7 +609,7 @@ func handleSqrt(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
 func handleOverline(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
 	macro := node.(*ast.Macro)
 	body := p.handleNode(
-		ast.List(macro.Args[0].(*ast.Arg).List),
+		argList(macro.Args[0]),
 		state, math,
 	).(*tex.HList)
 
@@ -507,6 +633,105 @@ func handleOverline(p *parser, node ast.Node, state tex.State, math bool) tex.No
 	return hl
 }
 
+// mathAlphabets maps the math alphabet macros to the prefix of the names
+// of their Unicode letters, e.g. "Bbb" for \mathbb{R} and ℝ (\BbbR).
+var mathAlphabets = map[string]string{
+	`\mathbb`:   "Bbb",
+	`\mathfrak`: "frak",
+	`\mathscr`:  "scr",
+}
+
+// handleMathAlphabet renders the letters of its argument with their Unicode
+// math alphabet counterparts, when they exist.
+func handleMathAlphabet(p *parser, node ast.Node, state tex.State, math bool) tex.Node {
+	var (
+		macro  = node.(*ast.Macro)
+		prefix = mathAlphabets[macro.Name.Name]
+		nodes  []tex.Node
+	)
+	for _, arg := range argList(macro.Args[0]) {
+		word, ok := arg.(*ast.Word)
+		if !ok {
+			nodes = append(nodes, p.handleNode(arg, state, math))
+			continue
+		}
+		for _, c := range word.Text {
+			sym := string(c)
+			if tex2unicode.HasSymbol(prefix + sym) {
+				sym = `\` + prefix + sym
+			}
+			nodes = append(nodes, tex.NewChar(sym, state, math))
+		}
+	}
+	return tex.HListOf(nodes, true)
+}
+
+// length returns the length of the argument arg, holding an *ast.Dimen or
+// a \stretch call, in pixels.
+// As in mathtext, bare numbers, e.g. \hspace{2}, are expressed in em.
+// The stretch and shrink components of glue are ignored: the length is
+// set at its natural size, which is zero for \fill and \stretch.
+// Lengths relative to the page layout, e.g. 0.5\textwidth, are reported
+// as unsupported.
+func (p *parser) length(arg ast.Node, state tex.State) float64 {
+	var list ast.List
+	switch arg := arg.(type) {
+	case *ast.Arg:
+		list = arg.List
+	case *ast.OptArg:
+		list = arg.List
+	}
+	if len(list) != 1 {
+		p.errorf("invalid length argument")
+		return 0
+	}
+	var dim *ast.Dimen
+	switch n := list[0].(type) {
+	case *ast.Dimen:
+		dim = n
+	case *ast.Macro:
+		if n.Name != nil && n.Name.Name == `\stretch` {
+			return 0
+		}
+	}
+	if dim == nil {
+		p.errorf("invalid length argument")
+		return 0
+	}
+
+	unit := dim.Unit
+	switch {
+	case unit == "":
+		unit = "em"
+	case unit == `\fill`:
+		return 0
+	case strings.HasPrefix(unit, `\`):
+		p.errorf("unsupported length %q", unit)
+		return 0
+	}
+	l, err := units.ParseLength(dim.Value + unit)
+	if err != nil {
+		p.errorf("could not parse length: %w", err)
+		return 0
+	}
+
+	var (
+		pt = units.PointsPerInch / state.DPI // number of points in a pixel
+		em = p.makeSpace(state, 1).Width()
+		ex = state.Backend().XHeight(state.Font, state.DPI)
+	)
+	return l.Points(units.Font{Em: em * pt, Ex: ex * pt}) / pt
+}
+
+// argList returns the nodes of the mandatory argument arg, either enclosed
+// in braces or made of a single node, e.g. the 1 of \frac1x.
+func argList(arg ast.Node) ast.List {
+	if arg, ok := arg.(*ast.Arg); ok {
+		return arg.List
+	}
+	return ast.List{arg}
+}
+
 func (p *parser) makeSpace(state tex.State, percentage float64) *tex.Kern {
 	const math = true
 	fnt := state.Font
@@ -516,6 +741,97 @@ func (p *parser) makeSpace(state tex.State, percentage float64) *tex.Kern {
 	return tex.NewKern(width * percentage)
 }
 
+// handleScript renders a superscript or a subscript as a shrunk box,
+// raised above or dropped below the baseline.
+// The leading primes of a superscript are rendered as \prime glyphs, which
+// are drawn raised by the fonts.
+func (p *parser) handleScript(node ast.Node, state tex.State, math bool) tex.Node {
+	var (
+		consts  = tex.DefaultFontConstants
+		xheight = state.Backend().XHeight(state.Font, state.DPI)
+		nodes   []tex.Node
+		body    ast.Node
+		shift   float64
+	)
+	switch node := node.(type) {
+	case *ast.Sup:
+		body = node.Node
+		if list, ok := body.(ast.List); ok && len(list) > 0 && isPrime(list[0]) {
+			// offset the primes from slanted nuclei, e.g. f'.
+			nodes = append(nodes, tex.NewKern(consts.DeltaSlanted*xheight))
+			for len(list) > 0 && isPrime(list[0]) {
+				nodes = append(nodes, tex.NewChar(`\prime`, state, math))
+				list = list[1:]
+			}
+			body = list
+		}
+		shift = -consts.Sup1 * xheight
+	case *ast.Sub:
+		body = node.Node
+		shift = consts.Sub1 * xheight
+	default:
+		panic(fmt.Errorf("invalid ast node %#v (%T)", node, node))
+	}
+
+	if list, ok := body.(ast.List); !ok || len(list) > 0 {
+		box := p.handleNode(body, state, math)
+		box.Shrink()
+		script := tex.VListOf([]tex.Node{box})
+		script.SetShift(shift)
+		nodes = append(nodes, script)
+	}
+	nodes = append(nodes, tex.NewKern(consts.ScriptSpace*xheight))
+	return tex.HListOf(nodes, true)
+}
+
+func isPrime(node ast.Node) bool {
+	sym, ok := node.(*ast.Symbol)
+	return ok && sym.Text == "'"
+}
+
+// handleDelimited renders the body of a \left ... \right construct,
+// with its delimiters sized after the body.
+func (p *parser) handleDelimited(node *ast.Delimited, state tex.State, math bool) tex.Node {
+	var (
+		parts  []tex.Node
+		mids   []*ast.Delim
+		seg    ast.List
+		height float64
+		depth  float64
+	)
+	flush := func() {
+		box := p.handleNode(seg, state, math)
+		if h := box.Height(); h > height {
+			height = h
+		}
+		if d := box.Depth(); d > depth {
+			depth = d
+		}
+		parts = append(parts, box)
+		seg = nil
+	}
+	for _, n := range node.Body {
+		mid, ok := n.(*ast.Delim)
+		if !ok {
+			seg = append(seg, n)
+			continue
+		}
+		flush()
+		mids = append(mids, mid)
+	}
+	flush()
+
+	middle := []tex.Node{parts[0]}
+	for i, mid := range mids {
+		if !mid.IsNull() {
+			middle = append(middle, tex.AutoHeightChar(mid.Text, height, depth, state, 0))
+		}
+		middle = append(middle, parts[i+1])
+	}
+
+	return p.autoSizedDelimiter(node.Left.Text, middle, node.Right.Text, state)
+}
+
 func (p *parser) autoSizedDelimiter(left string, middle []tex.Node, right string, state tex.State) tex.Node {
 	var (
 		height float64
@@ -41,12 +41,36 @@ func TestParse(t *testing.T) {
 			h:    5.46875,
 			d:    0.140625,
 		},
+		{
+			expr: `\newcommand{\s}{\sigma}$\s$`,
+			w:    6.337890625,
+			h:    5.46875,
+			d:    0.140625,
+		},
+		{
+			expr: `${\sigma}$`,
+			w:    6.337890625,
+			h:    5.46875,
+			d:    0.140625,
+		},
+		{
+			expr: "$\\sigma % comment\n$",
+			w:    6.337890625,
+			h:    5.46875,
+			d:    0.140625,
+		},
 		{
 			expr: `$\sigma$ is $12$`,
 			w:    33.408203125,
 			h:    7.59375,
 			d:    0.140625,
 		},
+		{
+			expr: "$\\sigma$ is\n  $12$",
+			w:    33.408203125,
+			h:    7.59375,
+			d:    0.140625,
+		},
 		{
 			expr: `$1.1$`,
 			w:    15.9033203125,
@@ -101,6 +125,66 @@ func TestParse(t *testing.T) {
 			h:    5.46875,
 			d:    0.140625,
 		},
+		{
+			expr: `$x^2$`,
+			w:    10.64501953125,
+			h:    9.0234375,
+			d:    0,
+		},
+		{
+			expr: `$x_i$`,
+			w:    8.13623046875,
+			h:    5.46875,
+			d:    1.640625,
+		},
+		{
+			expr: `$f'$`,
+			w:    7.158203125,
+			h:    7.59375,
+			d:    0,
+		},
+		{
+			expr: `$f''^2$`,
+			w:    13.88232421875,
+			h:    9.0234375,
+			d:    0,
+		},
+		{
+			expr: `$\sigma\hspace{2em}=\infty$`,
+			w:    46.42578125,
+			h:    5.46875,
+			d:    0.140625,
+		},
+		{
+			expr: `$\sigma\hspace{72.27pt plus 1fil}=\infty$`,
+			w:    98.943359375,
+			h:    5.46875,
+			d:    0.140625,
+		},
+		{
+			expr: `$\sigma\hspace{-1ex}=\infty$`,
+			w:    21.474609375,
+			h:    5.46875,
+			d:    0.140625,
+		},
+		{
+			expr: `x\rule[-1pt]{1in}{2pt}`,
+			w:    77.91796875,
+			h:    5.46875,
+			d:    0.9962640099626402,
+		},
+		{
+			expr: `x\raisebox{2pt}{x}`,
+			w:    11.8359375,
+			h:    7.46127801992528,
+			d:    0,
+		},
+		{
+			expr: `x\vspace{10bp}`,
+			w:    5.91796875,
+			h:    10,
+			d:    0,
+		},
 		{
 			expr: `$\cos\theta$`,
 			w:    22.9443359375,
@@ -125,6 +209,12 @@ func TestParse(t *testing.T) {
 			h:    9.490625,
 			d:    3.9375,
 		},
+		{
+			expr: `$\frac12$`,
+			w:    5.70361328125,
+			h:    9.490625,
+			d:    3.9375,
+		},
 		{
 			expr: `$\frac{1}{2\pi}$`,
 			w:    9.91796875,
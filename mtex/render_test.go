@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/go-latex/latex/drawtex"
+	"github.com/go-latex/latex/internal/tex2unicode"
 )
 
 type dummyRenderer struct{}
@@ -46,6 +47,57 @@ func TestRender(t *testing.T) {
 		{
 			expr: `$\int\frac{\partial x}{x}$`,
 		},
+		{
+			expr: `$\left(\frac{a}{b}\right)$`,
+		},
+		{
+			expr: `$\left.\frac{a}{b} \middle/ \sqrt{x}\right\}$`,
+		},
+		{
+			expr: `$\left\lbrace x \right\rbrace$`,
+		},
+		{
+			expr: "``50\\%~off'' --- \\{\\&\\} $a~\\#b$",
+		},
+		{
+			expr: `$α + β ≤ ∑ x \left⟨ y \right⟩$`,
+		},
+		{
+			expr: `$f'(x) + g''^2 + x_i^{n+1} + \sum_{k}^{n} y_k'$`,
+		},
+		{
+			expr: `a\hspace{1cm}b\rule[-1ex]{2mm}{3ex}\raisebox{.5em}{c}\vspace{1em}`,
+		},
+		{
+			expr: `a\hspace*{1cm}b\vspace*{1em}`,
+		},
+		{
+			expr: `a\hspace{\fill}b\hspace{\stretch{1}}c\hspace{1em plus 1fil}d`,
+		},
+		{
+			expr: `a\hspace{0.5\textwidth}b`,
+			want: fmt.Errorf("could not parse math expression: could not parse latex expression %q: unsupported length %q", `a\hspace{0.5\textwidth}b`, `\textwidth`),
+		},
+		{
+			expr: `\rule{\linewidth}{1pt}`,
+			want: fmt.Errorf("could not parse math expression: could not parse latex expression %q: unsupported length %q", `\rule{\linewidth}{1pt}`, `\linewidth`),
+		},
+		{
+			expr: `$\begin{array}{c}a\end{array}$`,
+			want: fmt.Errorf("could not parse math expression: could not parse latex expression %q: unsupported node *ast.Table", `$\begin{array}{c}a\end{array}$`),
+		},
+		{
+			expr: `a\verb|x|`,
+			want: fmt.Errorf("could not parse math expression: could not parse latex expression %q: unsupported macro %q", `a\verb|x|`, `\verb`),
+		},
+		{
+			expr: `$x^2'$`,
+			want: fmt.Errorf("could not parse math expression: could not parse latex expression %q: 1:5: double superscript", `$x^2'$`),
+		},
+		{
+			expr: `$a \\ b$`,
+			want: fmt.Errorf("could not parse math expression: could not parse latex expression %q: unsupported macro %q", `$a \\ b$`, `\\`),
+		},
 	} {
 		t.Run(tc.expr, func(t *testing.T) {
 			err := Render(dummyRenderer{}, tc.expr, ftsize, dpi, nil)
@@ -65,3 +117,28 @@ func TestRender(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderUnicodeMath(t *testing.T) {
+	const (
+		dpi    = 72
+		ftsize = 10
+	)
+	for _, name := range tex2unicode.Symbols() {
+		r := tex2unicode.Index(name, true)
+		if _, ok := tex2unicode.Name(r); !ok {
+			continue
+		}
+		expr := "$x" + string(r) + "y$"
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					t.Errorf("%q: panic: %+v", expr, err)
+				}
+			}()
+			err := Render(dummyRenderer{}, expr, ftsize, dpi, nil)
+			if err != nil {
+				t.Errorf("could not render %q: %+v", expr, err)
+			}
+		}()
+	}
+}
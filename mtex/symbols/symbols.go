@@ -3,14 +3,42 @@
 // license that can be found in the LICENSE file.
 
 // Package symbols contains logic about TeX symbols.
+//
+// The tables of symbols are shared with the latex parser and the ast
+// package.
 package symbols // import "github.com/go-latex/latex/mtex/symbols"
 
-//go:generate go run ./gen-symbols.go
+import (
+	"github.com/go-latex/latex/internal/symbols"
+)
+
+type Set = symbols.Set
 
 var (
-	SpacedSymbols = UnionOf(BinaryOperators, RelationSymbols, ArrowSymbols)
+	AmbiDelim          = symbols.AmbiDelim
+	ArrowSymbols       = symbols.ArrowSymbols
+	BinaryOperators    = symbols.BinaryOperators
+	DropSubSymbols     = symbols.DropSubSymbols
+	FontNames          = symbols.FontNames
+	FunctionNames      = symbols.FunctionNames
+	LeftDelim          = symbols.LeftDelim
+	OverUnderFunctions = symbols.OverUnderFunctions
+	OverUnderSymbols   = symbols.OverUnderSymbols
+	PunctuationSymbols = symbols.PunctuationSymbols
+	RelationSymbols    = symbols.RelationSymbols
+	RightDelim         = symbols.RightDelim
+
+	SpacedSymbols = symbols.SpacedSymbols
 )
 
+func NewSet(vs ...string) Set {
+	return symbols.NewSet(vs...)
+}
+
+func UnionOf(sets ...Set) Set {
+	return symbols.UnionOf(sets...)
+}
+
 func IsSpaced(s string) bool {
-	return SpacedSymbols.Has(s)
+	return symbols.IsSpaced(s)
 }
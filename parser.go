@@ -5,17 +5,50 @@
 package latex // import "github.com/go-latex/latex"
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/go-latex/latex/ast"
 	"github.com/go-latex/latex/token"
 )
 
-// ParseExpr parses a simple LaTeX expression.
+// ParseExpr parses a simple LaTeX expression, in text mode and with the
+// builtin set of macros and environments.
+//
+// See Parser.ParseExpr for details.
 func ParseExpr(x string) (ast.Node, error) {
-	p := newParser(x)
-	return p.parse()
+	return defaultParser().ParseExpr(x)
+}
+
+// ParseFile parses the source code of a single LaTeX source file and returns
+// the corresponding AST, in text mode and with the builtin set of macros and
+// environments.
+//
+// See Parser.ParseFile for details.
+func ParseFile(fset *token.FileSet, filename string, src interface{}) (ast.Node, error) {
+	return defaultParser().ParseFile(fset, filename, src)
+}
+
+func readSource(filename string, src interface{}) ([]byte, error) {
+	switch src := src.(type) {
+	case nil:
+		return ioutil.ReadFile(filename)
+	case string:
+		return []byte(src), nil
+	case []byte:
+		return src, nil
+	case *bytes.Buffer:
+		if src != nil {
+			return src.Bytes(), nil
+		}
+	case io.Reader:
+		return ioutil.ReadAll(src)
+	}
+	return nil, fmt.Errorf("latex: invalid source type %T", src)
 }
 
 type state int
@@ -26,53 +59,181 @@ const (
 )
 
 type parser struct {
+	cfg   *Parser
+	file  *token.File // file being parsed, or nil
+	src   string
 	s     *texScanner
 	state state
 
-	macros map[string]macroParser
+	errors ErrorList
+	macros map[string]macroParser // macros defined while parsing
+
+	expansions int // number of user-defined macro expansions, or -1 once the limits are exceeded
 }
 
-func newParser(x string) *parser {
+func newParser(cfg *Parser, file *token.File, x string) *parser {
 	p := &parser{
-		s:     newScanner(strings.NewReader(x)),
-		state: normalState,
+		cfg:    cfg,
+		file:   file,
+		src:    x,
+		s:      newScanner(strings.NewReader(x)),
+		state:  normalState,
+		macros: make(map[string]macroParser),
+	}
+	if cfg.Mode == MathMode {
+		p.state = mathState
+	}
+	if cfg.Catcodes != nil {
+		p.s.setCatcodes(cfg.Catcodes.clone())
+	}
+	if file != nil {
+		p.s.base = file.Base()
 	}
-	p.addBuiltinMacros()
+	p.s.err = p.error
 	return p
 }
 
+// macro returns the macro parser associated with name.
+// Macros defined while parsing take precedence over the configured ones.
+func (p *parser) macro(name string) (macroParser, bool) {
+	if m, ok := p.macros[name]; ok {
+		return m, true
+	}
+	m, ok := p.cfg.macros[name]
+	return m, ok
+}
+
+// openScope opens a scope for the macros defined while parsing a group,
+// and returns the enclosing scope, to be restored at the end of the group.
+func (p *parser) openScope() map[string]macroParser {
+	outer := p.macros
+	p.macros = make(map[string]macroParser, len(outer))
+	for name, macro := range outer {
+		p.macros[name] = macro
+	}
+	return outer
+}
+
 func (p *parser) parse() (ast.Node, error) {
-	var nodes ast.List
+	var body paragraphs
 	for p.s.Next() {
 		tok := p.s.Token()
-		node := p.parseNode(tok)
-		if node == nil {
+		if p.parBreak(tok) {
+			body.end()
 			continue
 		}
-		nodes = append(nodes, node)
+		body.add(p.parseNode(tok))
 	}
 
-	return nodes, nil
+	p.errors.Sort()
+	return body.list(), p.errors.Err()
+}
+
+// paragraphs groups the nodes of a text-mode body into paragraphs.
+type paragraphs struct {
+	cur  ast.List // nodes of the current paragraph
+	pars ast.List // completed paragraphs
+}
+
+func (ps *paragraphs) add(node ast.Node) {
+	if node == nil {
+		return
+	}
+	ps.cur = append(ps.cur, node)
+}
+
+// end ends the current paragraph, if it is not empty.
+func (ps *paragraphs) end() {
+	if len(ps.cur) == 0 {
+		return
+	}
+	ps.pars = append(ps.pars, &ast.Paragraph{List: ps.cur})
+	ps.cur = nil
+}
+
+// list returns the body nodes.
+// A body made of a single paragraph is returned as a plain list of nodes.
+func (ps *paragraphs) list() ast.List {
+	ps.end()
+	switch len(ps.pars) {
+	case 0:
+		return nil
+	case 1:
+		return ps.pars[0].(*ast.Paragraph).List
+	default:
+		return ps.pars
+	}
+}
+
+// parBreak reports whether tok is a paragraph break in text mode.
+func (p *parser) parBreak(tok token.Token) bool {
+	return tok.Kind == token.EmptyLine && p.state == normalState
+}
+
+// position returns the file, line and column information of pos.
+func (p *parser) position(pos token.Pos) token.Position {
+	if p.file != nil {
+		return p.file.Position(pos)
+	}
+	off := int(pos)
+	if off < 0 || off > len(p.src) {
+		return token.Position{}
+	}
+	src := p.src[:off]
+	return token.Position{
+		Offset: off,
+		Line:   strings.Count(src, "\n") + 1,
+		Column: off - strings.LastIndex(src, "\n"),
+	}
+}
+
+func (p *parser) error(pos token.Pos, msg string) {
+	p.errors.Add(p.position(pos), msg)
+}
+
+func (p *parser) errorf(pos token.Pos, format string, args ...interface{}) {
+	p.error(pos, fmt.Sprintf(format, args...))
 }
 
 func (p *parser) next() token.Token {
 	if !p.s.Next() {
-		return token.Token{Kind: token.EOF}
+		return token.Token{Kind: token.EOF, Pos: p.s.tok.Pos}
 	}
 	return p.s.tok
 }
 
-func (p *parser) expect(v rune) {
-	p.next()
-	if p.s.tok.Text != string(v) {
-		panic(fmt.Errorf("expected %q, got %q", v, p.s.tok.Text))
+// expect consumes the next token and reports whether it is v.
+// If it is not, an error is reported and the token is pushed back
+// to the scanner.
+func (p *parser) expect(v rune) bool {
+	tok := p.next()
+	if tok.Text != string(v) {
+		p.errorf(tok.Pos, "expected %q, got %q", v, tok.Text)
+		p.s.unread()
+		return false
+	}
+	return true
+}
+
+// bad returns a node covering the offending token tok.
+func (p *parser) bad(tok token.Token) *ast.Bad {
+	return &ast.Bad{
+		From: tok.Pos,
+		To:   token.Pos(int(tok.Pos) + len(tok.Text)),
 	}
 }
 
 func (p *parser) parseNode(tok token.Token) ast.Node {
+	if p.unicodeMath(tok) {
+		return p.parseNode(p.next())
+	}
+
 	switch tok.Kind {
 	case token.Comment:
-		return nil
+		return &ast.Comment{
+			Percent: tok.Pos,
+			Text:    tok.Text,
+		}
 	case token.Macro:
 		return p.parseMacro(tok)
 	case token.Word:
@@ -81,30 +242,32 @@ func (p *parser) parseNode(tok token.Token) ast.Node {
 		return p.parseNumber(tok)
 	case token.Symbol:
 		switch tok.Text {
-		case "$":
+		case "$", "$$":
 			return p.parseMathExpr(tok)
 		case "^":
 			return p.parseSup(tok)
 		case "_":
 			return p.parseSub(tok)
+		case "'":
+			if p.state == mathState {
+				return p.parsePrimes(tok)
+			}
+			return p.parseSymbol(tok)
+		case "&":
+			// alignment tabs are handled by parseTable.
+			p.error(tok.Pos, "misplaced alignment tab character &")
+			return p.bad(tok)
 		default:
 			return p.parseSymbol(tok)
 		}
 	case token.Lbrace:
-		switch p.state {
-		case mathState:
-			return p.parseMathLbrace(tok)
-		default:
-			panic("not implemented")
-		}
+		return p.parseGroup(tok)
 	case token.Other:
-		switch tok.Text {
-		default:
-			panic("not implemented: " + tok.String())
-		}
+		p.errorf(tok.Pos, "unexpected token %q", tok.Text)
+		return p.bad(tok)
 	case token.Space:
-		switch p.state {
-		case mathState:
+		switch {
+		case p.state == mathState && !isExplicitSpace(tok.Text):
 			return nil
 		default:
 			return p.parseSymbol(tok)
@@ -114,16 +277,40 @@ func (p *parser) parseNode(tok token.Token) ast.Node {
 		token.Lbrack, token.Rbrack:
 		return p.parseSymbol(tok)
 
+	case token.Param:
+		p.errorf(tok.Pos, "parameter %q outside of a macro definition", tok.Text)
+		return p.bad(tok)
+
+	case token.Invalid:
+		// error already reported by the scanner.
+		return p.bad(tok)
+
+	case token.EmptyLine:
+		// paragraph breaks in text mode are handled by the callers.
+		p.error(tok.Pos, "paragraph break in math mode")
+		return p.bad(tok)
+
+	case token.EOF:
+		p.error(tok.Pos, "unexpected EOF")
+		return p.bad(tok)
+
 	default:
-		panic(fmt.Errorf("impossible: %v (%v)", tok, tok.Kind))
+		p.errorf(tok.Pos, "unexpected %v %q", tok.Kind, tok.Text)
+		return p.bad(tok)
 	}
 }
 
 func (p *parser) parseMathExpr(tok token.Token) ast.Node {
-	state := p.state
+	if p.state == mathState {
+		p.errorf(tok.Pos, "%q not allowed in math mode", tok.Text)
+		return p.bad(tok)
+	}
+
+	state, macros := p.state, p.openScope()
 	p.state = mathState
 	defer func() {
 		p.state = state
+		p.macros = macros
 	}()
 
 	math := &ast.MathExpr{
@@ -134,24 +321,45 @@ func (p *parser) parseMathExpr(tok token.Token) ast.Node {
 	switch tok.Text {
 	case "$":
 		end = "$"
+	case "$$":
+		end = "$$"
+		math.Display = true
 	case `\(`:
 		end = `\)`
 	case `\[`:
 		end = `\]`
-	case `\begin`:
-		panic("not implemented")
+		math.Display = true
 	default:
-		panic(fmt.Errorf("opening math-expression delimiter %q not supported", tok.Text))
+		p.errorf(tok.Pos, "opening math-expression delimiter %q not supported", tok.Text)
+		return p.bad(tok)
 	}
 
-loop:
 	for p.s.Next() {
-		switch p.s.tok.Text {
-		case end:
-			math.Right = p.s.tok.Pos
-			break loop
+		tok := p.s.tok
+		switch {
+		case tok.Text == end:
+			math.Right = tok.Pos
+			return math
+		case end == "$" && tok.Text == "$$":
+			// the first '$' closes this expression, the second one opens
+			// a new one.
+			math.Right = tok.Pos
+			p.s.push([]token.Token{{
+				Kind: token.Symbol,
+				Pos:  tok.Pos + 1,
+				Text: "$",
+			}}, p.s.depth)
+			return math
+		case end == "$$" && tok.Text == "$":
+			p.errorf(tok.Pos, `display math should end with "$$"`)
+			math.Right = tok.Pos
+			return math
+		case end != "$" && end != "$$" && (tok.Text == `\)` || tok.Text == `\]`):
+			p.errorf(tok.Pos, "%q does not match %q", tok.Text, math.Delim)
+			math.Right = tok.Pos
+			return math
 		default:
-			node := p.parseNode(p.s.tok)
+			node := p.parseNode(tok)
 			if node == nil {
 				continue
 			}
@@ -159,15 +367,36 @@ loop:
 		}
 	}
 
+	math.Right = p.s.tok.Pos
+	math.List = append(math.List, &ast.Bad{From: math.Right, To: math.Right})
+	p.errorf(tok.Pos, "missing closing %q", end)
 	return math
 }
 
 func (p *parser) parseMacro(tok token.Token) ast.Node {
 	name := tok.Text
-	macro, ok := p.macros[name]
-	if !ok {
-		panic("unknown macro " + name)
-		//return nil
+	switch name {
+	case `\begin`:
+		return p.parseEnv(tok)
+	case `\end`:
+		name, rbrace, _ := p.parseEnvName()
+		p.errorf(tok.Pos, `unexpected \end{%s}`, name.Name)
+		return &ast.Bad{From: tok.Pos, To: rbrace + 1}
+	case `\(`, `\[`:
+		return p.parseMathExpr(tok)
+	case `\)`, `\]`:
+		p.errorf(tok.Pos, "unexpected %q", name)
+		return p.bad(tok)
+	case `\left`:
+		return p.parseDelimited(tok)
+	case `\middle`, `\right`:
+		return p.parseStrayDelim(tok)
+	}
+
+	macro, ok := p.macro(name)
+	if !ok || macro == nil {
+		p.errorf(tok.Pos, "unknown macro %q", name)
+		return p.bad(tok)
 	}
 	return macro.parseMacro(p)
 }
@@ -186,31 +415,95 @@ func (p *parser) parseNumber(tok token.Token) ast.Node {
 	}
 }
 
-func (p *parser) parseMacroArg(macro *ast.Macro) {
-	var arg ast.Arg
-	p.expect('{')
-	arg.Lbrace = p.s.tok.Pos
-
-loop:
-	for p.s.Next() {
-		switch p.s.tok.Kind {
-		case token.Rbrace:
-			arg.Rbrace = p.s.tok.Pos
-			break loop
-		default:
-			node := p.parseNode(p.s.tok)
-			if node == nil {
-				continue
+// parseArgs parses the arguments described by the signature sig and
+// appends them to args.
+//
+// Each character of sig describes an argument:
+//   - 'a': a mandatory argument,
+//   - 'o': an optional argument,
+//   - 'v': a verbatim argument, delimited by its first character or
+//     enclosed in braces,
+//   - 'l': a mandatory length argument,
+//   - 's': an optional length argument,
+//   - 'k': an optional argument holding key-value options.
+func (p *parser) parseArgs(sig string, args *ast.List) {
+	for _, typ := range strings.ToLower(sig) {
+		switch typ {
+		case 'a':
+			if !p.parseMacroArg(args) {
+				return
+			}
+		case 'o':
+			p.parseOptMacroArg(args)
+		case 'v':
+			if !p.parseVerbatimMacroArg(args) {
+				return
 			}
-			arg.List = append(arg.List, node)
+		case 'l':
+			if !p.parseLengthArg(args, false) {
+				return
+			}
+		case 's':
+			p.parseLengthArg(args, true)
+		case 'k':
+			p.parseKeyValArg(args)
+		}
+	}
+}
+
+// parseMacroArg parses a mandatory argument.
+// As in TeX, an argument not enclosed in braces is made of a single token,
+// e.g. the 1 and the x of \frac1x, and is appended to args as is.
+// parseMacroArg reports whether the argument could be parsed.
+func (p *parser) parseMacroArg(args *ast.List) bool {
+	tok := p.next()
+	for tok.Kind == token.Space {
+		// spaces before an argument are skipped.
+		tok = p.next()
+	}
+	switch {
+	case tok.Kind == token.Lbrace:
+		arg := ast.Arg{Lbrace: tok.Pos}
+		arg.List, arg.Rbrace = p.parseList(arg.Lbrace, token.Rbrace)
+		*args = append(*args, &arg)
+		return true
+
+	case tok.Kind == token.EOF, tok.Kind == token.Rbrace,
+		tok.Kind == token.EmptyLine, tok.Kind == token.Comment,
+		tok.Kind == token.Symbol && (tok.Text == "$" || tok.Text == "$$"):
+		p.errorf(tok.Pos, "missing argument, got %q", tok.Text)
+		p.s.unread()
+		*args = append(*args, &ast.Bad{From: tok.Pos, To: tok.Pos})
+		return false
+	}
+
+	switch tok.Kind {
+	case token.Word, token.Number:
+		// only the first character of a word or of a number is the
+		// argument, e.g. the 1 of \frac12.
+		_, n := utf8.DecodeRuneInString(tok.Text)
+		if rest := tok.Text[n:]; rest != "" {
+			p.s.push([]token.Token{{
+				Kind: tok.Kind,
+				Pos:  tok.Pos + token.Pos(n),
+				Text: rest,
+			}}, p.s.depth)
+			tok.Text = tok.Text[:n]
 		}
 	}
-	macro.Args = append(macro.Args, &arg)
+	node := p.parseNode(tok)
+	for node == nil {
+		// e.g. a macro expanded along with the following source.
+		node = p.parseNode(p.next())
+	}
+	*args = append(*args, node)
+	return true
 }
 
-func (p *parser) parseOptMacroArg(macro *ast.Macro) {
-	nxt := p.s.sc.Peek()
-	if nxt != '[' {
+func (p *parser) parseOptMacroArg(args *ast.List) {
+	// peek at the next character rather than at the next token, so a
+	// following verbatim argument or environment body is left unscanned.
+	if p.s.peekRune() != '[' {
 		return
 	}
 
@@ -218,25 +511,36 @@ func (p *parser) parseOptMacroArg(macro *ast.Macro) {
 
 	p.expect('[')
 	opt.Lbrack = p.s.tok.Pos
+	opt.List, opt.Rbrack = p.parseList(opt.Lbrack, token.Rbrack)
+	*args = append(*args, &opt)
+}
 
-loop:
-	for p.s.Next() {
-		switch p.s.tok.Kind {
-		case token.Rbrack:
-			opt.Rbrack = p.s.tok.Pos
-			break loop
-		default:
-			node := p.parseNode(p.s.tok)
-			if node == nil {
-				continue
-			}
-			opt.List = append(opt.List, node)
-		}
+// parseVerbatimMacroArg parses a verbatim argument, delimited by its first
+// character (e.g. |a%b| in \verb|a%b|) or enclosed in braces.
+// parseVerbatimMacroArg reports whether the argument could be parsed.
+func (p *parser) parseVerbatimMacroArg(args *ast.List) bool {
+	if !p.rawScanning() {
+		pos := p.s.tok.Pos
+		p.error(pos, "verbatim argument not allowed inside a macro expansion")
+		*args = append(*args, &ast.Bad{From: pos, To: pos})
+		return false
+	}
+
+	tok, ok := p.s.scanVerbatim()
+	if !ok {
+		*args = append(*args, &ast.Bad{From: tok.Pos, To: token.Pos(int(tok.Pos) + len(tok.Text))})
+		return false
 	}
-	macro.Args = append(macro.Args, &opt)
+	*args = append(*args, &ast.Verbatim{VerbPos: tok.Pos, Text: tok.Text})
+	return true
 }
 
-func (p *parser) parseVerbatimMacroArg(macro *ast.Macro) {
+// rawScanning reports whether the source following the current token
+// can be scanned verbatim, i.e. whether the current token does not come
+// from a macro expansion, no token has been scanned ahead and no token
+// may have to be parsed again (see parseExpansion).
+func (p *parser) rawScanning() bool {
+	return p.s.depth == 0 && len(p.s.queue) == 0 && p.s.logging == 0
 }
 
 func (p *parser) parseSup(tok token.Token) ast.Node {
@@ -244,29 +548,54 @@ func (p *parser) parseSup(tok token.Token) ast.Node {
 		HatPos: tok.Pos,
 	}
 
-	switch next := p.s.sc.Peek(); next {
-	case '{':
-		p.expect('{')
-		var list ast.List
-	loop:
-		for p.s.Next() {
-			switch p.s.tok.Kind {
-			case token.Rbrace:
-				break loop
-			default:
-				node := p.parseNode(p.s.tok)
-				if node == nil {
-					continue
-				}
-				list = append(list, node)
-			}
+	hat.Node = p.parseScript(tok)
+	p.checkScript(tok.Text)
+
+	return hat
+}
+
+// parsePrimes parses a run of primes, e.g. the primes of f'(x), into a
+// superscript holding each prime as a "'" symbol.
+// As in TeX, an explicit superscript immediately following the primes is
+// merged into that superscript, e.g. f'^2 or f'² is parsed as
+// f^{\prime 2}, whereas primes following a superscript, e.g. f^2', are
+// reported as a double superscript.
+func (p *parser) parsePrimes(tok token.Token) ast.Node {
+	var (
+		sup  = &ast.Sup{HatPos: tok.Pos}
+		list = ast.List{&ast.Symbol{SymPos: tok.Pos, Text: tok.Text}}
+	)
+	for {
+		next := p.s.peek()
+		if p.cfg.UnicodeMath && isPrime(firstRune(next.Text)) {
+			p.s.Next()
+			p.unicodeMath(p.s.tok)
+			continue
 		}
-		hat.Node = list
-	default:
-		hat.Node = p.parseNode(p.next())
+		if next.Kind != token.Symbol || next.Text != "'" {
+			break
+		}
+		p.s.Next()
+		list = append(list, &ast.Symbol{SymPos: next.Pos, Text: next.Text})
 	}
 
-	return hat
+	if next := p.s.peek(); p.cfg.UnicodeMath && superscripts[firstRune(next.Text)] != 0 {
+		p.s.Next()
+		p.unicodeMath(p.s.tok)
+	}
+	if next := p.s.peek(); next.Kind == token.Symbol && next.Text == "^" {
+		p.s.Next()
+		switch arg := p.parseScript(p.s.tok).(type) {
+		case ast.List:
+			list = append(list, arg...)
+		default:
+			list = append(list, arg)
+		}
+		p.checkScript("^")
+	}
+
+	sup.Node = list
+	return sup
 }
 
 func (p *parser) parseSub(tok token.Token) ast.Node {
@@ -274,64 +603,141 @@ func (p *parser) parseSub(tok token.Token) ast.Node {
 		UnderPos: tok.Pos,
 	}
 
-	switch next := p.s.sc.Peek(); next {
-	case '{':
+	sub.Node = p.parseScript(tok)
+	p.checkScript(tok.Text)
+
+	return sub
+}
+
+// checkScript reports an error if the script op (^ or _) that has just
+// been parsed is immediately followed by another script of the same kind,
+// e.g. x^2^3, x^2² or x^2', which TeX rejects as a double superscript.
+func (p *parser) checkScript(op string) {
+	next := p.s.peek()
+	switch {
+	case next.Kind == token.Symbol && next.Text == op:
+	case next.Kind == token.Symbol && next.Text == "'" && op == "^":
+	case p.cfg.UnicodeMath && op == "^" && superscripts[firstRune(next.Text)] != 0:
+	case p.cfg.UnicodeMath && op == "_" && subscripts[firstRune(next.Text)] != 0:
+	default:
+		return
+	}
+	kind := "superscript"
+	if op == "_" {
+		kind = "subscript"
+	}
+	p.errorf(next.Pos, "double %s", kind)
+}
+
+// parseScript parses the argument of a superscript or subscript.
+func (p *parser) parseScript(tok token.Token) ast.Node {
+	if p.s.peek().Kind == token.Lbrace {
 		p.expect('{')
-		var list ast.List
-	loop:
-		for p.s.Next() {
-			switch p.s.tok.Kind {
-			case token.Rbrace:
-				break loop
-			default:
-				node := p.parseNode(p.s.tok)
-				if node == nil {
-					continue
-				}
-				list = append(list, node)
-			}
+		list, _ := p.parseList(p.s.tok.Pos, token.Rbrace)
+		return list
+	}
+
+	// comments between a script and its argument are kept together with
+	// the argument, as if they were enclosed in braces.
+	var comments ast.List
+	for {
+		next := p.next()
+		switch {
+		case next.Kind == token.EOF, next.Kind == token.Rbrace,
+			next.Kind == token.Symbol && next.Text == "$":
+			p.errorf(tok.Pos, "missing argument for %q", tok.Text)
+			p.s.unread()
+			return &ast.Bad{From: next.Pos, To: next.Pos}
 		}
-		sub.Node = list
-	default:
-		sub.Node = p.parseNode(p.next())
+		node := p.parseNode(next)
+		switch node.(type) {
+		case nil:
+			continue
+		case *ast.Comment:
+			comments = append(comments, node)
+			continue
+		}
+		if len(comments) > 0 {
+			return append(comments, node)
+		}
+		return node
 	}
+}
 
-	return sub
+// parseList parses nodes until the closing delimiter rdelim.
+// parseList returns the parsed nodes and the position of the closing delimiter.
+func (p *parser) parseList(ldelim token.Pos, rdelim token.Kind) (ast.List, token.Pos) {
+	var body paragraphs
+	for p.s.Next() {
+		tok := p.s.tok
+		switch {
+		case tok.Kind == rdelim:
+			return body.list(), tok.Pos
+		case p.parBreak(tok):
+			body.end()
+		default:
+			body.add(p.parseNode(tok))
+		}
+	}
+	end := p.s.tok.Pos
+	body.add(&ast.Bad{From: end, To: end})
+	p.errorf(ldelim, "missing closing %q", closing[rdelim])
+	return body.list(), end
+}
+
+var closing = map[token.Kind]string{
+	token.Rbrace: "}",
+	token.Rbrack: "]",
+	token.Rparen: ")",
+}
+
+// isExplicitSpace reports whether the space text is kept in math mode,
+// i.e. whether it is a non-breaking space or a control space, e.g. "\ ".
+func isExplicitSpace(text string) bool {
+	return text == "~" || strings.HasPrefix(text, `\`)
 }
 
 func (p *parser) parseSymbol(tok token.Token) ast.Node {
+	if p.state == normalState && tok.Kind == token.Symbol {
+		tok = p.ligature(tok)
+	}
 	return &ast.Symbol{
 		SymPos: tok.Pos,
 		Text:   tok.Text,
 	}
 }
 
-func (p *parser) parseMathLbrace(tok token.Token) ast.Node {
-	var (
-		lst    ast.List
-		ldelim = tok.Kind
-		rdelim = map[token.Kind]token.Kind{
-			token.Lbrace: token.Rbrace,
-			token.Lparen: token.Rparen,
-		}[ldelim]
-	)
-
-	if rdelim == token.Invalid {
-		panic("impossible: no matching right-delim for: " + tok.String())
-	}
+// ligatures are the sequences of characters typeset as a single glyph in
+// text mode.
+var ligatures = map[string]bool{
+	"--":  true, // en dash
+	"---": true, // em dash
+	"``":  true, // opening double quote
+	"''":  true, // closing double quote
+}
 
-loop:
-	for p.s.Next() {
-		switch p.s.tok.Kind {
-		case rdelim:
-			break loop
-		default:
-			node := p.parseNode(p.s.tok)
-			if node == nil {
-				continue
-			}
-			lst = append(lst, node)
+// ligature returns the longest ligature starting with the symbol tok,
+// made of the immediately following symbols.
+func (p *parser) ligature(tok token.Token) token.Token {
+	for {
+		next := p.s.peek()
+		end := token.Pos(int(tok.Pos) + len(tok.Text))
+		if next.Kind != token.Symbol || next.Pos != end || !ligatures[tok.Text+next.Text] {
+			return tok
 		}
+		p.s.Next()
+		tok.Text += next.Text
 	}
-	return lst
+}
+
+// parseGroup parses a group of nodes enclosed in braces.
+func (p *parser) parseGroup(tok token.Token) ast.Node {
+	macros := p.openScope()
+	defer func() {
+		p.macros = macros
+	}()
+
+	grp := &ast.Group{Lbrace: tok.Pos}
+	grp.List, grp.Rbrace = p.parseList(tok.Pos, token.Rbrace)
+	return grp
 }
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
 )
 
 func TestParser(t *testing.T) {
@@ -31,7 +32,7 @@ func TestParser(t *testing.T) {
 			},
 		},
 		{
-			input: `empty equation $$`,
+			input: `empty equation $ $`,
 			want: ast.List{
 				&ast.Word{Text: "empty"},
 				&ast.Symbol{Text: " "},
@@ -42,6 +43,67 @@ func TestParser(t *testing.T) {
 				},
 			},
 		},
+		{
+			input: `empty display $$$$`,
+			want: ast.List{
+				&ast.Word{Text: "empty"},
+				&ast.Symbol{Text: " "},
+				&ast.Word{Text: "display"},
+				&ast.Symbol{Text: " "},
+				&ast.MathExpr{
+					Delim:   "$$",
+					Display: true,
+				},
+			},
+		},
+		{
+			input: `{\bf bold} normal`,
+			want: ast.List{
+				&ast.Group{
+					List: ast.List{
+						&ast.Macro{Name: &ast.Ident{Name: `\bf`}},
+						&ast.Symbol{Text: " "},
+						&ast.Word{Text: "bold"},
+					},
+				},
+				&ast.Symbol{Text: " "},
+				&ast.Word{Text: "normal"},
+			},
+		},
+		{
+			// single-token arguments.
+			input: `$\frac12 + \frac a\pi + \sqrt xy$`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim: "$",
+					List: ast.List{
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\frac`},
+							Args: ast.List{
+								&ast.Literal{Text: "1"},
+								&ast.Literal{Text: "2"},
+							},
+						},
+						&ast.Symbol{Text: "+"},
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\frac`},
+							Args: ast.List{
+								&ast.Word{Text: "a"},
+								&ast.Macro{Name: &ast.Ident{Name: `\pi`}},
+							},
+						},
+						&ast.Symbol{Text: "+"},
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\sqrt`},
+							Args: ast.List{
+								&ast.Word{Text: "x"},
+							},
+						},
+						&ast.Word{Text: "y"},
+					},
+				},
+			},
+		},
 		{
 			input: `$+10x$`,
 			want: ast.List{
@@ -61,7 +123,7 @@ func TestParser(t *testing.T) {
 				&ast.MathExpr{
 					Delim: "$",
 					List: ast.List{
-						ast.List{}, // FIXME(sbinet): shouldn't this be a "group"?
+						&ast.Group{},
 						&ast.Symbol{Text: "+"},
 						&ast.Literal{Text: "10"},
 						&ast.Word{Text: "x"},
@@ -353,8 +415,7 @@ func TestParser(t *testing.T) {
 				&ast.Symbol{Text: " "},
 				&ast.Word{Text: "Dummy"},
 				&ast.Symbol{Text: " "},
-				&ast.Symbol{Text: "-"},
-				&ast.Symbol{Text: "-"},
+				&ast.Symbol{Text: "--"},
 				&ast.Symbol{Text: " "},
 				&ast.MathExpr{
 					List: ast.List{
@@ -403,18 +464,47 @@ func TestParser(t *testing.T) {
 				},
 			},
 		},
-		//	{ // FIXME(sbinet): not ready
-		//		input: `\[x =3\]`,
-		//		want:  nil,
-		//	},
-		//	{ // FIXME(sbinet): not ready
-		//		input: `\(x =3\)`,
-		//		want:  nil,
-		//	},
-		//	{ // FIXME(sbinet): not ready
-		//		input: `\begin{equation}x=3\end{equation}`,
-		//		want: nil,
-		//	},
+		{
+			input: `\[x =3\]`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim:   `\[`,
+					Display: true,
+					List: ast.List{
+						&ast.Word{Text: "x"},
+						&ast.Symbol{Text: "="},
+						&ast.Literal{Text: "3"},
+					},
+				},
+			},
+		},
+		{
+			input: `\(x =3\)`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim: `\(`,
+					List: ast.List{
+						&ast.Word{Text: "x"},
+						&ast.Symbol{Text: "="},
+						&ast.Literal{Text: "3"},
+					},
+				},
+			},
+		},
+		{
+			input: `\begin{equation}x=3\end{equation}`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim:   `\begin{equation}`,
+					Display: true,
+					List: ast.List{
+						&ast.Word{Text: "x"},
+						&ast.Symbol{Text: "="},
+						&ast.Literal{Text: "3"},
+					},
+				},
+			},
+		},
 		{
 			input: `$x_i$`,
 			want: ast.List{
@@ -489,6 +579,7 @@ func TestTokenPos(t *testing.T) {
 	for _, tc := range []struct {
 		input string
 		want  ast.Node
+		ends  []token.Pos // end positions of the top-level nodes, if any
 	}{
 		{
 			input: `hello`,
@@ -503,7 +594,15 @@ func TestTokenPos(t *testing.T) {
 			},
 		},
 		{
-			input: `empty equation $$`,
+			input: "hello\n  world",
+			want: ast.List{
+				&ast.Word{Text: "hello"},
+				&ast.Symbol{Text: "\n  ", SymPos: 5},
+				&ast.Word{Text: "world", WordPos: 8},
+			},
+		},
+		{
+			input: `empty equation $ $`,
 			want: ast.List{
 				&ast.Word{Text: "empty"},
 				&ast.Symbol{Text: " ", SymPos: 5},
@@ -512,7 +611,22 @@ func TestTokenPos(t *testing.T) {
 				&ast.MathExpr{
 					Delim: "$",
 					Left:  15,
-					Right: 16,
+					Right: 17,
+				},
+			},
+		},
+		{
+			input: `empty display $$$$`,
+			want: ast.List{
+				&ast.Word{Text: "empty"},
+				&ast.Symbol{Text: " ", SymPos: 5},
+				&ast.Word{Text: "display", WordPos: 6},
+				&ast.Symbol{Text: " ", SymPos: 13},
+				&ast.MathExpr{
+					Delim:   "$$",
+					Display: true,
+					Left:    14,
+					Right:   16,
 				},
 			},
 		},
@@ -585,18 +699,77 @@ func TestTokenPos(t *testing.T) {
 				},
 			},
 		},
-		//	{ // FIXME(sbinet): not ready
-		//		input: `\[x =3\]`,
-		//		want:  nil,
-		//	},
-		//	{ // FIXME(sbinet): not ready
-		//		input: `\(x =3\)`,
-		//		want:  nil,
-		//	},
-		//	{ // FIXME(sbinet): not ready
-		//		input: `\begin{equation}x=3\end{equation}`,
-		//		want: nil,
-		//	},
+		{
+			input: `\[x =3\]`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim:   `\[`,
+					Display: true,
+					Left:    0,
+					List: ast.List{
+						&ast.Word{Text: "x", WordPos: 2},
+						&ast.Symbol{Text: "=", SymPos: 4},
+						&ast.Literal{Text: "3", LitPos: 5},
+					},
+					Right: 6,
+				},
+			},
+		},
+		{
+			input: `\(x =3\)`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim: `\(`,
+					Left:  0,
+					List: ast.List{
+						&ast.Word{Text: "x", WordPos: 2},
+						&ast.Symbol{Text: "=", SymPos: 4},
+						&ast.Literal{Text: "3", LitPos: 5},
+					},
+					Right: 6,
+				},
+			},
+		},
+		{
+			input: `\begin{equation}x=3\end{equation}`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim:   `\begin{equation}`,
+					Display: true,
+					Left:    0,
+					List: ast.List{
+						&ast.Word{Text: "x", WordPos: 16},
+						&ast.Symbol{Text: "=", SymPos: 17},
+						&ast.Literal{Text: "3", LitPos: 18},
+					},
+					Right: 33,
+				},
+			},
+			ends: []token.Pos{33},
+		},
+		{
+			input: `\begin{center}x\end{center}y`,
+			want: ast.List{
+				&ast.Env{
+					Name:   &ast.Ident{Name: "center", NamePos: 7},
+					Body:   ast.List{&ast.Word{Text: "x", WordPos: 14}},
+					EndPos: 27,
+				},
+				&ast.Word{Text: "y", WordPos: 27},
+			},
+			ends: []token.Pos{27, 28},
+		},
+		{
+			input: `\begin{verbatim}x\end{verbatim}`,
+			want: ast.List{
+				&ast.Env{
+					Name:   &ast.Ident{Name: "verbatim", NamePos: 7},
+					Body:   ast.List{&ast.Verbatim{Text: "x", VerbPos: 16}},
+					EndPos: 31,
+				},
+			},
+			ends: []token.Pos{31},
+		},
 		{
 			input: `$x_i$`,
 			want: ast.List{
@@ -614,6 +787,36 @@ func TestTokenPos(t *testing.T) {
 				},
 			},
 		},
+		{
+			input: `\def\a{x}$\a$\newcommand{\b}[1]{#1}`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:    &ast.Ident{Name: `\def`},
+					Name:   &ast.Ident{Name: `\a`, NamePos: 4},
+					Body:   "x",
+					Rbrace: 8,
+				},
+				&ast.MathExpr{
+					Delim: "$",
+					Left:  9,
+					List: ast.List{
+						&ast.Macro{
+							Name:      &ast.Ident{Name: `\a`, NamePos: 10},
+							Expansion: ast.List{&ast.Word{Text: "x", WordPos: 7}},
+						},
+					},
+					Right: 12,
+				},
+				&ast.MacroDef{
+					Cmd:    &ast.Ident{Name: `\newcommand`, NamePos: 13},
+					Name:   &ast.Ident{Name: `\b`, NamePos: 25},
+					NArgs:  1,
+					Body:   "#1",
+					Rbrace: 34,
+				},
+			},
+			ends: []token.Pos{9, 12, 35},
+		},
 	} {
 		t.Run("", func(t *testing.T) {
 			node, err := ParseExpr(tc.input)
@@ -624,7 +827,1354 @@ func TestTokenPos(t *testing.T) {
 			if got, want := node, tc.want; !reflect.DeepEqual(got, want) {
 				t.Fatalf("invalid positions:\ngot= %v\nwant=%v", got, want)
 			}
+
+			for i, end := range tc.ends {
+				if got, want := node.(ast.List)[i].End(), end; got != want {
+					t.Fatalf("invalid end position of node %d: got=%d, want=%d", i, got, want)
+				}
+			}
+		})
+	}
+
+}
+
+func TestArgPos(t *testing.T) {
+	const input = `\sqrt[3]{x+1}y`
+	node, err := ParseExpr(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	macro := node.(ast.List)[0].(*ast.Macro)
+	for i, want := range []struct {
+		pos, end token.Pos
+		text     string
+	}{
+		{5, 8, "[3]"},
+		{8, 13, "{x+1}"},
+	} {
+		arg := macro.Args[i]
+		if got := [2]token.Pos{arg.Pos(), arg.End()}; got != [2]token.Pos{want.pos, want.end} {
+			t.Errorf("invalid position of argument %d: got=%v, want=[%d %d]", i, got, want.pos, want.end)
+			continue
+		}
+		if got := input[arg.Pos():arg.End()]; got != want.text {
+			t.Errorf("invalid source of argument %d: got=%q, want=%q", i, got, want.text)
+		}
+	}
+	if got, want := macro.End(), token.Pos(13); got != want {
+		t.Errorf("invalid end position of macro: got=%d, want=%d", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `$\foo$`,
+			want:  `1:2: unknown macro "\\foo"`,
+		},
+		{
+			input: "hello\n$x + \\foo^2$",
+			want:  `2:6: unknown macro "\\foo"`,
+		},
+		{
+			input: `{\bf bold normal`,
+			want:  `1:1: missing closing "}"`,
+		},
+		{
+			input: `$x+1`,
+			want:  `1:1: missing closing "$"`,
+		},
+		{
+			input: `\textbf{bold`,
+			want:  `1:8: missing closing "}"`,
+		},
+		{
+			input: `$\frac1$`,
+			want:  `1:8: missing argument, got "$"`,
+		},
+		{
+			input: `$x^$`,
+			want:  `1:3: missing argument for "^"`,
+		},
+		{
+			input: `$a & b$`,
+			want:  `1:4: misplaced alignment tab character &`,
+		},
+		{
+			input: "$x \x01$",
+			want:  `1:4: unhandled token "\x01"`,
+		},
+		{
+			input: `a} b`,
+			want:  `1:2: unexpected Rbrace "}"`,
+		},
+		{
+			input: `$x^2^3$`,
+			want:  `1:5: double superscript`,
+		},
+		{
+			input: `$x^2'$`,
+			want:  `1:5: double superscript`,
+		},
+		{
+			input: `$f'^2''$`,
+			want:  `1:6: double superscript`,
+		},
+		{
+			input: `$x_{i}_j$`,
+			want:  `1:7: double subscript`,
+		},
+		{
+			input: `$\foo + \baz$`,
+			want:  `1:2: unknown macro "\\foo" (and 1 more errors)`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if _, ok := err.(ErrorList); !ok {
+				t.Fatalf("invalid error type %T", err)
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
 		})
 	}
+}
 
+func TestParseRecover(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `$\foo + x$`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim: "$",
+					Left:  0,
+					List: ast.List{
+						&ast.Bad{From: 1, To: 5},
+						&ast.Symbol{Text: "+", SymPos: 6},
+						&ast.Word{Text: "x", WordPos: 8},
+					},
+					Right: 9,
+				},
+			},
+		},
+		{
+			input: `$x+1`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim: "$",
+					Left:  0,
+					List: ast.List{
+						&ast.Word{Text: "x", WordPos: 1},
+						&ast.Symbol{Text: "+", SymPos: 2},
+						&ast.Literal{Text: "1", LitPos: 3},
+						&ast.Bad{From: 4, To: 4},
+					},
+					Right: 4,
+				},
+			},
+		},
+		{
+			input: `$\sqrt{x$ y`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim: "$",
+					Left:  0,
+					List: ast.List{
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\sqrt`, NamePos: 1},
+							Args: ast.List{
+								&ast.Arg{
+									Lbrace: 6,
+									List: ast.List{
+										&ast.Word{Text: "x", WordPos: 7},
+										&ast.Bad{From: 8, To: 9},
+										&ast.Word{Text: "y", WordPos: 10},
+										&ast.Bad{From: 11, To: 11},
+									},
+									Rbrace: 11,
+								},
+							},
+						},
+						&ast.Bad{From: 11, To: 11},
+					},
+					Right: 11,
+				},
+			},
+		},
+		{
+			input: `$x^$ ok`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim: "$",
+					Left:  0,
+					List: ast.List{
+						&ast.Word{Text: "x", WordPos: 1},
+						&ast.Sup{
+							HatPos: 2,
+							Node:   &ast.Bad{From: 3, To: 3},
+						},
+					},
+					Right: 3,
+				},
+				&ast.Symbol{Text: " ", SymPos: 4},
+				&ast.Word{Text: "ok", WordPos: 5},
+			},
+		},
+		{
+			input: `$\frac1$`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim: "$",
+					Left:  0,
+					List: ast.List{
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\frac`, NamePos: 1},
+							Args: ast.List{
+								&ast.Literal{Text: "1", LitPos: 6},
+								&ast.Bad{From: 7, To: 7},
+							},
+						},
+					},
+					Right: 7,
+				},
+			},
+		},
+		{
+			input: `a {\foo b} c`,
+			want: ast.List{
+				&ast.Word{Text: "a", WordPos: 0},
+				&ast.Symbol{Text: " ", SymPos: 1},
+				&ast.Group{
+					Lbrace: 2,
+					List: ast.List{
+						&ast.Bad{From: 3, To: 7},
+						&ast.Symbol{Text: " ", SymPos: 7},
+						&ast.Word{Text: "b", WordPos: 8},
+					},
+					Rbrace: 9,
+				},
+				&ast.Symbol{Text: " ", SymPos: 10},
+				&ast.Word{Text: "c", WordPos: 11},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+
+			if got, want := node, tc.want; !reflect.DeepEqual(got, want) {
+				o1 := new(strings.Builder)
+				ast.Print(o1, got)
+				o2 := new(strings.Builder)
+				ast.Print(o2, want)
+				t.Fatalf("invalid ast:\ngot= %v\nwant=%v", o1, o2)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	fset := token.NewFileSet()
+
+	_, err := ParseFile(fset, "a.tex", "hello $x$\n")
+	if err != nil {
+		t.Fatalf("could not parse a.tex: %+v", err)
+	}
+
+	node, err := ParseFile(fset, "b.tex", []byte("hello\n\n$x + \\foo$"))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if got, want := err.Error(), `b.tex:3:6: unknown macro "\\foo"`; got != want {
+		t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+	}
+
+	math := node.(ast.List)[1].(*ast.Paragraph).List[0].(*ast.MathExpr)
+	for _, tc := range []struct {
+		pos  token.Pos
+		want string
+	}{
+		{math.Pos(), "b.tex:3:1"},
+		{math.List[0].Pos(), "b.tex:3:2"},
+		{math.List[1].Pos(), "b.tex:3:4"},
+		{math.End(), "b.tex:3:10"},
+	} {
+		if got := fset.Position(tc.pos).String(); got != tc.want {
+			t.Fatalf("invalid position: got=%s, want=%s", got, tc.want)
+		}
+	}
+
+	_, err = ParseFile(fset, "c.tex", strings.NewReader(`$\alpha$`))
+	if err != nil {
+		t.Fatalf("could not parse c.tex: %+v", err)
+	}
+
+	_, err = ParseFile(fset, "d.tex", 42)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `\begin{align}x=3\end{align}`,
+			want: ast.List{
+				&ast.Table{
+					Env: &ast.Env{Name: &ast.Ident{Name: "align"}},
+					Rows: [][]ast.Cell{{{
+						List: ast.List{
+							&ast.Word{Text: "x"},
+							&ast.Symbol{Text: "="},
+							&ast.Literal{Text: "3"},
+						},
+					}}},
+				},
+			},
+		},
+		{
+			input: `\begin{align*} x \end{align*}`,
+			want: ast.List{
+				&ast.Table{
+					Env: &ast.Env{Name: &ast.Ident{Name: "align*"}},
+					Rows: [][]ast.Cell{{{
+						List: ast.List{&ast.Word{Text: "x"}},
+					}}},
+				},
+			},
+		},
+		{
+			input: `\begin{figure}[htbp]\begin{center}hello\end{center}\end{figure}`,
+			want: ast.List{
+				&ast.Env{
+					Name: &ast.Ident{Name: "figure"},
+					Args: ast.List{
+						&ast.OptArg{List: ast.List{&ast.Word{Text: "htbp"}}},
+					},
+					Body: ast.List{
+						&ast.Env{
+							Name: &ast.Ident{Name: "center"},
+							Body: ast.List{&ast.Word{Text: "hello"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			input: `\begin{minipage}{5}text\end{minipage}`,
+			want: ast.List{
+				&ast.Env{
+					Name: &ast.Ident{Name: "minipage"},
+					Args: ast.List{
+						&ast.Arg{List: ast.List{&ast.Literal{Text: "5"}}},
+					},
+					Body: ast.List{&ast.Word{Text: "text"}},
+				},
+			},
+		},
+		{
+			input: `\begin{foo}a b\end{foo}`,
+			want: ast.List{
+				&ast.Env{
+					Name: &ast.Ident{Name: "foo"},
+					Body: ast.List{
+						&ast.Word{Text: "a"},
+						&ast.Symbol{Text: " "},
+						&ast.Word{Text: "b"},
+					},
+				},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := new(strings.Builder)
+			ast.Print(got, node)
+
+			want := new(strings.Builder)
+			ast.Print(want, tc.want)
+
+			if got.String() != want.String() {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseEnvErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `\begin{itemize} a \end{enumerate}`,
+			want:  `1:24: \end{enumerate} does not match \begin{itemize}`,
+		},
+		{
+			input: `\begin{itemize} a`,
+			want:  `1:1: missing \end{itemize}`,
+		},
+		{
+			input: `a \end{itemize}`,
+			want:  `1:3: unexpected \end{itemize}`,
+		},
+		{
+			input: `\begin{}\end{}`,
+			want:  `1:7: empty environment name (and 2 more errors)`,
+		},
+		{
+			input: `\begin x`,
+			want:  `1:7: expected '{', got " "`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseVerbatim(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `\verb|a%b{\x|`,
+			want: ast.List{
+				&ast.Macro{
+					Name: &ast.Ident{Name: `\verb`},
+					Args: ast.List{&ast.Verbatim{Text: `|a%b{\x|`}},
+				},
+			},
+		},
+		{
+			input: `\verb*!a b! c`,
+			want: ast.List{
+				&ast.Macro{
+					Name: &ast.Ident{Name: `\verb*`},
+					Args: ast.List{&ast.Verbatim{Text: `!a b!`}},
+				},
+				&ast.Symbol{Text: " "},
+				&ast.Word{Text: "c"},
+			},
+		},
+		{
+			input: `\url{a{b}%c}`,
+			want: ast.List{
+				&ast.Macro{
+					Name: &ast.Ident{Name: `\url`},
+					Args: ast.List{&ast.Verbatim{Text: `{a{b}%c}`}},
+				},
+			},
+		},
+		{
+			input: `\lstinline[x]+$a$+`,
+			want: ast.List{
+				&ast.Macro{
+					Name: &ast.Ident{Name: `\lstinline`},
+					Args: ast.List{
+						&ast.OptArg{List: ast.List{&ast.KeyVal{Pairs: []*ast.Pair{{Key: "x"}}}}},
+						&ast.Verbatim{Text: `+$a$+`},
+					},
+				},
+			},
+		},
+		{
+			input: "\\begin{verbatim}\n%{\\x\n\\end{verbatim}",
+			want: ast.List{
+				&ast.Env{
+					Name: &ast.Ident{Name: "verbatim"},
+					Body: ast.List{&ast.Verbatim{Text: "\n%{\\x\n"}},
+				},
+			},
+		},
+		{
+			input: `\begin{lstlisting}[language=Go]x := "\end{x}"\end{lstlisting}`,
+			want: ast.List{
+				&ast.Env{
+					Name: &ast.Ident{Name: "lstlisting"},
+					Args: ast.List{
+						&ast.OptArg{List: ast.List{&ast.KeyVal{Pairs: []*ast.Pair{
+							{Key: "language", Value: ast.List{&ast.Word{Text: "Go"}}},
+						}}}},
+					},
+					Body: ast.List{&ast.Verbatim{Text: `x := "\end{x}"`}},
+				},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := new(strings.Builder)
+			ast.Print(got, node)
+
+			want := new(strings.Builder)
+			ast.Print(want, tc.want)
+
+			if got.String() != want.String() {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseVerbatimErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `\verb|ab`,
+			want:  `1:6: missing closing '|' for verbatim text`,
+		},
+		{
+			input: "\\verb+a\nb",
+			want:  `1:6: missing closing '+' for verbatim text`,
+		},
+		{
+			input: `\verb`,
+			want:  `1:6: missing verbatim delimiter`,
+		},
+		{
+			input: `\begin{verbatim} a`,
+			want:  `1:1: missing \end{verbatim}`,
+		},
+		{
+			input: `\newcommand{\code}{\verb+a+}\code`,
+			want:  `1:20: verbatim argument not allowed inside a macro expansion`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseParagraphs(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: "hello\nworld",
+			want: ast.List{
+				&ast.Word{Text: "hello"},
+				&ast.Symbol{Text: "\n"},
+				&ast.Word{Text: "world"},
+			},
+		},
+		{
+			input: "hello\n\nworld",
+			want: ast.List{
+				&ast.Paragraph{List: ast.List{&ast.Word{Text: "hello"}}},
+				&ast.Paragraph{List: ast.List{&ast.Word{Text: "world"}}},
+			},
+		},
+		{
+			input: "\n\nhello\n \t\n\n$x$ % comment\n\nworld\\par\n",
+			want: ast.List{
+				&ast.Paragraph{List: ast.List{&ast.Word{Text: "hello"}}},
+				&ast.Paragraph{List: ast.List{
+					&ast.MathExpr{List: ast.List{&ast.Word{Text: "x"}}},
+					&ast.Symbol{Text: " "},
+					&ast.Comment{Text: "% comment"},
+				}},
+				&ast.Paragraph{List: ast.List{&ast.Word{Text: "world"}}},
+			},
+		},
+		{
+			input: "a\n  b",
+			want: ast.List{
+				&ast.Word{Text: "a"},
+				&ast.Symbol{Text: "\n  "},
+				&ast.Word{Text: "b"},
+			},
+		},
+		{
+			input: "a% comment\n  b\n",
+			want: ast.List{
+				&ast.Word{Text: "a"},
+				&ast.Comment{Text: "% comment"},
+				&ast.Word{Text: "b"},
+			},
+		},
+		{
+			input: "\\begin{quote}a\n\nb\\end{quote}",
+			want: ast.List{
+				&ast.Env{
+					Name: &ast.Ident{Name: "quote"},
+					Body: ast.List{
+						&ast.Paragraph{List: ast.List{&ast.Word{Text: "a"}}},
+						&ast.Paragraph{List: ast.List{&ast.Word{Text: "b"}}},
+					},
+				},
+			},
+		},
+		{
+			input: "\\textbf{a\\par b}",
+			want: ast.List{
+				&ast.Macro{
+					Name: &ast.Ident{Name: `\textbf`},
+					Args: ast.List{
+						&ast.Arg{List: ast.List{
+							&ast.Paragraph{List: ast.List{&ast.Word{Text: "a"}}},
+							&ast.Paragraph{List: ast.List{
+								&ast.Symbol{Text: " "},
+								&ast.Word{Text: "b"},
+							}},
+						}},
+					},
+				},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := new(strings.Builder)
+			ast.Print(got, node)
+
+			want := new(strings.Builder)
+			ast.Print(want, tc.want)
+
+			if got.String() != want.String() {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+
+	_, err := ParseExpr("$x\n\ny$")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if got, want := err.Error(), `1:3: paragraph break in math mode`; got != want {
+		t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+	}
+}
+
+func TestFprintRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		`hello`,
+		`hello world`,
+		`$x$`,
+		`$x^2+y_{i j}=\sqrt[3]{\frac{a}{b}}$`,
+		`$\alpha x \beta 2 1 1.5 \cdot x$`,
+		`$\mathcal{L}\,=\,3\,ab^{-1}$`,
+		`\textbf{APLAS} Dummy -- $\sqrt{s}=13\,$TeV`,
+		"hello\nworld",
+		"a\n\nb\\par c\n\n\n d \\par\n",
+		`\begin{figure}[htbp]\begin{center}hello\end{center}\end{figure}`,
+		`\begin{equation}x=3\end{equation}`,
+		`\newcommand{\R}{\mathbb{R}}$\R$`,
+		`\newcommand\pow[2][2]{#2^{#1}}$\pow{x}$`,
+		"\\newcommand{\\R}{\\mathbb{R}}\n\nLet $x\\in\\R$ and\n$\\R^2$.\n\n\\def\\sq#1{#1^2}$\\sq x\\sq{x+1}$",
+		`\def\x#1#2{#1+#2}`,
+		`\verb|a%b{\x| and \verb*+x y+`,
+		"\\begin{lstlisting}[language=Go]\nx := `%s`\n\\end{lstlisting}",
+		`\url{a{b}c}`,
+		`{\bf bold {\it both}} normal ${\rm d}x^{2}$`,
+		`$\left(\frac{a}{b}\middle/\left.c\right\rangle\right)$`,
+		`$\left| x \middle\| y \right|$`,
+		"% TODO: fix\nhello % world\n\n%% foo\n\\par x %",
+		"$x^% sup\n2 + 1 % one\n$",
+		"\\begin{center}%\n\\textbf{a} % b\n\\end{center}",
+		`$$x$$ $a$$b$ \[y\] \(z\) \begin{displaymath}E=mc^2\end{displaymath}`,
+		`$\begin{pmatrix}a&b\\c&d\end{pmatrix}$`,
+		"\\begin{tabular}{lr}\\hline a & b \\\\[2pt] % c\n\\cline{1-2}c\\\\ \\hline\\end{tabular}",
+		`\begin{align}x&=1\\y&=\begin{cases}0&x<0\\1\end{cases}\end{align}`,
+		"\\begin{tabular}{c}a\n\nb\\end{tabular}",
+		"``50\\%~off'' -- --- ---- - - $a--b~c\\ d|x|$ \\{\\&\\$\\#\\_\\} café — naïve… x@y \"z\"",
+		`\hspace{-2.5em plus 1fil minus 2pt}\rule[-1ex]{0.5\textwidth}{2}$a\raisebox{1ex}[0pt]{b}$`,
+		`\includegraphics[width=3cm, page={1,2},draft,alt=,trim=1 2]{img}\lstinline[style=x]|y|`,
+		`$f'(x)+g''^{ab}+h'^2+x_i'+y^{'}$ it's`,
+		`1.5. 1.5.5 .5.`,
+		`$$a$$$$b$$`,
+		`\begin{array}{c}a\\ \hline c\end{array}`,
+		`\textbf x \sqrt[3]x`,
+		`\section*{A}\label{a}\begin{itemize}\item[b] c\end{itemize}`,
+		"\\begin{tabular}{l}\n  \\hline\n  a \\\\ % b\n  \\hline\n\\end{tabular}",
+		"\\begin{tabular}{ll}\n  a & b \\\\\n  c & d \\\\\n\\end{tabular}",
+		"a\\\nb $x\\\ny\\ z$",
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(input)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", input, err)
+			}
+
+			for _, mode := range []ast.PrintMode{0, ast.SpaceOperators} {
+				o := new(strings.Builder)
+				err = (&ast.Printer{Mode: mode}).Fprint(o, node)
+				if err != nil {
+					t.Fatalf("could not print %q: %+v", input, err)
+				}
+
+				got, err := ParseExpr(o.String())
+				if err != nil {
+					t.Fatalf("could not parse printed %q: %+v", o, err)
+				}
+
+				if got, want := sprint(got), sprint(node); got != want {
+					t.Fatalf("round-trip failed for %q (printed %q):\ngot= %s\nwant=%s", input, o, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: "% TODO: fix\r\nhello % world",
+			want: ast.List{
+				&ast.Comment{Text: "% TODO: fix"},
+				&ast.Word{Text: "hello"},
+				&ast.Symbol{Text: " "},
+				&ast.Comment{Text: "% world"},
+			},
+		},
+		{
+			input: "$x % first\n= 2^% sup\n3$",
+			want: ast.List{
+				&ast.MathExpr{List: ast.List{
+					&ast.Word{Text: "x"},
+					&ast.Comment{Text: "% first"},
+					&ast.Symbol{Text: "="},
+					&ast.Literal{Text: "2"},
+					&ast.Sup{Node: ast.List{
+						&ast.Comment{Text: "% sup"},
+						&ast.Literal{Text: "3"},
+					}},
+				}},
+			},
+		},
+		{
+			input: `\textbf{a%b}`,
+			want: ast.List{
+				&ast.Macro{
+					Name: &ast.Ident{Name: `\textbf`},
+					Args: ast.List{&ast.Arg{List: ast.List{
+						&ast.Word{Text: "a"},
+						&ast.Comment{Text: "%b}"},
+						&ast.Bad{},
+					}}},
+				},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, _ := ParseExpr(tc.input)
+			if got, want := sprint(node), sprint(tc.want); got != want {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseDelimited(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `$\left( \frac{a}{b} \middle\vert c \right.$`,
+			want: ast.List{
+				&ast.MathExpr{List: ast.List{
+					&ast.Delimited{
+						Left: &ast.Delim{Macro: `\left`, Text: "("},
+						Body: ast.List{
+							&ast.Macro{
+								Name: &ast.Ident{Name: `\frac`},
+								Args: ast.List{
+									&ast.Arg{List: ast.List{&ast.Word{Text: "a"}}},
+									&ast.Arg{List: ast.List{&ast.Word{Text: "b"}}},
+								},
+							},
+							&ast.Delim{Macro: `\middle`, Text: `\vert`},
+							&ast.Word{Text: "c"},
+						},
+						Right: &ast.Delim{Macro: `\right`, Text: "."},
+					},
+				}},
+			},
+		},
+		{
+			input: `$\left\{ x \left[y\right] \right\}$`,
+			want: ast.List{
+				&ast.MathExpr{List: ast.List{
+					&ast.Delimited{
+						Left: &ast.Delim{Macro: `\left`, Text: `\{`},
+						Body: ast.List{
+							&ast.Word{Text: "x"},
+							&ast.Delimited{
+								Left:  &ast.Delim{Macro: `\left`, Text: "["},
+								Body:  ast.List{&ast.Word{Text: "y"}},
+								Right: &ast.Delim{Macro: `\right`, Text: "]"},
+							},
+						},
+						Right: &ast.Delim{Macro: `\right`, Text: `\}`},
+					},
+				}},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := sprint(node), sprint(tc.want); got != want {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+
+	node, err := ParseExpr(`$\left( x \middle/ y \right)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delim := node.(ast.List)[0].(*ast.MathExpr).List[0].(*ast.Delimited)
+	if got, want := len(delim.Middle()), 1; got != want {
+		t.Fatalf("invalid number of middle delimiters: got=%d, want=%d", got, want)
+	}
+	if got, want := delim.Middle()[0], delim.Body[1]; got != want {
+		t.Fatalf("middle delimiter not in body")
+	}
+	if got, want := [2]token.Pos{delim.Pos(), delim.End()}, [2]token.Pos{1, 28}; got != want {
+		t.Fatalf("invalid positions: got=%v, want=%v", got, want)
+	}
+}
+
+func TestParseDelimitedErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `$\left( x$`,
+			want:  `1:2: missing \right for \left(`,
+		},
+		{
+			input: `$\left( x \right) \right)$`,
+			want:  `1:19: \right without matching \left`,
+		},
+		{
+			input: `$x \middle/ y$`,
+			want:  `1:4: \middle without matching \left`,
+		},
+		{
+			input: `$\left x \right)$`,
+			want:  `1:8: invalid delimiter "x" for \left`,
+		},
+		{
+			input: `$\left(\right$`,
+			want:  `1:14: invalid delimiter "$" for \right`,
+		},
+		{
+			input: `\[\left( x\] y`,
+			want:  `1:3: missing \right for \left(`,
+		},
+		{
+			input: `\(\left[ x\) y`,
+			want:  `1:3: missing \right for \left[`,
+		},
+		{
+			input: `$$\left\{ x$$ y`,
+			want:  `1:3: missing \right for \left\{`,
+		},
+		{
+			input: `${\left( x} \right)$`,
+			want:  `1:3: missing \right for \left( (and 1 more errors)`,
+		},
+		{
+			input: `\left( x \right)`,
+			want:  `1:1: \left not allowed in text mode (and 1 more errors)`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseMath(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `$$x$$`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim:   "$$",
+					Display: true,
+					List:    ast.List{&ast.Word{Text: "x"}},
+				},
+			},
+		},
+		{
+			input: `$a$$b$`,
+			want: ast.List{
+				&ast.MathExpr{Delim: "$", List: ast.List{&ast.Word{Text: "a"}}},
+				&ast.MathExpr{Delim: "$", List: ast.List{&ast.Word{Text: "b"}}},
+			},
+		},
+		{
+			input: `\begin{math}x\end{math}`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim: `\begin{math}`,
+					List:  ast.List{&ast.Word{Text: "x"}},
+				},
+			},
+		},
+		{
+			input: `\begin{equation*}x\end{equation*}`,
+			want: ast.List{
+				&ast.MathExpr{
+					Delim:   `\begin{equation*}`,
+					Display: true,
+					List:    ast.List{&ast.Word{Text: "x"}},
+				},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := sprint(node), sprint(tc.want); got != want {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseMathErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `$$x$ y`,
+			want:  `1:4: display math should end with "$$"`,
+		},
+		{
+			input: `x\) y`,
+			want:  `1:2: unexpected "\\)"`,
+		},
+		{
+			input: `\[x`,
+			want:  `1:1: missing closing "\\]"`,
+		},
+		{
+			input: `\(a\] b`,
+			want:  `1:4: "\\]" does not match "\\("`,
+		},
+		{
+			input: `\[ x $ y \]`,
+			want:  `1:6: "$" not allowed in math mode`,
+		},
+		{
+			input: `$a \(b\) c$`,
+			want:  `1:4: "\\(" not allowed in math mode (and 1 more errors)`,
+		},
+		{
+			input: `\begin{equation}\begin{equation}x\end{equation}\end{equation}`,
+			want:  `1:17: environment "equation" not allowed in math mode`,
+		},
+		{
+			input: `$\begin{align}x\end{align}$`,
+			want:  `1:2: environment "align" not allowed in math mode`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `$\begin{pmatrix} a & b \\ c & d \end{pmatrix}$`,
+			want: ast.List{
+				&ast.MathExpr{List: ast.List{
+					&ast.Table{
+						Env: &ast.Env{Name: &ast.Ident{Name: "pmatrix"}},
+						Rows: [][]ast.Cell{
+							{
+								{List: ast.List{&ast.Word{Text: "a"}}},
+								{List: ast.List{&ast.Word{Text: "b"}}},
+							},
+							{
+								{List: ast.List{&ast.Word{Text: "c"}}},
+								{List: ast.List{&ast.Word{Text: "d"}}},
+							},
+						},
+					},
+				}},
+			},
+		},
+		{
+			input: `$\begin{array}{cc}1&\\&x\\\end{array}$`,
+			want: ast.List{
+				&ast.MathExpr{List: ast.List{
+					&ast.Table{
+						Env: &ast.Env{
+							Name: &ast.Ident{Name: "array"},
+							Args: ast.List{&ast.Arg{List: ast.List{&ast.Word{Text: "cc"}}}},
+						},
+						Rows: [][]ast.Cell{
+							{
+								{List: ast.List{&ast.Literal{Text: "1"}}},
+								{},
+							},
+							{
+								{},
+								{List: ast.List{&ast.Word{Text: "x"}}},
+							},
+						},
+					},
+				}},
+			},
+		},
+		{
+			input: `\begin{tabular}{l}\hline a\\[2pt]\hline\end{tabular}`,
+			want: ast.List{
+				&ast.Table{
+					Env: &ast.Env{
+						Name: &ast.Ident{Name: "tabular"},
+						Args: ast.List{&ast.Arg{List: ast.List{&ast.Word{Text: "l"}}}},
+					},
+					Rows: [][]ast.Cell{
+						{{
+							Rules: ast.List{&ast.Macro{Name: &ast.Ident{Name: `\hline`}}},
+							List: ast.List{
+								&ast.Symbol{Text: " "},
+								&ast.Word{Text: "a"},
+							},
+							Space: &ast.OptArg{List: ast.List{
+								&ast.Dimen{Value: "2", Unit: "pt"},
+							}},
+						}},
+						{{
+							Rules: ast.List{&ast.Macro{Name: &ast.Ident{Name: `\hline`}}},
+						}},
+					},
+				},
+			},
+		},
+		{
+			input: "\\begin{tabular}{l}\n  \\hline\n  a \\\\\n  \\hline\n\\end{tabular}",
+			want: ast.List{
+				&ast.Table{
+					Env: &ast.Env{
+						Name: &ast.Ident{Name: "tabular"},
+						Args: ast.List{&ast.Arg{List: ast.List{&ast.Word{Text: "l"}}}},
+					},
+					Rows: [][]ast.Cell{
+						{{
+							Rules: ast.List{
+								&ast.Symbol{Text: "\n  "},
+								&ast.Macro{Name: &ast.Ident{Name: `\hline`}},
+							},
+							List: ast.List{
+								&ast.Symbol{Text: "\n  "},
+								&ast.Word{Text: "a"},
+								&ast.Symbol{Text: " "},
+							},
+						}},
+						{{
+							Rules: ast.List{
+								&ast.Symbol{Text: "\n  "},
+								&ast.Macro{Name: &ast.Ident{Name: `\hline`}},
+							},
+							List: ast.List{&ast.Symbol{Text: "\n"}},
+						}},
+					},
+				},
+			},
+		},
+		{
+			input: "\\begin{tabular}{ll}\n  a & b \\\\\n  c & d \\\\\n\\end{tabular}",
+			want: ast.List{
+				&ast.Table{
+					Env: &ast.Env{
+						Name: &ast.Ident{Name: "tabular"},
+						Args: ast.List{&ast.Arg{List: ast.List{&ast.Word{Text: "ll"}}}},
+					},
+					Rows: [][]ast.Cell{
+						{
+							{List: ast.List{&ast.Symbol{Text: "\n  "}, &ast.Word{Text: "a"}, &ast.Symbol{Text: " "}}},
+							{List: ast.List{&ast.Symbol{Text: " "}, &ast.Word{Text: "b"}, &ast.Symbol{Text: " "}}},
+						},
+						{
+							{List: ast.List{&ast.Symbol{Text: "\n  "}, &ast.Word{Text: "c"}, &ast.Symbol{Text: " "}}},
+							{List: ast.List{&ast.Symbol{Text: " "}, &ast.Word{Text: "d"}, &ast.Symbol{Text: " "}}},
+						},
+					},
+				},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := sprint(node), sprint(tc.want); got != want {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+
+	node, err := ParseExpr(`\begin{tabular}{lr} a & b \\ c \end{tabular}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := node.(ast.List)[0].(*ast.Table)
+	if got, want := sprint(tbl.ColSpec), `{ast.Word{"lr"}}`; got != want {
+		t.Fatalf("invalid column specification: got=%s, want=%s", got, want)
+	}
+	for _, tc := range []struct {
+		row, col int
+		sep      string
+		pos      token.Pos
+	}{
+		{0, 0, "&", 22},
+		{0, 1, `\\`, 26},
+		{1, 0, "", 31},
+	} {
+		cell := tbl.Rows[tc.row][tc.col]
+		if cell.Sep != tc.sep || cell.SepPos != tc.pos {
+			t.Fatalf("invalid separator for cell (%d,%d): got=(%q, %d), want=(%q, %d)",
+				tc.row, tc.col, cell.Sep, cell.SepPos, tc.sep, tc.pos,
+			)
+		}
+	}
+}
+
+func TestParseTableErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `\begin{tabular}{c} a & b`,
+			want:  `1:1: missing \end{tabular}`,
+		},
+		{
+			input: `a & b`,
+			want:  `1:3: misplaced alignment tab character &`,
+		},
+		{
+			input: `$\begin{cases} a & b \end{matrix}$`,
+			want:  `1:27: \end{matrix} does not match \begin{cases}`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseSpecialChars(t *testing.T) {
+	sym := func(text string) ast.Node { return &ast.Symbol{Text: text} }
+	word := func(text string) ast.Node { return &ast.Word{Text: text} }
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `a~b`,
+			want:  ast.List{word("a"), sym("~"), word("b")},
+		},
+		{
+			input: `$a~b\ c d$`,
+			want: ast.List{
+				&ast.MathExpr{List: ast.List{
+					word("a"), sym("~"), word("b"), sym(`\ `), word("c"), word("d"),
+				}},
+			},
+		},
+		{
+			input: `50\% \&\$\#\_\{\}`,
+			want: ast.List{
+				&ast.Literal{Text: "50"}, sym(`\%`), sym(" "),
+				sym(`\&`), sym(`\$`), sym(`\#`), sym(`\_`), sym(`\{`), sym(`\}`),
+			},
+		},
+		{
+			input: "``a'' -- --- ---- - -",
+			want: ast.List{
+				sym("``"), word("a"), sym("''"), sym(" "),
+				sym("--"), sym(" "),
+				sym("---"), sym(" "),
+				sym("---"), sym("-"), sym(" "),
+				sym("-"), sym(" "), sym("-"),
+			},
+		},
+		{
+			input: `$a--b''$`,
+			want: ast.List{
+				&ast.MathExpr{List: ast.List{
+					word("a"), sym("-"), sym("-"), word("b"),
+					&ast.Sup{Node: ast.List{sym("'"), sym("'")}},
+				}},
+			},
+		},
+		{
+			input: `x|y@z"`,
+			want:  ast.List{word("x"), sym("|"), word("y"), sym("@"), word("z"), sym(`"`)},
+		},
+		{
+			input: "café — naïve…\u00a0«x»",
+			want: ast.List{
+				word("café"), sym(" "), sym("—"), sym(" "), word("naïve"), sym("…"),
+				sym("\u00a0"), sym("«"), word("x"), sym("»"),
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := sprint(node), sprint(tc.want); got != want {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+}
+
+func TestParsePrimes(t *testing.T) {
+	sym := func(text string) ast.Node { return &ast.Symbol{Text: text} }
+	word := func(text string) ast.Node { return &ast.Word{Text: text} }
+	prime := sym("'")
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `$f'(x)$`,
+			want: ast.List{&ast.MathExpr{List: ast.List{
+				word("f"), &ast.Sup{Node: ast.List{prime}}, sym("("), word("x"), sym(")"),
+			}}},
+		},
+		{
+			input: `$f'''$`,
+			want: ast.List{&ast.MathExpr{List: ast.List{
+				word("f"), &ast.Sup{Node: ast.List{prime, prime, prime}},
+			}}},
+		},
+		{
+			input: `$f'^2$`,
+			want: ast.List{&ast.MathExpr{List: ast.List{
+				word("f"), &ast.Sup{Node: ast.List{prime, &ast.Literal{Text: "2"}}},
+			}}},
+		},
+		{
+			input: `$f''^{a b}$`,
+			want: ast.List{&ast.MathExpr{List: ast.List{
+				word("f"), &ast.Sup{Node: ast.List{prime, prime, word("a"), word("b")}},
+			}}},
+		},
+		{
+			input: `$x_i' '$`,
+			want: ast.List{&ast.MathExpr{List: ast.List{
+				word("x"),
+				&ast.Sub{Node: word("i")},
+				&ast.Sup{Node: ast.List{prime}},
+				&ast.Sup{Node: ast.List{prime}},
+			}}},
+		},
+		{
+			input: `it's`,
+			want:  ast.List{word("it"), sym("'"), word("s")},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := sprint(node), sprint(tc.want); got != want {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+
+	node, err := ParseExpr(`$f''^2$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sup := node.(ast.List)[0].(*ast.MathExpr).List[1].(*ast.Sup)
+	if got, want := sup.Pos(), token.Pos(2); got != want {
+		t.Fatalf("invalid position: got=%d, want=%d", got, want)
+	}
+	if got, want := sup.End(), token.Pos(6); got != want {
+		t.Fatalf("invalid end position: got=%d, want=%d", got, want)
+	}
 }
@@ -0,0 +1,41 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rewrite_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/rewrite"
+)
+
+func ExampleParseRule() {
+	p := latex.NewParser()
+	p.RegisterMacro(`\dv`, "AA")
+
+	rule, err := rewrite.ParseRule(p, `\frac{d a}{d b} -> \dv{a}{b}`, "a", "b")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	node, err := p.ParseExpr(`$\frac{d f}{d x}+\frac{d}{d t}\frac{d g}{d t}$`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	node, n := rule.Rewrite(node, false)
+	err = ast.Fprint(os.Stdout, node)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("\n%d rewritten\n", n)
+
+	// Output:
+	// $\dv{f}{x}+\frac{d}{d t}\dv{g}{t}$
+	// 2 rewritten
+}
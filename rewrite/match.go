@@ -0,0 +1,371 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rewrite
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+// matcher matches patterns against nodes, recording the nodes matched by
+// metavariables.
+type matcher struct {
+	vars     map[string]bool
+	bindings []binding
+}
+
+type binding struct {
+	name string
+	list ast.List
+}
+
+func (m *matcher) lookup(name string) (ast.List, bool) {
+	for _, b := range m.bindings {
+		if b.name == name {
+			return b.list, true
+		}
+	}
+	return nil, false
+}
+
+// isVar returns the name of the metavariable node, if node is one.
+func (m *matcher) isVar(node ast.Node) (string, bool) {
+	w, ok := node.(*ast.Word)
+	if !ok || !m.vars[w.Text] {
+		return "", false
+	}
+	return w.Text, true
+}
+
+// matchList matches the atoms of the pattern pat against the atoms of
+// list, starting at index i.
+// If prefix is true, pat may match only a part of the remaining atoms.
+// matchList returns the index of the first atom of list following the
+// match.
+//
+// A metavariable matches a single atom when it is followed by a sub- or
+// superscript, which applies to the preceding atom, or when the end of the
+// match is not constrained (prefix is true). Otherwise, it matches a
+// non-empty sequence of atoms.
+// Space symbols are ignored.
+func (m *matcher) matchList(pat, list ast.List, i int, prefix bool) (int, bool) {
+	for len(pat) > 0 && isSpace(pat[0]) {
+		pat = pat[1:]
+	}
+	if len(pat) == 0 {
+		if prefix {
+			return i, true
+		}
+		for _, node := range list[i:] {
+			if !isSpace(node) {
+				return 0, false
+			}
+		}
+		return len(list), true
+	}
+
+	for i < len(list) && isSpace(list[i]) {
+		i++
+	}
+
+	mark := len(m.bindings)
+	if name, ok := m.isVar(pat[0]); ok {
+		if bound, ok := m.lookup(name); ok {
+			j, ok := m.equal(bound, list, i)
+			if !ok {
+				return 0, false
+			}
+			return m.matchList(pat[1:], list, j, prefix)
+		}
+		if i == len(list) || isScript(list[i:i+1]) {
+			// scripts apply to the preceding atom.
+			return 0, false
+		}
+		last := len(list)
+		if prefix || isScript(pat[1:]) {
+			last = i + 1
+		}
+		for j := i + 1; j <= last; j++ {
+			if isSpace(list[j-1]) {
+				continue
+			}
+			m.bindings = append(m.bindings, binding{name: name, list: list[i:j]})
+			if end, ok := m.matchList(pat[1:], list, j, prefix); ok {
+				return end, true
+			}
+			m.bindings = m.bindings[:mark]
+		}
+		return 0, false
+	}
+
+	if i == len(list) || !m.match(pat[0], list[i]) {
+		m.bindings = m.bindings[:mark]
+		return 0, false
+	}
+	end, ok := m.matchList(pat[1:], list, i+1, prefix)
+	if !ok {
+		m.bindings = m.bindings[:mark]
+	}
+	return end, ok
+}
+
+// equal matches the nodes bound to a metavariable against list, starting
+// at index i.
+func (m *matcher) equal(bound, list ast.List, i int) (int, bool) {
+	vars := m.vars
+	m.vars = nil
+	defer func() { m.vars = vars }()
+	return m.matchList(bound, list, i, true)
+}
+
+// matchLists reports whether the pattern pat matches all the nodes of list.
+func (m *matcher) matchLists(pat, list ast.List) bool {
+	_, ok := m.matchList(atoms(pat), atoms(list), 0, false)
+	return ok
+}
+
+// match reports whether the pattern pat matches node.
+func (m *matcher) match(pat, node ast.Node) bool {
+	switch p := pat.(type) {
+	case nil:
+		return node == nil
+
+	case ast.List:
+		n, ok := node.(ast.List)
+		return ok && m.matchLists(p, n)
+
+	case *ast.Macro:
+		n, ok := node.(*ast.Macro)
+		if !ok || p.Name.Name != n.Name.Name || len(p.Args) != len(n.Args) {
+			return false
+		}
+		for i := range p.Args {
+			if !m.match(p.Args[i], n.Args[i]) {
+				return false
+			}
+		}
+		return true
+
+	case *ast.MacroDef:
+		n, ok := node.(*ast.MacroDef)
+		return ok && p.Cmd.Name == n.Cmd.Name && p.Name.Name == n.Name.Name &&
+			p.NArgs == n.NArgs && p.Optional == n.Optional &&
+			p.Default == n.Default && p.Body == n.Body
+
+	case *ast.Arg:
+		n, ok := node.(*ast.Arg)
+		return ok && m.matchLists(p.List, n.List)
+
+	case *ast.OptArg:
+		n, ok := node.(*ast.OptArg)
+		return ok && m.matchLists(p.List, n.List)
+
+	case *ast.Ident:
+		n, ok := node.(*ast.Ident)
+		return ok && p.Name == n.Name
+
+	case *ast.Env:
+		n, ok := node.(*ast.Env)
+		if !ok || p.Name.Name != n.Name.Name || len(p.Args) != len(n.Args) {
+			return false
+		}
+		for i := range p.Args {
+			if !m.match(p.Args[i], n.Args[i]) {
+				return false
+			}
+		}
+		return m.matchLists(p.Body, n.Body)
+
+	case *ast.Table:
+		n, ok := node.(*ast.Table)
+		if !ok || !m.match(p.Env, n.Env) || len(p.Rows) != len(n.Rows) {
+			return false
+		}
+		for i := range p.Rows {
+			if len(p.Rows[i]) != len(n.Rows[i]) {
+				return false
+			}
+			for j, pc := range p.Rows[i] {
+				nc := n.Rows[i][j]
+				if pc.Sep != nc.Sep ||
+					!m.matchLists(pc.Rules, nc.Rules) ||
+					!m.matchLists(pc.List, nc.List) ||
+					(pc.Space == nil) != (nc.Space == nil) ||
+					(pc.Space != nil && !m.match(pc.Space, nc.Space)) {
+					return false
+				}
+			}
+		}
+		return true
+
+	case *ast.Delimited:
+		n, ok := node.(*ast.Delimited)
+		return ok && m.match(p.Left, n.Left) && m.match(p.Right, n.Right) &&
+			m.matchLists(p.Body, n.Body)
+
+	case *ast.Delim:
+		n, ok := node.(*ast.Delim)
+		if !ok || p == nil || n == nil {
+			return ok && p == n
+		}
+		return p.Macro == n.Macro && p.Text == n.Text
+
+	case *ast.Group:
+		n, ok := node.(*ast.Group)
+		return ok && m.matchLists(p.List, n.List)
+
+	case *ast.MathExpr:
+		n, ok := node.(*ast.MathExpr)
+		return ok && p.Delim == n.Delim && m.matchLists(p.List, n.List)
+
+	case *ast.Paragraph:
+		n, ok := node.(*ast.Paragraph)
+		return ok && m.matchLists(p.List, n.List)
+
+	case *ast.Word:
+		n, ok := node.(*ast.Word)
+		return ok && p.Text == n.Text
+
+	case *ast.Literal:
+		n, ok := node.(*ast.Literal)
+		return ok && p.Text == n.Text
+
+	case *ast.Dimen:
+		n, ok := node.(*ast.Dimen)
+		return ok && matchDimen(p, n)
+
+	case *ast.KeyVal:
+		n, ok := node.(*ast.KeyVal)
+		if !ok || len(p.Pairs) != len(n.Pairs) {
+			return false
+		}
+		for i, pp := range p.Pairs {
+			np := n.Pairs[i]
+			if pp.Key != np.Key || (pp.Value == nil) != (np.Value == nil) ||
+				!m.matchLists(pp.Value, np.Value) {
+				return false
+			}
+		}
+		return true
+
+	case *ast.Symbol:
+		n, ok := node.(*ast.Symbol)
+		return ok && p.Text == n.Text
+
+	case *ast.Comment:
+		n, ok := node.(*ast.Comment)
+		return ok && p.Text == n.Text
+
+	case *ast.Verbatim:
+		n, ok := node.(*ast.Verbatim)
+		return ok && p.Text == n.Text
+
+	case *ast.Sub:
+		n, ok := node.(*ast.Sub)
+		return ok && m.matchLists(script(p.Node), script(n.Node))
+
+	case *ast.Sup:
+		n, ok := node.(*ast.Sup)
+		return ok && m.matchLists(script(p.Node), script(n.Node))
+	}
+
+	// *ast.Bad nodes never match.
+	return false
+}
+
+func matchDimen(p, n *ast.Dimen) bool {
+	switch {
+	case p == nil || n == nil:
+		return p == n
+	case p.Value != n.Value || p.Unit != n.Unit:
+		return false
+	}
+	return matchDimen(p.Plus, n.Plus) && matchDimen(p.Minus, n.Minus)
+}
+
+// script returns the nodes of a sub- or superscript, so x^2 and x^{2}
+// are matched alike.
+func script(node ast.Node) ast.List {
+	if list, ok := node.(ast.List); ok {
+		return list
+	}
+	return ast.List{node}
+}
+
+// isScript reports whether the first node of list, ignoring spaces, is a
+// sub- or superscript.
+func isScript(list ast.List) bool {
+	for _, node := range list {
+		switch node.(type) {
+		case *ast.Sub, *ast.Sup:
+			return true
+		}
+		if !isSpace(node) {
+			return false
+		}
+	}
+	return false
+}
+
+// isSpace reports whether node is a space symbol.
+func isSpace(node ast.Node) bool {
+	sym, ok := node.(*ast.Symbol)
+	return ok && strings.TrimSpace(sym.Text) == ""
+}
+
+// atoms returns the nodes of list, with its words split into single-letter
+// words, as they are typeset in math mode.
+func atoms(list ast.List) ast.List {
+	o, _ := split(list)
+	return o
+}
+
+// split returns the atoms of list, and the node of list each atom comes
+// from.
+func split(list ast.List) (atoms, origins ast.List) {
+	for _, node := range list {
+		w, ok := node.(*ast.Word)
+		if !ok || utf8.RuneCountInString(w.Text) < 2 {
+			atoms = append(atoms, node)
+			origins = append(origins, node)
+			continue
+		}
+		for i, r := range w.Text {
+			atoms = append(atoms, &ast.Word{WordPos: w.WordPos + token.Pos(i), Text: string(r)})
+			origins = append(origins, node)
+		}
+	}
+	return atoms, origins
+}
+
+// join appends the atoms to list, merging back the letters split from
+// the same word.
+func join(list, atoms, origins ast.List) ast.List {
+	for i := 0; i < len(atoms); {
+		if atoms[i] == origins[i] {
+			list = append(list, atoms[i])
+			i++
+			continue
+		}
+		var (
+			text = new(strings.Builder)
+			j    = i
+		)
+		for ; j < len(atoms) && origins[j] == origins[i]; j++ {
+			text.WriteString(atoms[j].(*ast.Word).Text)
+		}
+		switch orig := origins[i].(*ast.Word); {
+		case text.String() == orig.Text:
+			list = append(list, orig)
+		default:
+			list = append(list, &ast.Word{WordPos: atoms[i].Pos(), Text: text.String()})
+		}
+		i = j
+	}
+	return list
+}
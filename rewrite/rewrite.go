@@ -0,0 +1,548 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rewrite implements structural search and rewrite of LaTeX math
+// formulas, in the style of gofmt -r.
+//
+// A pattern is a math formula, whose metavariables are single-letter
+// names. A metavariable matches any node, or any sequence of nodes when
+// it is enclosed in braces, e.g. in \frac{a}{b} or x^{a}.
+// Other nodes match structurally identical nodes, ignoring spaces, the
+// grouping of letters into words and the braces enclosing a single
+// sub- or superscript, e.g. x^2 matches x^{2}.
+//
+// Example:
+//
+//	p := latex.NewParser()
+//	p.RegisterMacro(`\dv`, "AA")
+//	rule, err := rewrite.ParseRule(p, `\frac{d a}{d b} -> \dv{a}{b}`, "a", "b")
+//	...
+//	node, n := rule.Rewrite(node, false)
+package rewrite // import "github.com/go-latex/latex/rewrite"
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/ast/astutil"
+	"github.com/go-latex/latex/token"
+)
+
+// Pattern is a math formula with metavariables.
+type Pattern struct {
+	list ast.List
+	vars map[string]bool
+}
+
+// NewPattern returns a pattern matching node, with the metavariables vars.
+func NewPattern(node ast.Node, vars ...string) (*Pattern, error) {
+	pat := &Pattern{
+		list: atoms(script(node)),
+		vars: make(map[string]bool, len(vars)),
+	}
+	for _, name := range vars {
+		if utf8.RuneCountInString(name) != 1 || !unicode.IsLetter([]rune(name)[0]) {
+			return nil, fmt.Errorf("rewrite: invalid metavariable %q", name)
+		}
+		pat.vars[name] = true
+	}
+	for _, node := range pat.list {
+		if !isSpace(node) {
+			return pat, nil
+		}
+	}
+	return nil, fmt.Errorf("rewrite: empty pattern")
+}
+
+// ParsePattern parses the math formula pattern, with the metavariables
+// vars.
+// If p is nil, a parser with the builtin macros and environments is used.
+func ParsePattern(p *latex.Parser, pattern string, vars ...string) (*Pattern, error) {
+	node, err := parseMath(p, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return NewPattern(node, vars...)
+}
+
+// Match is an occurrence of a pattern.
+type Match struct {
+	Nodes ast.List            // matched nodes
+	Vars  map[string]ast.List // nodes matched by each metavariable
+}
+
+// Pos returns the position of the first matched node.
+func (m *Match) Pos() token.Pos { return m.Nodes.Pos() }
+
+// End returns the position of the first character immediately after the
+// matched nodes.
+func (m *Match) End() token.Pos { return m.Nodes.End() }
+
+// Find returns the non-overlapping occurrences of the pattern in the math
+// expressions of root.
+// If math is true, root is in math mode, e.g. when it was parsed in math
+// mode.
+func (pat *Pattern) Find(root ast.Node, math bool) []*Match {
+	var o []*Match
+	apply(root, math, func(list ast.List) ast.List {
+		atoms := atoms(list)
+		pat.scan(atoms, func(m *matcher, beg, end int) {
+			match := &Match{
+				Nodes: atoms[beg:end:end],
+				Vars:  make(map[string]ast.List, len(m.bindings)),
+			}
+			for _, b := range m.bindings {
+				match.Vars[b.name] = b.list
+			}
+			o = append(o, match)
+		})
+		return list
+	})
+	return o
+}
+
+// scan calls f for each non-overlapping match of the pattern in atoms,
+// with the range of matched atoms.
+func (pat *Pattern) scan(atoms ast.List, f func(m *matcher, beg, end int)) {
+	m := matcher{vars: pat.vars}
+	for i := 0; i < len(atoms); {
+		if isSpace(atoms[i]) {
+			i++
+			continue
+		}
+		m.bindings = m.bindings[:0]
+		end, ok := m.matchList(pat.list, atoms, i, true)
+		if !ok {
+			i++
+			continue
+		}
+		f(&m, i, end)
+		i = end
+	}
+}
+
+// Rule is a rewrite rule, replacing the occurrences of a pattern.
+type Rule struct {
+	Pattern     *Pattern
+	Replacement ast.List
+}
+
+// NewRule returns a rule rewriting the occurrences of pattern into
+// replacement, with the metavariables vars.
+// The metavariables of replacement must appear in pattern.
+func NewRule(pattern, replacement ast.Node, vars ...string) (*Rule, error) {
+	pat, err := NewPattern(pattern, vars...)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &Rule{Pattern: pat, Replacement: script(replacement)}
+	used := make(map[string]bool)
+	ast.Inspect(pattern, func(node ast.Node) bool {
+		if w, ok := node.(*ast.Word); ok {
+			for _, r := range w.Text {
+				used[string(r)] = true
+			}
+		}
+		return true
+	})
+	for _, name := range vars {
+		if !used[name] {
+			return nil, fmt.Errorf("rewrite: metavariable %q not used in pattern", name)
+		}
+	}
+	return rule, nil
+}
+
+// ParseRule parses the rewrite rule "pattern -> replacement", whose sides
+// are math formulas, with the metavariables vars.
+// If p is nil, a parser with the builtin macros and environments is used.
+func ParseRule(p *latex.Parser, rule string, vars ...string) (*Rule, error) {
+	sides := strings.Split(rule, "->")
+	if len(sides) != 2 {
+		return nil, fmt.Errorf("rewrite: rule must be of the form 'pattern -> replacement'")
+	}
+	pattern, err := parseMath(p, sides[0])
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := parseMath(p, sides[1])
+	if err != nil {
+		return nil, err
+	}
+	return NewRule(pattern, replacement, vars...)
+}
+
+// Rewrite rewrites, in place, the non-overlapping occurrences of the rule
+// pattern in the math expressions of root, and returns the rewritten root
+// with the number of rewritten occurrences.
+// If math is true, root is in math mode, e.g. when it was parsed in math
+// mode.
+//
+// The children of a node are rewritten before the node itself, and
+// replacement nodes are not rewritten again.
+// The positions of the replacement nodes, other than the nodes matched by
+// metavariables, are the positions of the rule.
+func (r *Rule) Rewrite(root ast.Node, math bool) (ast.Node, int) {
+	n := 0
+	root = apply(root, math, func(list ast.List) ast.List {
+		var (
+			o    ast.List
+			last = 0
+			k    = 0
+		)
+		atoms, origins := split(list)
+		r.Pattern.scan(atoms, func(m *matcher, beg, end int) {
+			o = join(o, atoms[last:beg], origins[last:beg])
+			o = append(o, r.subst(m, r.Replacement)...)
+			last = end
+			k++
+		})
+		if k == 0 {
+			return list
+		}
+		n += k
+		return join(o, atoms[last:], origins[last:])
+	})
+	return root, n
+}
+
+// Replace returns the nodes replacing the match m of the rule pattern,
+// e.g. a match returned by the Find method of the pattern.
+// The occurrences of the pattern within the nodes matched by the
+// metavariables are rewritten as well, as done by Rewrite.
+func (r *Rule) Replace(m *Match) ast.List {
+	bindings := &matcher{vars: r.Pattern.vars}
+	for name, list := range m.Vars {
+		list, _ := r.Rewrite(r.subst(&matcher{}, list), true)
+		bindings.bindings = append(bindings.bindings, binding{name: name, list: list.(ast.List)})
+	}
+	return r.subst(bindings, r.Replacement)
+}
+
+// apply calls f for each list of nodes in math mode of root, after its
+// children have been processed, and replaces the list with the result of
+// f.
+func apply(root ast.Node, math bool, f func(ast.List) ast.List) ast.Node {
+	depth := 0
+	if math {
+		depth++
+	}
+	pre := func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.MathExpr:
+			depth++
+		case *ast.Macro:
+			// calls of user-defined macros are printed as written: their
+			// expansion is left alone.
+			return n.Expansion == nil
+		}
+		return true
+	}
+	post := func(c *astutil.Cursor) bool {
+		if n, ok := c.Node().(*ast.MathExpr); ok {
+			depth--
+			n.List = f(n.List)
+			return true
+		}
+		if depth == 0 {
+			return true
+		}
+		switch n := c.Node().(type) {
+		case ast.List:
+			c.Replace(f(n))
+		case *ast.Arg:
+			n.List = f(n.List)
+		case *ast.OptArg:
+			n.List = f(n.List)
+		case *ast.Group:
+			n.List = f(n.List)
+		case *ast.Paragraph:
+			n.List = f(n.List)
+		case *ast.Delimited:
+			n.Body = f(n.Body)
+		case *ast.Env:
+			n.Body = f(n.Body)
+		case *ast.Table:
+			for i := range n.Rows {
+				for j := range n.Rows[i] {
+					n.Rows[i][j].List = f(n.Rows[i][j].List)
+				}
+			}
+		case *ast.Sub:
+			n.Node = applyScript(n.Node, f)
+		case *ast.Sup:
+			n.Node = applyScript(n.Node, f)
+		}
+		return true
+	}
+	return astutil.Apply(root, pre, post)
+}
+
+// applyScript applies f to a script made of a single node.
+// Scripts made of a list of nodes are handled as any other list.
+func applyScript(node ast.Node, f func(ast.List) ast.List) ast.Node {
+	if _, ok := node.(ast.List); ok {
+		return node
+	}
+	switch list := f(ast.List{node}); len(list) {
+	case 1:
+		return list[0]
+	default:
+		return list
+	}
+}
+
+// subst returns a copy of the replacement list, with its metavariables
+// replaced with copies of the nodes they matched.
+func (r *Rule) subst(m *matcher, list ast.List) ast.List {
+	if list == nil {
+		return nil
+	}
+	var (
+		o              = make(ast.List, 0, len(list))
+		atoms, origins = split(list)
+		beg            = 0
+	)
+	flush := func(end int) {
+		for _, node := range join(nil, atoms[beg:end], origins[beg:end]) {
+			o = append(o, r.substNode(m, node))
+		}
+	}
+	for i, node := range atoms {
+		name, ok := m.isVar(node)
+		if !ok {
+			continue
+		}
+		flush(i)
+		bound, _ := m.lookup(name)
+		o = append(o, mergeWords(r.subst(&matcher{}, bound))...)
+		beg = i + 1
+	}
+	flush(len(atoms))
+	return o
+}
+
+// mergeWords merges the adjacent words of list, e.g. the letters of a
+// word split by a match.
+func mergeWords(list ast.List) ast.List {
+	o := list[:0]
+	for _, node := range list {
+		if w, ok := node.(*ast.Word); ok && len(o) > 0 {
+			if prev, ok := o[len(o)-1].(*ast.Word); ok && prev.End() == w.WordPos {
+				prev.Text += w.Text
+				continue
+			}
+		}
+		o = append(o, node)
+	}
+	return o
+}
+
+func (r *Rule) substNode(m *matcher, node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case nil:
+		return nil
+
+	case ast.List:
+		return r.subst(m, n)
+
+	case *ast.Macro:
+		o := *n
+		o.Name = r.substIdent(n.Name)
+		o.Args = r.substNodes(m, n.Args)
+		return &o
+
+	case *ast.MacroDef:
+		o := *n
+		o.Cmd = r.substIdent(n.Cmd)
+		o.Name = r.substIdent(n.Name)
+		return &o
+
+	case *ast.Arg:
+		o := *n
+		o.List = r.subst(m, n.List)
+		return &o
+
+	case *ast.OptArg:
+		o := *n
+		o.List = r.subst(m, n.List)
+		return &o
+
+	case *ast.Ident:
+		return r.substIdent(n)
+
+	case *ast.Env:
+		o := *n
+		o.Name = r.substIdent(n.Name)
+		o.Args = r.substNodes(m, n.Args)
+		o.Body = r.subst(m, n.Body)
+		return &o
+
+	case *ast.Table:
+		o := *n
+		o.Env = r.substNode(m, n.Env).(*ast.Env)
+		o.ColSpec = nil
+		if n.ColSpec != nil {
+			for i, arg := range n.Env.Args {
+				if arg == ast.Node(n.ColSpec) {
+					o.ColSpec = o.Env.Args[i].(*ast.Arg)
+				}
+			}
+		}
+		o.Rows = make([][]ast.Cell, len(n.Rows))
+		for i, row := range n.Rows {
+			o.Rows[i] = make([]ast.Cell, len(row))
+			for j, cell := range row {
+				cell.Rules = r.subst(m, cell.Rules)
+				cell.List = r.subst(m, cell.List)
+				if cell.Space != nil {
+					cell.Space = r.substNode(m, cell.Space).(*ast.OptArg)
+				}
+				o.Rows[i][j] = cell
+			}
+		}
+		return &o
+
+	case *ast.Delimited:
+		o := *n
+		o.Body = r.subst(m, n.Body)
+		if n.Left != nil {
+			o.Left = r.substNode(m, n.Left).(*ast.Delim)
+		}
+		if n.Right != nil {
+			o.Right = r.substNode(m, n.Right).(*ast.Delim)
+		}
+		return &o
+
+	case *ast.Delim:
+		o := *n
+		return &o
+
+	case *ast.Group:
+		o := *n
+		o.List = r.subst(m, n.List)
+		return &o
+
+	case *ast.MathExpr:
+		o := *n
+		o.List = r.subst(m, n.List)
+		return &o
+
+	case *ast.Paragraph:
+		o := *n
+		o.List = r.subst(m, n.List)
+		return &o
+
+	case *ast.Word:
+		o := *n
+		return &o
+
+	case *ast.Literal:
+		o := *n
+		return &o
+
+	case *ast.Dimen:
+		o := *n
+		if n.Plus != nil {
+			o.Plus = r.substNode(m, n.Plus).(*ast.Dimen)
+		}
+		if n.Minus != nil {
+			o.Minus = r.substNode(m, n.Minus).(*ast.Dimen)
+		}
+		return &o
+
+	case *ast.KeyVal:
+		o := &ast.KeyVal{Pairs: make([]*ast.Pair, len(n.Pairs))}
+		for i, pair := range n.Pairs {
+			p := *pair
+			p.Value = r.subst(m, pair.Value)
+			o.Pairs[i] = &p
+		}
+		return o
+
+	case *ast.Symbol:
+		o := *n
+		return &o
+
+	case *ast.Comment:
+		o := *n
+		return &o
+
+	case *ast.Verbatim:
+		o := *n
+		return &o
+
+	case *ast.Sub:
+		o := *n
+		o.Node = r.substScript(m, n.Node)
+		return &o
+
+	case *ast.Sup:
+		o := *n
+		o.Node = r.substScript(m, n.Node)
+		return &o
+
+	case *ast.Bad:
+		o := *n
+		return &o
+
+	default:
+		panic(fmt.Errorf("rewrite: unknown ast node %#v (type=%T)", n, n))
+	}
+}
+
+// substNodes returns a copy of list, without substituting metavariables
+// at its top level, e.g. for the arguments of a macro.
+func (r *Rule) substNodes(m *matcher, list ast.List) ast.List {
+	if list == nil {
+		return nil
+	}
+	o := make(ast.List, len(list))
+	for i, node := range list {
+		o[i] = r.substNode(m, node)
+	}
+	return o
+}
+
+func (r *Rule) substIdent(id *ast.Ident) *ast.Ident {
+	if id == nil {
+		return nil
+	}
+	o := *id
+	return &o
+}
+
+// substScript returns a copy of a sub- or superscript, with its
+// metavariables replaced.
+func (r *Rule) substScript(m *matcher, node ast.Node) ast.Node {
+	if list, ok := node.(ast.List); ok {
+		return r.subst(m, list)
+	}
+	switch list := r.subst(m, ast.List{node}); len(list) {
+	case 1:
+		return list[0]
+	default:
+		return list
+	}
+}
+
+// parseMath parses the math formula expr.
+func parseMath(p *latex.Parser, expr string) (ast.Node, error) {
+	if p == nil {
+		p = latex.NewParser()
+	}
+	q := *p
+	q.Mode = latex.MathMode
+	expr = strings.TrimSpace(expr)
+	node, err := q.ParseExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("rewrite: could not parse %q: %w", expr, err)
+	}
+	return node, nil
+}
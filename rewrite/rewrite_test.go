@@ -0,0 +1,253 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rewrite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-latex/latex"
+	"github.com/go-latex/latex/ast"
+)
+
+func newParser() *latex.Parser {
+	p := latex.NewParser()
+	p.RegisterMacro(`\dv`, "AA")
+	p.RegisterMacro(`\over`, "")
+	return p
+}
+
+func TestRewrite(t *testing.T) {
+	for _, tc := range []struct {
+		rule  string
+		vars  []string
+		input string
+		want  string
+		n     int
+	}{
+		{
+			rule:  `\frac{d a}{d b} -> \dv{a}{b}`,
+			vars:  []string{"a", "b"},
+			input: `$\frac{dx}{dt} + \frac{d f}{d t}$ and $\frac{d(x+y)}{dt}$`,
+			want:  `$\dv{x}{t}+\dv{f}{t}$ and $\dv{(x+y)}{t}$`,
+			n:     3,
+		},
+		{
+			rule:  `\frac{a}{b} -> {a \over b}`,
+			vars:  []string{"a", "b"},
+			input: `$\frac{\frac{1}{2}}{x+1}$`,
+			want:  `${{1\over2}\over x+1}$`,
+			n:     2,
+		},
+		{
+			rule:  `a^2 -> a \cdot a`,
+			vars:  []string{"a"},
+			input: `$x+y^{2}=\sqrt{z^2}$`,
+			want:  `$x+y\cdot y=\sqrt{z\cdot z}$`,
+			n:     2,
+		},
+		{
+			// a metavariable bound twice must match the same nodes.
+			rule:  `a+a -> 2a`,
+			vars:  []string{"a"},
+			input: `$x+x+y+z$ and $\alpha+\alpha$`,
+			want:  `$2x+y+z$ and $2\alpha$`,
+			n:     2,
+		},
+		{
+			rule:  `\frac{d a}{d b} -> \dv{a}{b}`,
+			vars:  []string{"a", "b"},
+			input: `$\frac{dxy}{dt}$`,
+			want:  `$\dv{xy}{t}$`,
+			n:     1,
+		},
+		{
+			// words are matched letter by letter.
+			rule:  `ab -> ba`,
+			input: `$abc+cab$ and ab`,
+			want:  `$ba c+c ba$ and ab`,
+			n:     2,
+		},
+		{
+			rule:  `\left(a\right) -> (a)`,
+			vars:  []string{"a"},
+			input: `\[\left( x_1 + \left(y\right)\right)\]`,
+			want:  `\[(x_1+(y))\]`,
+			n:     2,
+		},
+		{
+			rule:  `\sin^2 a -> (\sin a)^2`,
+			vars:  []string{"a"},
+			input: `$\sin^{2} x + \cos^2 x$`,
+			want:  `$(\sin x)^2+\cos^2x$`,
+			n:     1,
+		},
+		{
+			rule:  `e^{a} -> E(a)`,
+			vars:  []string{"a"},
+			input: `$e^{i\pi}+e^x+e_1$`,
+			want:  `$E(i\pi)+E(x)+e_1$`,
+			n:     2,
+		},
+	} {
+		t.Run(tc.rule, func(t *testing.T) {
+			p := newParser()
+			rule, err := ParseRule(p, tc.rule, tc.vars...)
+			if err != nil {
+				t.Fatalf("could not parse rule: %+v", err)
+			}
+			node, err := p.ParseExpr(tc.input)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.input, err)
+			}
+			node, n := rule.Rewrite(node, false)
+			if n != tc.n {
+				t.Fatalf("invalid number of rewrites: got=%d, want=%d", n, tc.n)
+			}
+			o := new(strings.Builder)
+			err = ast.Fprint(o, node)
+			if err != nil {
+				t.Fatalf("could not print ast: %+v", err)
+			}
+			if got, want := o.String(), tc.want; got != want {
+				t.Fatalf("invalid rewrite:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRewriteMath(t *testing.T) {
+	p := newParser()
+	p.Mode = latex.MathMode
+	rule, err := ParseRule(p, `a^2 -> a^3`, "a")
+	if err != nil {
+		t.Fatalf("could not parse rule: %+v", err)
+	}
+	node, err := p.ParseExpr(`x^2+y^2`)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	if _, n := rule.Rewrite(node, false); n != 0 {
+		t.Fatalf("invalid number of rewrites in text mode: got=%d, want=0", n)
+	}
+
+	node, n := rule.Rewrite(node, true)
+	if n != 2 {
+		t.Fatalf("invalid number of rewrites: got=%d, want=2", n)
+	}
+	o := new(strings.Builder)
+	err = (&ast.Printer{Math: true}).Fprint(o, node)
+	if err != nil {
+		t.Fatalf("could not print ast: %+v", err)
+	}
+	if got, want := o.String(), `x^3+y^3`; got != want {
+		t.Fatalf("invalid rewrite:\ngot= %s\nwant=%s", got, want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	const src = `$\frac{dx}{dt}=v$ and $\frac{d^2x}{dt^2} = \frac{dv}{dt^2}$`
+	p := newParser()
+	pat, err := ParsePattern(p, `\frac{d a}{d b}`, "a", "b")
+	if err != nil {
+		t.Fatalf("could not parse pattern: %+v", err)
+	}
+	node, err := p.ParseExpr(src)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	var got []string
+	for _, m := range pat.Find(node, false) {
+		got = append(got, src[m.Pos():m.End()]+" a="+sprint(m.Vars["a"])+" b="+sprint(m.Vars["b"]))
+	}
+	want := []string{
+		`\frac{dx}{dt} a=ast.List{ast.Word{"x"}} b=ast.List{ast.Word{"t"}}`,
+		`\frac{dv}{dt^2} a=ast.List{ast.Word{"v"}} b=ast.List{ast.Word{"t"}, ast.Sup{ast.Lit{"2"}}}`,
+	}
+	if got, want := strings.Join(got, "\n"), strings.Join(want, "\n"); got != want {
+		t.Fatalf("invalid matches:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	const src = `$\frac{d\frac{df}{dx}}{dt}+\frac{dy}{dt}$`
+	p := newParser()
+	rule, err := ParseRule(p, `\frac{d a}{d b} -> \dv{a}{b}`, "a", "b")
+	if err != nil {
+		t.Fatalf("could not parse rule: %+v", err)
+	}
+	node, err := p.ParseExpr(src)
+	if err != nil {
+		t.Fatalf("could not parse expression: %+v", err)
+	}
+
+	var got []string
+	for _, m := range rule.Pattern.Find(node, false) {
+		o := new(strings.Builder)
+		err := (&ast.Printer{Math: true}).Fprint(o, rule.Replace(m))
+		if err != nil {
+			t.Fatalf("could not print replacement: %+v", err)
+		}
+		got = append(got, src[m.Pos():m.End()]+" -> "+o.String())
+	}
+	want := []string{
+		`\frac{df}{dx} -> \dv{f}{x}`,
+		`\frac{d\frac{df}{dx}}{dt} -> \dv{\dv{f}{x}}{t}`,
+		`\frac{dy}{dt} -> \dv{y}{t}`,
+	}
+	if got, want := strings.Join(got, "\n"), strings.Join(want, "\n"); got != want {
+		t.Fatalf("invalid replacements:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	for _, tc := range []struct {
+		rule string
+		vars []string
+		want string
+	}{
+		{
+			rule: `a+b`,
+			want: `rewrite: rule must be of the form 'pattern -> replacement'`,
+		},
+		{
+			rule: ` -> x`,
+			want: `rewrite: empty pattern`,
+		},
+		{
+			rule: `\frac{a}{b} -> \dv{a}{b}`,
+			vars: []string{"a", "b"},
+			want: "rewrite: could not parse \"\\\\dv{a}{b}\": 1:1: unknown macro \"\\\\dv\"",
+		},
+		{
+			rule: `a -> b`,
+			vars: []string{"a", "b"},
+			want: `rewrite: metavariable "b" not used in pattern`,
+		},
+		{
+			rule: `x -> y`,
+			vars: []string{"xy"},
+			want: `rewrite: invalid metavariable "xy"`,
+		},
+	} {
+		t.Run(tc.rule, func(t *testing.T) {
+			_, err := ParseRule(nil, tc.rule, tc.vars...)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func sprint(node ast.Node) string {
+	o := new(strings.Builder)
+	ast.Print(o, node)
+	return o.String()
+}
@@ -15,26 +15,69 @@ import (
 )
 
 type texScanner struct {
-	sc scanner.Scanner
+	sc   scanner.Scanner
+	base int                             // position of the first byte of the source
+	err  func(pos token.Pos, msg string) // error reporting; or nil
 
-	r   rune
-	tok token.Token
+	cat   Catcodes // category codes of the scanned characters
+	r     rune
+	tok   token.Token
+	depth int       // macro expansion depth of tok
+	queue []pending // tokens to be returned before scanning further
+
+	comment bool // whether the last scanned token is a comment
+
+	logging int       // number of active logs of the consumed tokens
+	log     []pending // tokens consumed while logging
+}
+
+// pending is a token pushed back to the scanner.
+type pending struct {
+	tok   token.Token
+	depth int // macro expansion depth of tok
 }
 
 func newScanner(r io.Reader) *texScanner {
 	sc := &texScanner{}
 	sc.sc.Init(r)
-	sc.sc.Mode = (scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats)
-	sc.sc.Mode |= scanner.ScanStrings
+	// numbers are scanned by scanNumber: LaTeX numbers are decimal and may
+	// be followed by a unit (e.g. 2pt or 1em), which text/scanner would
+	// mistake for an exponent.
+	sc.sc.Mode = scanner.ScanIdents
 	//scanner.ScanRawStrings)
-	//	sc.sc.Error = func(s *scanner.Scanner, msg string) {}
+	sc.sc.Error = func(s *scanner.Scanner, msg string) {
+		pos := s.Position
+		if !pos.IsValid() {
+			pos = s.Pos()
+		}
+		sc.error(token.Pos(sc.base+pos.Offset), msg)
+	}
 	sc.sc.IsIdentRune = func(ch rune, i int) bool {
-		return unicode.IsLetter(ch) //|| unicode.IsDigit(ch) && i > 0
+		return sc.cat.Of(ch) == Letter
 	}
-	sc.sc.Whitespace = 1<<'\t' | 1<<'\n' | 1<<'\r'
+	sc.setCatcodes(DefaultCatcodes())
 	return sc
 }
 
+// setCatcodes sets the category codes of the scanned characters.
+// cat is used as is and may be modified by setCatcode.
+func (s *texScanner) setCatcodes(cat Catcodes) {
+	s.cat = cat
+	// let text/scanner skip the ignored characters it can.
+	s.sc.Whitespace = 0
+	for r, c := range cat {
+		if c == Ignored && 0 <= r && r < 64 {
+			s.sc.Whitespace |= 1 << uint(r)
+		}
+	}
+}
+
+// setCatcode sets the category code of r.
+func (s *texScanner) setCatcode(r rune, cat Catcode) {
+	s.cat[r] = cat
+	s.setCatcodes(s.cat)
+}
+
 // Token returns the most recently parsed token
 func (s *texScanner) Token() token.Token {
 	return s.tok
@@ -44,11 +87,57 @@ func (s *texScanner) Token() token.Token {
 // Next retrieves the most recent token with Token().
 // It returns false once it reaches token.EOF.
 func (s *texScanner) Next() bool {
-	s.tok = s.scan()
+	switch {
+	case len(s.queue) > 0:
+		s.tok = s.queue[0].tok
+		s.depth = s.queue[0].depth
+		s.queue = s.queue[1:]
+	default:
+		s.tok = s.scan()
+		s.depth = 0
+	}
+	if s.logging > 0 && s.tok.Kind != token.EOF {
+		s.log = append(s.log, pending{tok: s.tok, depth: s.depth})
+	}
 	return s.tok.Kind != token.EOF
 }
 
+// peek returns the next token without consuming it.
+func (s *texScanner) peek() token.Token {
+	if len(s.queue) == 0 {
+		s.queue = append(s.queue, pending{tok: s.scan()})
+	}
+	return s.queue[0].tok
+}
+
+// unread pushes back the most recent token so it is returned again
+// by the next call to Next.
+func (s *texScanner) unread() {
+	if s.logging > 0 && len(s.log) > 0 {
+		s.log = s.log[:len(s.log)-1]
+	}
+	s.push([]token.Token{s.tok}, s.depth)
+}
+
+// push pushes back toks, at the given macro expansion depth, so they are
+// returned by the next calls to Next, before scanning further.
+func (s *texScanner) push(toks []token.Token, depth int) {
+	queue := make([]pending, 0, len(toks)+len(s.queue))
+	for _, tok := range toks {
+		queue = append(queue, pending{tok: tok, depth: depth})
+	}
+	s.queue = append(queue, s.queue...)
+}
+
+// requeue pushes back the pending tokens toks, at their own macro
+// expansion depths.
+func (s *texScanner) requeue(toks []pending) {
+	s.queue = append(append([]pending(nil), toks...), s.queue...)
+}
+
 func (s *texScanner) scan() token.Token {
+	comment := s.comment
+	s.comment = false
 	s.next()
 	pos := s.pos()
 	switch s.r {
@@ -58,97 +147,208 @@ func (s *texScanner) scan() token.Token {
 			Pos:  pos,
 			Text: s.sc.TokenText(),
 		}
-	case '\\':
+	case scanner.EOF:
+		return token.Token{
+			Kind: token.EOF,
+			Pos:  pos,
+		}
+	}
+
+	text := s.sc.TokenText()
+	switch s.cat.Of(s.r) {
+	case Escape:
 		nxt := s.sc.Peek()
-		switch nxt {
-		case ' ':
-			s.next()
+		switch {
+		case s.cat.Of(nxt) == Spacer, s.cat.Of(nxt) == EndOfLine:
+			// control space.
+			s.sc.Next()
 			return token.Token{
 				Kind: token.Space,
 				Pos:  pos,
-				Text: `\ `,
+				Text: text + string(nxt),
+			}
+		case s.escaped(nxt):
+			// escaped special characters stand for themselves.
+			s.sc.Next()
+			return token.Token{
+				Kind: token.Symbol,
+				Pos:  pos,
+				Text: text + string(nxt),
 			}
 		default:
 			return s.scanMacro()
 		}
-	case ' ':
+
+	case BeginGroup:
+		return token.Token{
+			Kind: token.Lbrace,
+			Pos:  pos,
+			Text: text,
+		}
+
+	case EndGroup:
+		return token.Token{
+			Kind: token.Rbrace,
+			Pos:  pos,
+			Text: text,
+		}
+
+	case MathShift:
+		if s.sc.Peek() == s.r {
+			text += string(s.sc.Next())
+		}
+		return token.Token{
+			Kind: token.Symbol,
+			Pos:  pos,
+			Text: text,
+		}
+
+	case AlignTab, Superscript, Subscript:
+		return token.Token{
+			Kind: token.Symbol,
+			Pos:  pos,
+			Text: text,
+		}
+
+	case EndOfLine:
+		return s.scanNewline(pos, comment)
+
+	case Parameter:
+		if nxt := s.sc.Peek(); '1' <= nxt && nxt <= '9' {
+			text += string(s.sc.Next())
+		}
+		return token.Token{
+			Kind: token.Param,
+			Pos:  pos,
+			Text: text,
+		}
+
+	case Ignored:
+		return s.scan()
+
+	case Spacer:
 		return token.Token{
 			Kind: token.Space,
 			Pos:  pos,
-			Text: ` `,
+			Text: text,
 		}
 
-	case '%':
-		line := s.scanComment()
+	case Active:
+		kind := token.Symbol
+		if s.r == '~' {
+			// non-breaking space.
+			kind = token.Space
+		}
+		return token.Token{
+			Kind: kind,
+			Pos:  pos,
+			Text: text,
+		}
+
+	case CommentChar:
+		s.comment = true
 		return token.Token{
 			Kind: token.Comment,
 			Pos:  pos,
-			Text: line,
+			Text: s.scanComment(text),
 		}
 
-	case '$', '_', '=', '<', '>', '^', '/', '*', '-', '+',
-		'!', '?', '\'', ':', ',', ';', '.':
+	case Invalid:
+		s.error(pos, fmt.Sprintf("invalid character %s", scanner.TokenString(s.r)))
 		return token.Token{
-			Kind: token.Symbol,
+			Kind: token.Invalid,
 			Pos:  pos,
-			Text: s.sc.TokenText(),
+			Text: text,
 		}
 
-	case '[':
+	case Letter:
+		// a letter not recognized by text/scanner, e.g. a letter made of
+		// multiple runes.
 		return token.Token{
-			Kind: token.Lbrack,
+			Kind: token.Word,
 			Pos:  pos,
-			Text: s.sc.TokenText(),
+			Text: text,
+		}
+	}
+
+	switch s.r {
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return s.scanNumber(pos)
+
+	case '.':
+		if isDigit(s.sc.Peek()) {
+			return s.scanNumber(pos)
 		}
-	case ']':
 		return token.Token{
-			Kind: token.Rbrack,
+			Kind: token.Symbol,
 			Pos:  pos,
-			Text: s.sc.TokenText(),
+			Text: text,
 		}
-	case '{':
+
+	case '[':
 		return token.Token{
-			Kind: token.Lbrace,
+			Kind: token.Lbrack,
 			Pos:  pos,
-			Text: s.sc.TokenText(),
+			Text: text,
 		}
-	case '}':
+	case ']':
 		return token.Token{
-			Kind: token.Rbrace,
+			Kind: token.Rbrack,
 			Pos:  pos,
-			Text: s.sc.TokenText(),
+			Text: text,
 		}
 	case '(':
 		return token.Token{
 			Kind: token.Lparen,
 			Pos:  pos,
-			Text: s.sc.TokenText(),
+			Text: text,
 		}
 	case ')':
 		return token.Token{
 			Kind: token.Rparen,
 			Pos:  pos,
-			Text: s.sc.TokenText(),
+			Text: text,
 		}
-	case scanner.Int, scanner.Float:
+	}
+
+	switch {
+	case unicode.IsSpace(s.r):
+		// e.g. U+00A0 NO-BREAK SPACE.
 		return token.Token{
-			Kind: token.Number,
+			Kind: token.Space,
 			Pos:  pos,
-			Text: s.sc.TokenText(),
+			Text: text,
 		}
-	case scanner.String, scanner.Char:
+	case unicode.IsGraphic(s.r):
+		// e.g. punctuation, operators or non-ASCII symbols.
 		return token.Token{
-			Kind: token.Other,
+			Kind: token.Symbol,
 			Pos:  pos,
-			Text: s.sc.TokenText(),
+			Text: text,
 		}
-	case scanner.EOF:
+	default:
+		s.error(pos, fmt.Sprintf("unhandled token %s", scanner.TokenString(s.r)))
 		return token.Token{
-			Kind: token.EOF,
+			Kind: token.Invalid,
 			Pos:  pos,
+			Text: text,
 		}
-	default:
-		panic(fmt.Errorf("unhandled token: %v %v", scanner.TokenString(s.r), s.r))
+	}
+}
+
+// escaped reports whether the escaped character r stands for itself,
+// e.g. \& or \%.
+func (s *texScanner) escaped(r rune) bool {
+	switch s.cat.Of(r) {
+	case BeginGroup, EndGroup, MathShift, AlignTab, Parameter, Subscript, CommentChar:
+		return true
+	}
+	return false
+}
+
+func (s *texScanner) error(pos token.Pos, msg string) {
+	if s.err != nil {
+		s.err(pos, msg)
 	}
 }
 
@@ -161,36 +361,203 @@ func (s *texScanner) scanMacro() token.Token {
 		macro = new(strings.Builder)
 		pos   = s.pos()
 	)
-	s.next()
-	macro.WriteString(`\` + s.sc.TokenText())
+	macro.WriteString(`\`)
+	switch c := s.sc.Peek(); {
+	case s.cat.Of(c) == Letter:
+		// control word.
+		s.next()
+		macro.WriteString(s.sc.TokenText())
+	case c != scanner.EOF:
+		// control symbol, made of exactly one character.
+		s.r = s.sc.Next()
+		macro.WriteRune(s.r)
+	}
+	if macro.String() == `\verb` && s.sc.Peek() == '*' {
+		macro.WriteRune(s.sc.Next())
+	}
+
+	kind := token.Macro
+	if macro.String() == `\par` {
+		kind = token.EmptyLine
+	}
 
 	return token.Token{
-		Kind: token.Macro,
+		Kind: kind,
 		Pos:  pos,
 		Text: macro.String(),
 	}
 }
 
-func (s *texScanner) scanComment() string {
+// scanNumber scans a decimal number, e.g. 42, 4.2 or .42, whose first
+// character has already been read.
+func (s *texScanner) scanNumber(pos token.Pos) token.Token {
+	var (
+		text = new(strings.Builder)
+		dot  = s.r == '.'
+	)
+	text.WriteRune(s.r)
+	for {
+		c := s.sc.Peek()
+		switch {
+		case isDigit(c):
+			// ok.
+		case c == '.' && !dot:
+			dot = true
+		default:
+			return token.Token{
+				Kind: token.Number,
+				Pos:  pos,
+				Text: text.String(),
+			}
+		}
+		text.WriteRune(s.sc.Next())
+	}
+}
+
+func isDigit(c rune) bool {
+	return '0' <= c && c <= '9'
+}
+
+// peekRune returns the first character of the next token, without
+// consuming it.
+// Unlike peek, peekRune does not scan the next token when no token has
+// been pushed back, so the following characters may still be scanned
+// verbatim.
+func (s *texScanner) peekRune() rune {
+	if len(s.queue) > 0 {
+		for _, r := range s.queue[0].tok.Text {
+			return r
+		}
+		return scanner.EOF
+	}
+	return s.sc.Peek()
+}
+
+// scanVerbatim scans a verbatim argument, delimited by its first
+// character (as in \verb|...|) or enclosed in braces (as in \url{...}).
+// scanVerbatim reports whether the closing delimiter was found.
+func (s *texScanner) scanVerbatim() (token.Token, bool) {
+	var (
+		pos   = s.rawPos()
+		text  = new(strings.Builder)
+		delim = s.sc.Next()
+	)
+	switch delim {
+	case scanner.EOF, '\n':
+		s.error(pos, "missing verbatim delimiter")
+		return token.Token{Kind: token.Verbatim, Pos: pos}, false
+	}
+	text.WriteRune(delim)
+
+	var (
+		right = delim
+		depth = 0
+	)
+	if delim == '{' {
+		right = '}'
+	}
+	for {
+		c := s.sc.Next()
+		switch {
+		case c == scanner.EOF, c == '\n' && delim != '{':
+			s.error(pos, fmt.Sprintf("missing closing %q for verbatim text", right))
+			return token.Token{Kind: token.Verbatim, Pos: pos, Text: text.String()}, false
+		}
+		text.WriteRune(c)
+		switch {
+		case c == right && depth == 0:
+			return token.Token{Kind: token.Verbatim, Pos: pos, Text: text.String()}, true
+		case c == right:
+			depth--
+		case c == delim:
+			depth++
+		}
+	}
+}
+
+// scanVerbatimEnv scans the body of a verbatim environment, up to and
+// including the \end{name} command.
+// scanVerbatimEnv returns the body, the position of \end and whether
+// \end{name} was found.
+func (s *texScanner) scanVerbatimEnv(name string) (token.Token, token.Pos, bool) {
+	var (
+		pos  = s.rawPos()
+		text = new(strings.Builder)
+		end  = `\end{` + name + `}`
+	)
+	for {
+		if body := text.String(); strings.HasSuffix(body, end) {
+			body = body[:len(body)-len(end)]
+			tok := token.Token{Kind: token.Verbatim, Pos: pos, Text: body}
+			return tok, token.Pos(int(pos) + len(body)), true
+		}
+		c := s.sc.Next()
+		if c == scanner.EOF {
+			tok := token.Token{Kind: token.Verbatim, Pos: pos, Text: text.String()}
+			return tok, s.rawPos(), false
+		}
+		text.WriteRune(c)
+	}
+}
+
+// scanNewline scans the whitespace following the newline at pos.
+// A newline followed by a blank line (containing only whitespace) is a
+// paragraph break, returned as a token.EmptyLine.
+// Otherwise, the newline and the indentation of the next line, if any,
+// are returned as a single token.Space, as TeX turns the end of a line
+// into a space, unless the line ends with a comment or the source ends.
+func (s *texScanner) scanNewline(pos token.Pos, comment bool) token.Token {
+	var (
+		text  = new(strings.Builder)
+		space = new(strings.Builder)
+	)
+	text.WriteString("\n")
+	for {
+		switch c := s.sc.Peek(); c {
+		case ' ', '\t', '\r':
+			space.WriteRune(s.sc.Next())
+		case '\n':
+			text.WriteString(space.String())
+			text.WriteRune(s.sc.Next())
+			for strings.ContainsRune(" \t\r\n", s.sc.Peek()) {
+				text.WriteRune(s.sc.Next())
+			}
+			return token.Token{
+				Kind: token.EmptyLine,
+				Pos:  pos,
+				Text: text.String(),
+			}
+		default:
+			if comment || c == scanner.EOF {
+				return s.scan()
+			}
+			text.WriteString(space.String())
+			return token.Token{
+				Kind: token.Space,
+				Pos:  pos,
+				Text: text.String(),
+			}
+		}
+	}
+}
+
+// scanComment scans the comment started by the comment character c.
+func (s *texScanner) scanComment(c string) string {
 	comment := new(strings.Builder)
-	comment.WriteString("%")
-	wsp := s.sc.Whitespace
-	defer func() {
-		s.sc.Whitespace = wsp
-	}()
-	s.sc.Whitespace = 0
+	comment.WriteString(c)
 
+	// the newline ending the comment is left to the scanner, so blank
+	// lines following a comment are still recognized.
 	for {
-		s.next()
-		if s.r == '\r' {
+		switch s.sc.Peek() {
+		case '\n', scanner.EOF:
+			return comment.String()
+		case '\r':
+			s.sc.Next()
 			continue
 		}
-		if s.r == '\n' || s.r == scanner.EOF {
-			break
-		}
-		comment.WriteString(s.sc.TokenText())
+		comment.WriteRune(s.sc.Next())
 	}
-	return comment.String()
 }
 
 // func (s *texScanner) expect(want rune) {
@@ -201,5 +568,10 @@ func (s *texScanner) scanComment() string {
 // }
 
 func (s *texScanner) pos() token.Pos {
-	return token.Pos(s.sc.Position.Offset)
+	return token.Pos(s.base + s.sc.Position.Offset)
+}
+
+// rawPos returns the position of the next character to be read.
+func (s *texScanner) rawPos() token.Pos {
+	return token.Pos(s.base + s.sc.Pos().Offset)
 }
@@ -6,8 +6,11 @@ package latex
 
 import (
 	"log"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/go-latex/latex/token"
 )
 
 func TestScanner(t *testing.T) {
@@ -43,6 +46,22 @@ func TestScanner(t *testing.T) {
 			name:  "chars",
 			input: `x='cos'`,
 		},
+		{
+			name:  "paragraphs",
+			input: "hello\n  \nworld % boo\n\n\\par x",
+		},
+		{
+			name:  "dimensions",
+			input: `\\[2pt] \hspace{1em} .5ex 1.5`,
+		},
+		{
+			name:  "special",
+			input: "a~b \\& x|y@z \"q\" `` -- '' café — naïve…",
+		},
+		{
+			name:  "invalid",
+			input: "a \x01 b",
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			sc := newScanner(strings.NewReader(tc.input))
@@ -53,3 +72,118 @@ func TestScanner(t *testing.T) {
 		})
 	}
 }
+
+func TestScanNewline(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  []token.Token
+	}{
+		{
+			input: "hello\nworld",
+			want: []token.Token{
+				{Kind: token.Word, Pos: 0, Text: "hello"},
+				{Kind: token.Space, Pos: 5, Text: "\n"},
+				{Kind: token.Word, Pos: 6, Text: "world"},
+			},
+		},
+		{
+			input: "a\n  b",
+			want: []token.Token{
+				{Kind: token.Word, Pos: 0, Text: "a"},
+				{Kind: token.Space, Pos: 1, Text: "\n  "},
+				{Kind: token.Word, Pos: 4, Text: "b"},
+			},
+		},
+		{
+			input: "a\n\nb",
+			want: []token.Token{
+				{Kind: token.Word, Pos: 0, Text: "a"},
+				{Kind: token.EmptyLine, Pos: 1, Text: "\n\n"},
+				{Kind: token.Word, Pos: 3, Text: "b"},
+			},
+		},
+		{
+			input: "a\n \t\n\n b",
+			want: []token.Token{
+				{Kind: token.Word, Pos: 0, Text: "a"},
+				{Kind: token.EmptyLine, Pos: 1, Text: "\n \t\n\n "},
+				{Kind: token.Word, Pos: 7, Text: "b"},
+			},
+		},
+		{
+			input: "a%c\n  b\n",
+			want: []token.Token{
+				{Kind: token.Word, Pos: 0, Text: "a"},
+				{Kind: token.Comment, Pos: 1, Text: "%c"},
+				{Kind: token.Word, Pos: 6, Text: "b"},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			var (
+				sc  = newScanner(strings.NewReader(tc.input))
+				got []token.Token
+			)
+			for sc.Next() {
+				got = append(got, sc.Token())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("invalid tokens:\ngot= %#v\nwant=%#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScanControlSequences(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  []token.Token
+	}{
+		{
+			input: `\alpha1\,x`,
+			want: []token.Token{
+				{Kind: token.Macro, Pos: 0, Text: `\alpha`},
+				{Kind: token.Number, Pos: 6, Text: "1"},
+				{Kind: token.Macro, Pos: 7, Text: `\,`},
+				{Kind: token.Word, Pos: 9, Text: "x"},
+			},
+		},
+		{
+			input: "\\\tb",
+			want: []token.Token{
+				{Kind: token.Space, Pos: 0, Text: "\\\t"},
+				{Kind: token.Word, Pos: 2, Text: "b"},
+			},
+		},
+		{
+			input: "a\\ b\\\nc",
+			want: []token.Token{
+				{Kind: token.Word, Pos: 0, Text: "a"},
+				{Kind: token.Space, Pos: 1, Text: `\ `},
+				{Kind: token.Word, Pos: 3, Text: "b"},
+				{Kind: token.Space, Pos: 4, Text: "\\\n"},
+				{Kind: token.Word, Pos: 6, Text: "c"},
+			},
+		},
+		{
+			input: `a\`,
+			want: []token.Token{
+				{Kind: token.Word, Pos: 0, Text: "a"},
+				{Kind: token.Macro, Pos: 1, Text: `\`},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			var (
+				sc  = newScanner(strings.NewReader(tc.input))
+				got []token.Token
+			)
+			for sc.Next() {
+				got = append(got, sc.Token())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("invalid tokens:\ngot= %#v\nwant=%#v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strings"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+// rules is the set of commands drawing horizontal rules between table rows.
+var rules = map[string]bool{
+	`\hline`: true,
+	`\cline`: true,
+}
+
+// parseTable parses the body of the table environment env, up to and
+// including its \end{name} command.
+func (p *parser) parseTable(env *ast.Env, spec builtinEnv) ast.Node {
+	tbl := &ast.Table{Env: env}
+	if spec.colspec && len(env.Args) > 0 {
+		tbl.ColSpec, _ = env.Args[len(env.Args)-1].(*ast.Arg)
+	}
+
+	var (
+		row  []ast.Cell
+		cell ast.Cell
+		body paragraphs
+	)
+	endCell := func(tok token.Token) {
+		cell.List = body.list()
+		cell.Sep = tok.Text
+		cell.SepPos = tok.Pos
+		row = append(row, cell)
+		cell = ast.Cell{}
+		body = paragraphs{}
+	}
+
+	for p.s.Next() {
+		tok := p.s.tok
+		switch {
+		case tok.Kind == token.Macro && tok.Text == `\end`:
+			if len(row) > 0 || len(cell.Rules) > 0 || !isSpaces(body.cur) || len(body.pars) > 0 {
+				endCell(token.Token{Pos: tok.Pos})
+				tbl.Rows = append(tbl.Rows, row)
+			}
+			p.parseEnvEnd(env, tok)
+			return tbl
+		case tok.Kind == token.Symbol && tok.Text == "&":
+			endCell(tok)
+		case tok.Kind == token.Macro && tok.Text == `\\`:
+			var args ast.List
+			p.parseLengthArg(&args, true)
+			if len(args) > 0 {
+				cell.Space = args[0].(*ast.OptArg)
+			}
+			endCell(tok)
+			tbl.Rows = append(tbl.Rows, row)
+			row = nil
+		case tok.Kind == token.Macro && rules[tok.Text] && len(row) == 0 && isBlank(body.cur) && len(body.pars) == 0:
+			// the spaces and comments preceding the rule are kept with it.
+			cell.Rules = append(cell.Rules, body.cur...)
+			body.cur = nil
+			if node := p.parseNode(tok); node != nil {
+				cell.Rules = append(cell.Rules, node)
+			}
+		case p.parBreak(tok):
+			body.end()
+		default:
+			body.add(p.parseNode(tok))
+		}
+	}
+
+	end := p.s.tok.Pos
+	body.add(&ast.Bad{From: end, To: end})
+	endCell(token.Token{Pos: end})
+	tbl.Rows = append(tbl.Rows, row)
+	env.EndPos = end
+	p.errorf(env.BeginPos, `missing \end{%s}`, env.Name.Name)
+	return tbl
+}
+
+// isBlank reports whether list only holds spaces and comments.
+func isBlank(list ast.List) bool {
+	for _, node := range list {
+		if _, ok := node.(*ast.Comment); !ok && !isSpace(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSpaces reports whether list only holds spaces.
+func isSpaces(list ast.List) bool {
+	for _, node := range list {
+		if !isSpace(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSpace reports whether node is a space, possibly spanning lines.
+func isSpace(node ast.Node) bool {
+	sym, ok := node.(*ast.Symbol)
+	return ok && strings.TrimSpace(sym.Text) == ""
+}
@@ -0,0 +1,184 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package units converts lengths expressed in TeX units.
+package units // import "github.com/go-latex/latex/tex/units"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	PointsPerInch    = 72.27 // number of TeX points in an inch
+	BigPointsPerInch = 72    // number of PostScript points in an inch
+)
+
+// Unit is a TeX unit of length.
+type Unit uint8
+
+const (
+	Pt    Unit = iota // point, 1/72.27 inch
+	Bp                // big point, 1/72 inch
+	Mm                // millimeter
+	Cm                // centimeter
+	In                // inch
+	Pc                // pica, 12 points
+	Dd                // didot point, 1238/1157 points
+	Cc                // cicero, 12 didot points
+	Sp                // scaled point, 1/65536 point
+	Em                // width of a quad in the current font
+	Ex                // x-height of the current font
+	Mu                // math unit, 1/18 em
+	Fil               // infinite stretch or shrink, of order 1
+	Fill              // infinite stretch or shrink, of order 2
+	Filll             // infinite stretch or shrink, of order 3
+)
+
+var names = [...]string{
+	Pt:    "pt",
+	Bp:    "bp",
+	Mm:    "mm",
+	Cm:    "cm",
+	In:    "in",
+	Pc:    "pc",
+	Dd:    "dd",
+	Cc:    "cc",
+	Sp:    "sp",
+	Em:    "em",
+	Ex:    "ex",
+	Mu:    "mu",
+	Fil:   "fil",
+	Fill:  "fill",
+	Filll: "filll",
+}
+
+// points holds the size of the absolute units, in points.
+var points = [...]float64{
+	Pt: 1,
+	Bp: PointsPerInch / BigPointsPerInch,
+	Mm: PointsPerInch / 25.4,
+	Cm: PointsPerInch / 2.54,
+	In: PointsPerInch,
+	Pc: 12,
+	Dd: 1238.0 / 1157,
+	Cc: 12 * 1238.0 / 1157,
+	Sp: 1.0 / 65536,
+}
+
+// ParseUnit returns the unit named s, e.g. "pt" or "fil".
+// As in TeX, unit names are case-insensitive.
+func ParseUnit(s string) (Unit, error) {
+	s = strings.ToLower(s)
+	for u, name := range names {
+		if name == s {
+			return Unit(u), nil
+		}
+	}
+	return 0, fmt.Errorf("units: unknown unit %q", s)
+}
+
+func (u Unit) String() string {
+	if int(u) < len(names) {
+		return names[u]
+	}
+	return fmt.Sprintf("Unit(%d)", uint8(u))
+}
+
+// Relative reports whether u depends on the current font.
+func (u Unit) Relative() bool {
+	switch u {
+	case Em, Ex, Mu:
+		return true
+	}
+	return false
+}
+
+// Order returns the order of infinity of u: 0 for finite units, and 1, 2
+// or 3 for fil, fill and filll.
+func (u Unit) Order() int {
+	switch u {
+	case Fil, Fill, Filll:
+		return int(u-Fil) + 1
+	}
+	return 0
+}
+
+// Font holds the font lengths the relative units are defined by.
+type Font struct {
+	Em float64 // width of a quad, in points
+	Ex float64 // x-height, in points
+}
+
+// Length is a length, expressed in a TeX unit.
+type Length struct {
+	Value float64
+	Unit  Unit
+}
+
+// ParseLength parses a TeX length, e.g. "2.5em", "-3pt" or "1fil".
+// The number may be preceded by several signs, and separated from its
+// unit by spaces.
+func ParseLength(s string) (Length, error) {
+	var (
+		sign = 1.0
+		str  = strings.TrimSpace(s)
+	)
+	for len(str) > 0 && (str[0] == '+' || str[0] == '-' || str[0] == ' ') {
+		if str[0] == '-' {
+			sign = -sign
+		}
+		str = str[1:]
+	}
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || '9' < r) && r != '.'
+	})
+	if i < 0 {
+		return Length{}, fmt.Errorf("units: missing unit in length %q", s)
+	}
+	v, err := strconv.ParseFloat(str[:i], 64)
+	if err != nil {
+		return Length{}, fmt.Errorf("units: invalid number in length %q", s)
+	}
+	u, err := ParseUnit(strings.TrimSpace(str[i:]))
+	if err != nil {
+		return Length{}, err
+	}
+	return Length{Value: sign * v, Unit: u}, nil
+}
+
+func (l Length) String() string {
+	return strconv.FormatFloat(l.Value, 'g', -1, 64) + l.Unit.String()
+}
+
+// Points returns the length of l, in points.
+// The relative units are converted with the lengths of f.
+// Infinite lengths, e.g. 1fil, have no finite size: Points returns 0.
+func (l Length) Points(f Font) float64 {
+	switch l.Unit {
+	case Em:
+		return l.Value * f.Em
+	case Ex:
+		return l.Value * f.Ex
+	case Mu:
+		return l.Value * f.Em / 18
+	case Fil, Fill, Filll:
+		return 0
+	}
+	return l.Value * points[l.Unit]
+}
+
+// In returns the length of l, expressed in the unit u.
+// In panics if exactly one of the units of l and u is infinite, or if they
+// are infinite units of different orders.
+func (l Length) In(u Unit, f Font) float64 {
+	if l.Unit.Order() != 0 || u.Order() != 0 {
+		if l.Unit != u {
+			panic(fmt.Errorf("units: can not convert %v into %v", l.Unit, u))
+		}
+		return l.Value
+	}
+	return l.Points(f) / Length{Value: 1, Unit: u}.Points(f)
+}
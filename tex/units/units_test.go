@@ -0,0 +1,141 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseLength(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  Length
+		err   string
+	}{
+		{input: "2.5em", want: Length{2.5, Em}},
+		{input: "-3pt", want: Length{-3, Pt}},
+		{input: "--3 PT", want: Length{3, Pt}},
+		{input: "+.5in", want: Length{0.5, In}},
+		{input: "1fil", want: Length{1, Fil}},
+		{input: "2filll", want: Length{2, Filll}},
+		{input: "65536sp", want: Length{65536, Sp}},
+		{input: "3", err: `units: missing unit in length "3"`},
+		{input: "pt", err: `units: invalid number in length "pt"`},
+		{input: "1..2pt", err: `units: invalid number in length "1..2pt"`},
+		{input: "2px", err: `units: unknown unit "px"`},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseLength(tc.input)
+			switch {
+			case err != nil && tc.err == "":
+				t.Fatalf("could not parse length: %+v", err)
+			case err == nil && tc.err != "":
+				t.Fatalf("expected an error")
+			case err != nil:
+				if got, want := err.Error(), tc.err; got != want {
+					t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+				}
+				return
+			}
+			if got != tc.want {
+				t.Fatalf("invalid length: got=%v, want=%v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLengthPoints(t *testing.T) {
+	f := Font{Em: 10, Ex: 4.3}
+	for _, tc := range []struct {
+		l    Length
+		want float64
+	}{
+		{Length{2, Pt}, 2},
+		{Length{1, In}, 72.27},
+		{Length{72, Bp}, 72.27},
+		{Length{2.54, Cm}, 72.27},
+		{Length{25.4, Mm}, 72.27},
+		{Length{1, Pc}, 12},
+		{Length{1157, Dd}, 1238},
+		{Length{1, Cc}, 12 * 1238.0 / 1157},
+		{Length{65536, Sp}, 1},
+		{Length{1.5, Em}, 15},
+		{Length{2, Ex}, 8.6},
+		{Length{18, Mu}, 10},
+		{Length{-3, Mu}, -10.0 / 6},
+		{Length{1, Fil}, 0},
+	} {
+		t.Run(tc.l.String(), func(t *testing.T) {
+			if got, want := tc.l.Points(f), tc.want; math.Abs(got-want) > 1e-9 {
+				t.Fatalf("invalid length: got=%g, want=%g", got, want)
+			}
+		})
+	}
+}
+
+func TestLengthIn(t *testing.T) {
+	f := Font{Em: 10, Ex: 4.3}
+	for _, tc := range []struct {
+		l    Length
+		u    Unit
+		want float64
+	}{
+		{Length{1, In}, Cm, 2.54},
+		{Length{1, Em}, Mu, 18},
+		{Length{20, Pt}, Em, 2},
+		{Length{3, Fill}, Fill, 3},
+	} {
+		t.Run(tc.l.String(), func(t *testing.T) {
+			if got, want := tc.l.In(tc.u, f), tc.want; math.Abs(got-want) > 1e-9 {
+				t.Fatalf("invalid length: got=%g, want=%g", got, want)
+			}
+		})
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected a panic")
+			}
+		}()
+		Length{1, Fil}.In(Pt, f)
+	}()
+}
+
+func TestUnit(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		unit     Unit
+		relative bool
+		order    int
+	}{
+		{"pt", Pt, false, 0},
+		{"em", Em, true, 0},
+		{"mu", Mu, true, 0},
+		{"fil", Fil, false, 1},
+		{"fill", Fill, false, 2},
+		{"filll", Filll, false, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := ParseUnit(tc.name)
+			if err != nil {
+				t.Fatalf("could not parse unit: %+v", err)
+			}
+			if u != tc.unit {
+				t.Fatalf("invalid unit: got=%v, want=%v", u, tc.unit)
+			}
+			if got, want := u.String(), tc.name; got != want {
+				t.Fatalf("invalid name: got=%q, want=%q", got, want)
+			}
+			if got, want := u.Relative(), tc.relative; got != want {
+				t.Fatalf("invalid relative: got=%v, want=%v", got, want)
+			}
+			if got, want := u.Order(), tc.order; got != want {
+				t.Fatalf("invalid order: got=%d, want=%d", got, want)
+			}
+		})
+	}
+}
@@ -32,6 +32,7 @@ const (
 	Rparen
 	Other
 	Verbatim
+	Param // #1, #2, ...
 	EOF
 )
 
@@ -48,3 +49,24 @@ func (t Token) String() string { return t.Text }
 //
 // Aliased from go/token.Pos
 type Pos = token.Pos
+
+// Position describes an arbitrary source position including the file,
+// line and column location.
+//
+// Aliased from go/token.Position
+type Position = token.Position
+
+// File is a handle for a file belonging to a FileSet.
+//
+// Aliased from go/token.File
+type File = token.File
+
+// FileSet represents a set of source files.
+//
+// Aliased from go/token.FileSet
+type FileSet = token.FileSet
+
+// NewFileSet creates a new file set.
+func NewFileSet() *FileSet {
+	return token.NewFileSet()
+}
@@ -0,0 +1,224 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-latex/latex/internal/tex2unicode"
+	"github.com/go-latex/latex/token"
+)
+
+// superscripts maps the Unicode superscript characters to the characters
+// they raise.
+var superscripts = map[rune]rune{
+	'⁰': '0', '¹': '1', '²': '2', '³': '3', '⁴': '4',
+	'⁵': '5', '⁶': '6', '⁷': '7', '⁸': '8', '⁹': '9',
+	'⁺': '+', '⁻': '-', '⁼': '=', '⁽': '(', '⁾': ')',
+	'ⁱ': 'i', 'ⁿ': 'n',
+}
+
+// subscripts maps the Unicode subscript characters to the characters they
+// lower.
+var subscripts = map[rune]rune{
+	'₀': '0', '₁': '1', '₂': '2', '₃': '3', '₄': '4',
+	'₅': '5', '₆': '6', '₇': '7', '₈': '8', '₉': '9',
+	'₊': '+', '₋': '-', '₌': '=', '₍': '(', '₎': ')',
+	'ₐ': 'a', 'ₑ': 'e', 'ₒ': 'o', 'ₓ': 'x', 'ₕ': 'h',
+	'ₖ': 'k', 'ₗ': 'l', 'ₘ': 'm', 'ₙ': 'n', 'ₚ': 'p',
+	'ₛ': 's', 'ₜ': 't', 'ᵢ': 'i', 'ⱼ': 'j', 'ᵣ': 'r',
+	'ᵤ': 'u', 'ᵥ': 'v',
+}
+
+// texSpellings maps the Unicode math characters to their TeX spelling,
+// made of the macros known to the parser, e.g. 'α' to \alpha, 'ℝ' to
+// \mathbb{R} or '−' to -.
+// The Unicode primes are spelled as runs of ', so that f′ is parsed as f'.
+// Characters without such a spelling are left untranslated.
+var texSpellings = func() map[rune]string {
+	var (
+		known = builtinMacros()
+		fonts = []struct{ prefix, macro string }{
+			{"Bbb", `\mathbb`},
+			{"frak", `\mathfrak`},
+			{"scr", `\mathscr`},
+		}
+		db = map[rune]string{
+			'−': "-",   // U+2212 MINUS SIGN
+			'′': "'",   // U+2032 PRIME
+			'″': "''",  // U+2033 DOUBLE PRIME
+			'‴': "'''", // U+2034 TRIPLE PRIME
+		}
+	)
+	for _, name := range tex2unicode.Symbols() {
+		r := tex2unicode.Index(name, true)
+		if _, ok := tex2unicode.Name(r); !ok {
+			continue
+		}
+		spelling := ""
+		if _, ok := known[`\`+name]; ok {
+			spelling = `\` + name
+		}
+		for _, font := range fonts {
+			c := strings.TrimPrefix(name, font.prefix)
+			if len(c) == 1 && 'A' <= c[0] && c[0] <= 'Z' && c != name {
+				spelling = font.macro + "{" + c + "}"
+			}
+		}
+		if spelling == "" {
+			continue
+		}
+		if cur, dup := db[r]; dup && (len(cur) < len(spelling) || len(cur) == len(spelling) && cur < spelling) {
+			continue
+		}
+		db[r] = spelling
+	}
+	return db
+}()
+
+// unicodeMath translates the Unicode math characters of tok into their
+// TeX spelling, e.g. α into \alpha or x² into x^2, when the parser is
+// configured to do so.
+// The translated tokens are pushed back to the scanner.
+// unicodeMath reports whether tok was translated.
+func (p *parser) unicodeMath(tok token.Token) bool {
+	if !p.cfg.UnicodeMath || p.state != mathState {
+		return false
+	}
+	switch tok.Kind {
+	case token.Word, token.Symbol:
+	default:
+		return false
+	}
+
+	var (
+		toks []token.Token
+		beg  = 0 // start of the untranslated characters
+		text = tok.Text
+		ends = []int{len(text)}       // end of each token of text
+		kind = []token.Kind{tok.Kind} // kind of each token of text
+	)
+
+	// a run of super- or subscript characters may span several tokens,
+	// e.g. x²³ is scanned as x, ² and ³.
+	for isScript(lastRune(text)) {
+		next := p.s.peek()
+		if next.Pos != tok.Pos+token.Pos(len(text)) || !isScript(firstRune(next.Text)) {
+			break
+		}
+		p.s.Next()
+		text += next.Text
+		ends = append(ends, len(text))
+		kind = append(kind, next.Kind)
+	}
+
+	flush := func(end int) {
+		if beg >= end {
+			return
+		}
+		i := 0
+		for ends[i] <= beg {
+			i++
+		}
+		toks = append(toks, token.Token{
+			Kind: kind[i],
+			Pos:  tok.Pos + token.Pos(beg),
+			Text: text[beg:end],
+		})
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case superscripts[r] != 0:
+			flush(i)
+			i += scriptTokens(&toks, "^", superscripts, tok.Pos+token.Pos(i), text[i:])
+			beg = i
+		case subscripts[r] != 0:
+			flush(i)
+			i += scriptTokens(&toks, "_", subscripts, tok.Pos+token.Pos(i), text[i:])
+			beg = i
+		default:
+			spelling, ok := texSpellings[r]
+			if !ok {
+				i += size
+				continue
+			}
+			flush(i)
+			pos := tok.Pos + token.Pos(i)
+			sc := newScanner(strings.NewReader(spelling))
+			for sc.Next() {
+				tok := sc.Token()
+				tok.Pos = pos
+				toks = append(toks, tok)
+			}
+			i += size
+			beg = i
+		}
+	}
+	if toks == nil {
+		return false
+	}
+	flush(len(text))
+	p.s.push(toks, p.s.depth)
+	return true
+}
+
+func isPrime(r rune) bool {
+	return r == '′' || r == '″' || r == '‴'
+}
+
+func isScript(r rune) bool {
+	return superscripts[r] != 0 || subscripts[r] != 0
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+// scriptTokens appends to toks the TeX spelling of the leading run of Unicode
+// super- or subscript characters of text, located at pos: the script
+// command op, followed by the lowered or raised characters, enclosed in
+// braces when there are several of them.
+// scriptTokens returns the length of the run, in bytes.
+func scriptTokens(toks *[]token.Token, op string, chars map[rune]rune, pos token.Pos, text string) int {
+	var (
+		ascii = new(strings.Builder)
+		poss  []token.Pos // position of each character of ascii
+		n     = 0
+	)
+	for n < len(text) {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		c, ok := chars[r]
+		if !ok {
+			break
+		}
+		ascii.WriteRune(c)
+		poss = append(poss, pos+token.Pos(n))
+		n += size
+	}
+
+	*toks = append(*toks, token.Token{Kind: token.Symbol, Pos: pos, Text: op})
+	if len(poss) > 1 {
+		*toks = append(*toks, token.Token{Kind: token.Lbrace, Pos: pos, Text: "{"})
+	}
+	sc := newScanner(strings.NewReader(ascii.String()))
+	for sc.Next() {
+		tok := sc.Token()
+		tok.Pos = poss[tok.Pos]
+		*toks = append(*toks, tok)
+	}
+	if len(poss) > 1 {
+		*toks = append(*toks, token.Token{Kind: token.Rbrace, Pos: poss[len(poss)-1], Text: "}"})
+	}
+	return n
+}
@@ -0,0 +1,117 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"testing"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/internal/tex2unicode"
+)
+
+func TestParseUnicodeMath(t *testing.T) {
+	p := NewParser()
+	p.UnicodeMath = true
+
+	for _, tc := range []struct {
+		input string
+		want  string // TeX spelling of input
+	}{
+		{`$α + β ≤ ∑ xᵢ²$`, `$\alpha + \beta \leq \sum x_i^2$`},
+		{`$αβx$`, `$\alpha\beta x$`},
+		{`$x²³ + y₁₀ + zⁿ⁺¹$`, `$x^{23} + y_{10} + z^{n+1}$`},
+		{`$aᵢⱼ$`, `$a_{ij}$`},
+		{`$x ∈ ∅ → ∞$`, `$x \in \emptyset \to \infty$`},
+		{`$\left⟨ x \right⟩$`, `$\left\langle x \right\rangle$`},
+		{`$\frac{π}{2}$`, `$\frac{\pi}{2}$`},
+		{`$f'² + g''ⁿ$`, `$f'^2 + g''^n$`},
+		{`$f′(x) + g″ + h′′ + k‴$`, `$f'(x) + g'' + h'' + k'''$`},
+		{`$f′² + g′^n$`, `$f'^2 + g'^n$`},
+		{`α ≤ x² $é$`, `α ≤ x² $é$`},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := p.ParseExpr(tc.input)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.input, err)
+			}
+			want, err := p.ParseExpr(tc.want)
+			if err != nil {
+				t.Fatalf("could not parse %q: %+v", tc.want, err)
+			}
+			if got, want := sprint(got), sprint(want); got != want {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+
+	node, err := ParseExpr(`$α ≤ x²$`)
+	if err != nil {
+		t.Fatalf("could not parse: %+v", err)
+	}
+	if got, want := sprint(node), `ast.List{ast.MathExpr{List:ast.Word{"α"}, ast.Symbol{"≤"}, ast.Word{"x"}, ast.Symbol{"²"}}}`; got != want {
+		t.Fatalf("default parser translated Unicode math:\ngot: %v\nwant:%v", got, want)
+	}
+}
+
+func TestParseUnicodeMathErrors(t *testing.T) {
+	p := NewParser()
+	p.UnicodeMath = true
+
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{`$x^2²$`, `1:5: double superscript`},
+		{`$x²^2$`, `1:5: double superscript`},
+		{`$x_1₂$`, `1:5: double subscript`},
+		{`$x²'$`, `1:5: double superscript`},
+	} {
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := p.ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}
+
+func TestUnicodeMathSpellings(t *testing.T) {
+	p := NewParser()
+	p.UnicodeMath = true
+
+	known := builtinMacros()
+	for _, name := range tex2unicode.Symbols() {
+		r := tex2unicode.Index(name, true)
+		if _, ok := tex2unicode.Name(r); !ok {
+			continue
+		}
+		input := "$" + string(r) + "$"
+		node, err := p.ParseExpr(input)
+		if err != nil {
+			t.Errorf("could not parse %q: %+v", input, err)
+			continue
+		}
+		ast.Inspect(node, func(n ast.Node) bool {
+			if n, ok := n.(*ast.Macro); ok {
+				if _, ok := known[n.Name.Name]; !ok {
+					t.Errorf("%q: unknown LaTeX macro %q", input, n.Name.Name)
+				}
+			}
+			return true
+		})
+	}
+	if got, want := texSpellings['−'], "-"; got != want {
+		t.Errorf("invalid spelling of U+2212: got=%q, want=%q", got, want)
+	}
+	if got, want := texSpellings['′'], "'"; got != want {
+		t.Errorf("invalid spelling of U+2032: got=%q, want=%q", got, want)
+	}
+	if got, want := texSpellings['ℝ'], `\mathbb{R}`; got != want {
+		t.Errorf("invalid spelling of U+211D: got=%q, want=%q", got, want)
+	}
+}
@@ -0,0 +1,434 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/go-latex/latex/ast"
+	"github.com/go-latex/latex/token"
+)
+
+const (
+	// maxExpansionDepth is the maximum nesting depth of user-defined
+	// macro expansions.
+	maxExpansionDepth = 100
+
+	// maxExpansions is the maximum number of user-defined macro
+	// expansions performed while parsing a document.
+	maxExpansions = 10000
+)
+
+// userMacro is a macro defined with \newcommand, \renewcommand,
+// \providecommand or \def.
+// userMacro is expanded during parsing.
+type userMacro struct {
+	nargs int           // number of arguments
+	opt   bool          // whether the first argument is optional
+	def   []token.Token // default value of the optional argument
+	body  []token.Token // replacement text
+}
+
+// parseMacro parses a call of the macro, with its arguments as written,
+// and the nodes it expands to.
+// The source consumed by the expansion, e.g. the arguments of a macro
+// ending the replacement text, is appended to the arguments of the call.
+// If the expansion cannot be parsed at the place of the call, parseMacro
+// returns nil and the expansion is parsed along with the following source.
+func (m *userMacro) parseMacro(p *parser) ast.Node {
+	var (
+		tok   = p.s.tok
+		depth = p.s.depth
+		call  = &ast.Macro{Name: &ast.Ident{NamePos: tok.Pos, Name: tok.Text}}
+	)
+
+	switch {
+	case p.expansions < 0:
+		// expansion limits already exceeded and reported.
+		return p.bad(tok)
+	case depth >= maxExpansionDepth:
+		p.errorf(tok.Pos, "macro %q: maximum expansion depth exceeded", tok.Text)
+		p.expansions = -1
+		return p.bad(tok)
+	case p.expansions >= maxExpansions:
+		p.errorf(tok.Pos, "macro %q: maximum number of expansions exceeded", tok.Text)
+		p.expansions = -1
+		return p.bad(tok)
+	}
+	p.expansions++
+
+	args := make([][]token.Token, m.nargs)
+	for i := range args {
+		if i == 0 && m.opt {
+			args[i] = m.def
+			if p.s.peek().Kind == token.Lbrack {
+				lbrack := p.next().Pos
+				toks, rbrack, _ := p.scanBalanced(token.Rbrack)
+				args[i] = toks
+				call.Args = append(call.Args, p.verbatim(lbrack, rbrack+1, "[", toks, "]"))
+			}
+			continue
+		}
+		arg, verb, ok := p.scanMacroArg()
+		if !ok {
+			p.errorf(tok.Pos, "macro %q: missing argument #%d", tok.Text, i+1)
+			return p.bad(tok)
+		}
+		args[i] = arg
+		call.Args = append(call.Args, verb)
+	}
+
+	expansion, rest := p.parseExpansion(m.expand(args), depth+1)
+	if expansion == nil {
+		return nil
+	}
+	call.Expansion = expansion
+	if len(rest) > 0 {
+		var (
+			beg = rest[0].Pos
+			end = rest[len(rest)-1].Pos + token.Pos(len(rest[len(rest)-1].Text))
+		)
+		call.Args = append(call.Args, p.verbatim(beg, end, "", rest, ""))
+	}
+	return call
+}
+
+// parseExpansion parses the tokens toks of a macro expansion, at the given
+// expansion depth.
+// The expansion may consume tokens following it, e.g. when it begins an
+// environment ended after it, or when it ends with a macro taking its
+// arguments from the following source.
+// parseExpansion returns the parsed nodes and the following tokens they
+// consumed.
+//
+// parseExpansion returns nil when the expansion cannot be parsed at the
+// place of the call, e.g. when it ends the enclosing environment.
+// In that case, toks are pushed back to the scanner, to be parsed along
+// with the following source, as TeX does.
+func (p *parser) parseExpansion(toks []token.Token, depth int) (ast.List, []token.Token) {
+	var (
+		errs   = len(p.errors)
+		macros = make(map[string]macroParser, len(p.macros))
+		start  = len(p.s.log)
+		body   paragraphs
+	)
+	for name, macro := range p.macros {
+		macros[name] = macro
+	}
+
+	p.s.push(toks, depth)
+	p.s.logging++
+	for len(p.s.queue) > 0 && p.s.queue[0].depth >= depth {
+		tok := p.next()
+		if p.parBreak(tok) {
+			body.end()
+			continue
+		}
+		body.add(p.parseNode(tok))
+	}
+	p.s.logging--
+
+	var (
+		outer []pending     // tokens following the expansion
+		rest  []token.Token // non-comment tokens following the expansion
+	)
+	for _, tok := range p.s.log[start:] {
+		if tok.depth < depth {
+			outer = append(outer, tok)
+			if tok.tok.Kind != token.Comment {
+				rest = append(rest, tok.tok)
+			}
+		}
+	}
+	if p.s.logging == 0 {
+		p.s.log = p.s.log[:0]
+	}
+
+	if p.expansions < 0 || len(p.errors) == errs {
+		// errors of exceeded expansion limits are only reported once.
+		list := body.list()
+		if list == nil {
+			list = ast.List{}
+		}
+		return list, rest
+	}
+
+	// parse the expansion again, along with the following source.
+	p.errors = p.errors[:errs]
+	p.macros = macros
+	for len(p.s.queue) > 0 && p.s.queue[0].depth >= depth {
+		p.s.queue = p.s.queue[1:]
+	}
+	p.s.requeue(outer)
+	p.s.push(toks, depth)
+	return nil, nil
+}
+
+// expand returns the replacement text of the macro, with its parameters
+// substituted with args.
+func (m *userMacro) expand(args [][]token.Token) []token.Token {
+	toks := make([]token.Token, 0, len(m.body))
+	for _, tok := range m.body {
+		if tok.Kind != token.Param {
+			toks = append(toks, tok)
+			continue
+		}
+		i := int(tok.Text[1] - '1')
+		toks = append(toks, args[i]...)
+	}
+	return toks
+}
+
+// newcommand parses \newcommand-like macro definitions.
+type newcommand int
+
+const (
+	defineNew     newcommand = iota // \newcommand: the macro must not already exist.
+	defineRenew                     // \renewcommand: the macro must already exist.
+	defineProvide                   // \providecommand: the macro is only defined if it does not already exist.
+)
+
+func (cmd newcommand) parseMacro(p *parser) ast.Node {
+	var (
+		tok = p.s.tok
+		def = &ast.MacroDef{
+			Cmd: &ast.Ident{NamePos: tok.Pos, Name: tok.Text},
+		}
+		macro = new(userMacro)
+		bad   = false
+	)
+
+	if next := p.s.peek(); next.Kind == token.Symbol && next.Text == "*" {
+		p.next()
+	}
+
+	name, ok := p.parseDefName()
+	if !ok {
+		return &ast.Bad{From: tok.Pos, To: p.s.tok.Pos}
+	}
+	def.Name = name
+
+	if p.s.peek().Kind == token.Lbrack {
+		lbrack := p.next().Pos
+		toks, _, _ := p.scanBalanced(token.Rbrack)
+		n, err := strconv.Atoi(strings.TrimSpace(tokensText(toks)))
+		if err != nil || n < 0 || n > 9 {
+			p.errorf(lbrack, "macro %q: invalid number of arguments %q", name.Name, tokensText(toks))
+			n, bad = 9, true
+		}
+		macro.nargs = n
+	}
+
+	if p.s.peek().Kind == token.Lbrack {
+		lbrack := p.next().Pos
+		toks, rbrack, _ := p.scanBalanced(token.Rbrack)
+		if macro.nargs == 0 {
+			p.errorf(lbrack, "macro %q: default value for a macro without arguments", name.Name)
+			bad = true
+		}
+		macro.opt = true
+		macro.def = toks
+		def.Optional = true
+		def.Default = p.text(lbrack+1, rbrack, toks)
+	}
+
+	if !p.parseDefBody(def, macro) || bad {
+		return &ast.Bad{From: tok.Pos, To: p.s.tok.Pos + 1}
+	}
+
+	old, exists := p.macro(name.Name)
+	exists = exists && old != nil
+	switch cmd {
+	case defineNew:
+		if exists {
+			p.errorf(name.Pos(), "macro %q already defined", name.Name)
+			return def
+		}
+	case defineRenew:
+		if !exists {
+			p.errorf(name.Pos(), "macro %q undefined", name.Name)
+		}
+	case defineProvide:
+		if exists {
+			return def
+		}
+	}
+	p.macros[name.Name] = macro
+
+	return def
+}
+
+// texDef parses \def macro definitions.
+// Only undelimited parameters (#1, #2, ...) are supported.
+type texDef struct{}
+
+func (texDef) parseMacro(p *parser) ast.Node {
+	var (
+		tok = p.s.tok
+		def = &ast.MacroDef{
+			Cmd: &ast.Ident{NamePos: tok.Pos, Name: tok.Text},
+		}
+		macro = new(userMacro)
+		bad   = false
+	)
+
+	name, ok := p.parseDefName()
+	if !ok {
+		return &ast.Bad{From: tok.Pos, To: p.s.tok.Pos}
+	}
+	def.Name = name
+
+	for p.s.peek().Kind != token.Lbrace && p.s.peek().Kind != token.EOF {
+		next := p.next()
+		if bad {
+			continue
+		}
+		if next.Kind != token.Param || next.Text != "#"+strconv.Itoa(macro.nargs+1) {
+			p.errorf(next.Pos, "macro %q: invalid parameter %q", name.Name, next.Text)
+			macro.nargs, bad = 9, true
+			continue
+		}
+		macro.nargs++
+	}
+
+	if !p.parseDefBody(def, macro) || bad {
+		return &ast.Bad{From: tok.Pos, To: p.s.tok.Pos + 1}
+	}
+
+	p.macros[name.Name] = macro
+	return def
+}
+
+// parseDefName parses the name of a macro definition, either as \name
+// or as {\name}.
+func (p *parser) parseDefName() (*ast.Ident, bool) {
+	next := p.next()
+	switch next.Kind {
+	case token.Macro:
+		return &ast.Ident{NamePos: next.Pos, Name: next.Text}, true
+	case token.Lbrace:
+		toks, _, ok := p.scanBalanced(token.Rbrace)
+		if ok && len(toks) == 1 && toks[0].Kind == token.Macro {
+			return &ast.Ident{NamePos: toks[0].Pos, Name: toks[0].Text}, true
+		}
+		p.errorf(next.Pos, "invalid macro name %q", tokensText(toks))
+		return nil, false
+	default:
+		p.errorf(next.Pos, "expected macro name, got %q", next.Text)
+		return nil, false
+	}
+}
+
+// parseDefBody parses the replacement text of a macro definition.
+func (p *parser) parseDefBody(def *ast.MacroDef, macro *userMacro) bool {
+	if !p.expect('{') {
+		return false
+	}
+	lbrace := p.s.tok.Pos
+
+	body, rbrace, ok := p.scanBalanced(token.Rbrace)
+	if !ok {
+		return false
+	}
+	for _, tok := range body {
+		if tok.Kind != token.Param {
+			continue
+		}
+		if len(tok.Text) != 2 || int(tok.Text[1]-'0') > macro.nargs {
+			p.errorf(tok.Pos, "macro %q: illegal parameter %q", def.Name.Name, tok.Text)
+			return false
+		}
+	}
+
+	macro.body = body
+	def.NArgs = macro.nargs
+	def.Body = p.text(lbrace+1, rbrace, body)
+	def.Rbrace = rbrace
+	return true
+}
+
+// scanMacroArg scans the tokens of an undelimited macro argument:
+// either a single token or a group of tokens enclosed in braces.
+// scanMacroArg also returns the argument as written.
+func (p *parser) scanMacroArg() ([]token.Token, *ast.Verbatim, bool) {
+	for {
+		next := p.next()
+		switch next.Kind {
+		case token.Space, token.Comment:
+			continue
+		case token.Lbrace:
+			toks, rbrace, ok := p.scanBalanced(token.Rbrace)
+			return toks, p.verbatim(next.Pos, rbrace+1, "{", toks, "}"), ok
+		case token.EOF, token.Rbrace:
+			p.s.unread()
+			return nil, nil, false
+		default:
+			toks := []token.Token{next}
+			end := next.Pos + token.Pos(len(next.Text))
+			return toks, p.verbatim(next.Pos, end, "", toks, ""), true
+		}
+	}
+}
+
+// scanBalanced scans tokens up to the closing delimiter rdelim, skipping
+// over nested brace groups.
+// scanBalanced returns the scanned tokens, the position of the closing
+// delimiter and whether it was found.
+func (p *parser) scanBalanced(rdelim token.Kind) ([]token.Token, token.Pos, bool) {
+	var (
+		ldelim = p.s.tok.Pos
+		toks   []token.Token
+		depth  = 0
+	)
+	for p.s.Next() {
+		tok := p.s.tok
+		switch {
+		case tok.Kind == rdelim && depth == 0:
+			return toks, tok.Pos, true
+		case tok.Kind == token.Lbrace:
+			depth++
+		case tok.Kind == token.Rbrace:
+			depth--
+		case tok.Kind == token.Comment:
+			continue
+		}
+		toks = append(toks, tok)
+	}
+	p.errorf(ldelim, "missing closing %q", closing[rdelim])
+	return toks, p.s.tok.Pos, false
+}
+
+// text returns the source text between the beg and end positions.
+// If the positions do not refer to the source (e.g. for tokens resulting
+// from a macro expansion), the text is reconstructed from toks.
+func (p *parser) text(beg, end token.Pos, toks []token.Token) string {
+	var (
+		i = int(beg) - p.s.base
+		j = int(end) - p.s.base
+	)
+	if i < 0 || j < i || j > len(p.src) {
+		return tokensText(toks)
+	}
+	return p.src[i:j]
+}
+
+// verbatim returns the source text between the beg and end positions, made
+// of the tokens toks enclosed in the left and right delimiters.
+func (p *parser) verbatim(beg, end token.Pos, left string, toks []token.Token, right string) *ast.Verbatim {
+	var (
+		i = beg + token.Pos(len(left))
+		j = end - token.Pos(len(right))
+	)
+	return &ast.Verbatim{VerbPos: beg, Text: left + p.text(i, j, toks) + right}
+}
+
+func tokensText(toks []token.Token) string {
+	o := new(strings.Builder)
+	for _, tok := range toks {
+		o.WriteString(tok.Text)
+	}
+	return o.String()
+}
@@ -0,0 +1,377 @@
+// Copyright ©2020 The go-latex Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-latex/latex/ast"
+)
+
+func TestUserMacros(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  ast.Node
+	}{
+		{
+			input: `\newcommand{\R}{\mathbb{R}}$x\in\R$`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\newcommand`},
+					Name: &ast.Ident{Name: `\R`},
+					Body: `\mathbb{R}`,
+				},
+				&ast.MathExpr{
+					List: ast.List{
+						&ast.Word{Text: "x"},
+						&ast.Macro{Name: &ast.Ident{Name: `\in`}},
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\R`},
+							Expansion: ast.List{
+								&ast.Macro{
+									Name: &ast.Ident{Name: `\mathbb`},
+									Args: ast.List{
+										&ast.Arg{List: ast.List{&ast.Word{Text: "R"}}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			input: `\newcommand\half[1]{\frac{#1}{2}}$\half{x+1}$`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:   &ast.Ident{Name: `\newcommand`},
+					Name:  &ast.Ident{Name: `\half`},
+					NArgs: 1,
+					Body:  `\frac{#1}{2}`,
+				},
+				&ast.MathExpr{
+					List: ast.List{
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\half`},
+							Args: ast.List{&ast.Verbatim{Text: "{x+1}"}},
+							Expansion: ast.List{
+								&ast.Macro{
+									Name: &ast.Ident{Name: `\frac`},
+									Args: ast.List{
+										&ast.Arg{List: ast.List{
+											&ast.Word{Text: "x"},
+											&ast.Symbol{Text: "+"},
+											&ast.Literal{Text: "1"},
+										}},
+										&ast.Arg{List: ast.List{&ast.Literal{Text: "2"}}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			input: `\newcommand*{\pow}[2][2]{#2^{#1}}$\pow{x}\pow[3]y$`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:      &ast.Ident{Name: `\newcommand`},
+					Name:     &ast.Ident{Name: `\pow`},
+					NArgs:    2,
+					Optional: true,
+					Default:  "2",
+					Body:     `#2^{#1}`,
+				},
+				&ast.MathExpr{
+					List: ast.List{
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\pow`},
+							Args: ast.List{&ast.Verbatim{Text: "{x}"}},
+							Expansion: ast.List{
+								&ast.Word{Text: "x"},
+								&ast.Sup{Node: ast.List{&ast.Literal{Text: "2"}}},
+							},
+						},
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\pow`},
+							Args: ast.List{
+								&ast.Verbatim{Text: "[3]"},
+								&ast.Verbatim{Text: "y"},
+							},
+							Expansion: ast.List{
+								&ast.Word{Text: "y"},
+								&ast.Sup{Node: ast.List{&ast.Literal{Text: "3"}}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			input: `\def\sq#1#2{#1^#2}$\sq x 2$`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:   &ast.Ident{Name: `\def`},
+					Name:  &ast.Ident{Name: `\sq`},
+					NArgs: 2,
+					Body:  `#1^#2`,
+				},
+				&ast.MathExpr{
+					List: ast.List{
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\sq`},
+							Args: ast.List{
+								&ast.Verbatim{Text: "x"},
+								&ast.Verbatim{Text: "2"},
+							},
+							Expansion: ast.List{
+								&ast.Word{Text: "x"},
+								&ast.Sup{Node: &ast.Literal{Text: "2"}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			input: `\renewcommand{\pi}{3}\providecommand{\pi}{4}$\pi$`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\renewcommand`},
+					Name: &ast.Ident{Name: `\pi`},
+					Body: `3`,
+				},
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\providecommand`},
+					Name: &ast.Ident{Name: `\pi`},
+					Body: `4`,
+				},
+				&ast.MathExpr{
+					List: ast.List{
+						&ast.Macro{
+							Name:      &ast.Ident{Name: `\pi`},
+							Expansion: ast.List{&ast.Literal{Text: "3"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			input: `\def\a{x}\def\b{\a\a}$\b$`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\def`},
+					Name: &ast.Ident{Name: `\a`},
+					Body: `x`,
+				},
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\def`},
+					Name: &ast.Ident{Name: `\b`},
+					Body: `\a\a`,
+				},
+				&ast.MathExpr{
+					List: ast.List{
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\b`},
+							Expansion: ast.List{
+								&ast.Macro{
+									Name:      &ast.Ident{Name: `\a`},
+									Expansion: ast.List{&ast.Word{Text: "x"}},
+								},
+								&ast.Macro{
+									Name:      &ast.Ident{Name: `\a`},
+									Expansion: ast.List{&ast.Word{Text: "x"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// the source consumed by an expansion is kept with the call.
+			input: `\def\be{\begin{equation}}\def\ee{\end{equation}}\be x\ee`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\def`},
+					Name: &ast.Ident{Name: `\be`},
+					Body: `\begin{equation}`,
+				},
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\def`},
+					Name: &ast.Ident{Name: `\ee`},
+					Body: `\end{equation}`,
+				},
+				&ast.Macro{
+					Name: &ast.Ident{Name: `\be`},
+					Args: ast.List{&ast.Verbatim{Text: ` x\ee`}},
+					Expansion: ast.List{&ast.MathExpr{
+						Display: true,
+						List:    ast.List{&ast.Word{Text: "x"}},
+					}},
+				},
+			},
+		},
+		{
+			input: `\def\sq{^2}\def\p{x^}$\sq\p\sq$`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\def`},
+					Name: &ast.Ident{Name: `\sq`},
+					Body: `^2`,
+				},
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\def`},
+					Name: &ast.Ident{Name: `\p`},
+					Body: `x^`,
+				},
+				&ast.MathExpr{
+					List: ast.List{
+						&ast.Macro{
+							Name:      &ast.Ident{Name: `\sq`},
+							Expansion: ast.List{&ast.Sup{Node: &ast.Literal{Text: "2"}}},
+						},
+						&ast.Macro{
+							Name: &ast.Ident{Name: `\p`},
+							Args: ast.List{&ast.Verbatim{Text: `\sq`}},
+							Expansion: ast.List{
+								&ast.Word{Text: "x"},
+								&ast.Sup{Node: &ast.Macro{
+									Name:      &ast.Ident{Name: `\sq`},
+									Expansion: ast.List{&ast.Sup{Node: &ast.Literal{Text: "2"}}},
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// definitions are local to the enclosing group.
+			input: `\newcommand{\R}{y}{\renewcommand{\R}{x}}$\R$`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\newcommand`},
+					Name: &ast.Ident{Name: `\R`},
+					Body: `y`,
+				},
+				&ast.Group{List: ast.List{&ast.MacroDef{
+					Cmd:  &ast.Ident{Name: `\renewcommand`},
+					Name: &ast.Ident{Name: `\R`},
+					Body: `x`,
+				}}},
+				&ast.MathExpr{
+					List: ast.List{&ast.Macro{
+						Name:      &ast.Ident{Name: `\R`},
+						Expansion: ast.List{&ast.Word{Text: "y"}},
+					}},
+				},
+			},
+		},
+		{
+			input: `\newcommand{\f}[1]{\frac{#1}}$\f{a}{b}$`,
+			want: ast.List{
+				&ast.MacroDef{
+					Cmd:   &ast.Ident{Name: `\newcommand`},
+					Name:  &ast.Ident{Name: `\f`},
+					NArgs: 1,
+					Body:  `\frac{#1}`,
+				},
+				&ast.MathExpr{
+					List: ast.List{&ast.Macro{
+						Name: &ast.Ident{Name: `\f`},
+						Args: ast.List{
+							&ast.Verbatim{Text: "{a}"},
+							&ast.Verbatim{Text: "{b}"},
+						},
+						Expansion: ast.List{&ast.Macro{
+							Name: &ast.Ident{Name: `\frac`},
+							Args: ast.List{
+								&ast.Arg{List: ast.List{&ast.Word{Text: "a"}}},
+								&ast.Arg{List: ast.List{&ast.Word{Text: "b"}}},
+							},
+						}},
+					}},
+				},
+			},
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			node, err := ParseExpr(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := new(strings.Builder)
+			ast.Print(got, node)
+
+			want := new(strings.Builder)
+			ast.Print(want, tc.want)
+
+			if got.String() != want.String() {
+				t.Fatalf("invalid ast:\ngot: %v\nwant:%v", got, want)
+			}
+		})
+	}
+}
+
+func TestUserMacrosErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{
+			input: `\def\a{\a}$\a$`,
+			want:  `1:8: macro "\\a": maximum expansion depth exceeded`,
+		},
+		{
+			input: `\def\a{x}\def\b{\a\a\a\a\a\a\a\a\a\a}\def\c{\b\b\b\b\b\b\b\b\b\b}\def\d{\c\c\c\c\c\c\c\c\c\c}\def\e{\d\d\d\d\d\d\d\d\d\d}$\e$`,
+			want:  `1:119: macro "\\d": maximum number of expansions exceeded`,
+		},
+		{
+			input: `\newcommand{\pi}{3}`,
+			want:  `1:13: macro "\\pi" already defined`,
+		},
+		{
+			input: `\renewcommand{\foo}{3}`,
+			want:  `1:15: macro "\\foo" undefined`,
+		},
+		{
+			input: `\newcommand{\f}[1]{#2}`,
+			want:  `1:20: macro "\\f": illegal parameter "#2"`,
+		},
+		{
+			input: `\newcommand{\f}[x]{#1}`,
+			want:  `1:16: macro "\\f": invalid number of arguments "x"`,
+		},
+		{
+			input: `\def\f#2{#2}`,
+			want:  `1:7: macro "\\f": invalid parameter "#2"`,
+		},
+		{
+			input: `\newcommand{\f}[1]{#1}\f`,
+			want:  `1:23: macro "\\f": missing argument #1`,
+		},
+		{
+			input: `{\newcommand{\R}{x}}$\R$`,
+			want:  `1:22: unknown macro "\\R"`,
+		},
+		{
+			input: `$x^#1$`,
+			want:  `1:4: parameter "#1" outside of a macro definition`,
+		},
+	} {
+		t.Run("", func(t *testing.T) {
+			_, err := ParseExpr(tc.input)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got, want := err.Error(), tc.want; got != want {
+				t.Fatalf("invalid error:\ngot= %s\nwant=%s", got, want)
+			}
+		})
+	}
+}